@@ -0,0 +1,139 @@
+package build
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/pip-services3-go/pip-services3-commons-go/convert"
+	"github.com/pip-services3-go/pip-services3-commons-go/refer"
+	cbuild "github.com/pip-services3-go/pip-services3-components-go/build"
+	"github.com/pip-services3-go/pip-services3-data-go/persistence"
+)
+
+var MemoryPersistenceDescriptor = refer.NewDescriptor("pip-services", "persistence", "memory", "*", "1.0")
+var FilePersistenceDescriptor = refer.NewDescriptor("pip-services", "persistence", "file", "*", "1.0")
+
+// Name of descriptors for components that store items as map[string]interface{}
+const MapPrototypeName = "map"
+
+/*
+Creates generic memory and file persistence components by their descriptors.
+
+The name part of a descriptor selects the prototype of stored items
+registered with RegisterPrototype. Items are stored as map[string]interface{}
+only for the MapPrototypeName name or the "*" wildcard, components with other
+unregistered names are not created. Created components are configured
+by the container, so data services can be defined in container configuration only.
+
+See IdentifiableMemoryPersistence
+See IdentifiableFilePersistence
+
+Example
+
+    factory := build.NewDefaultDataFactory()
+    factory.RegisterPrototype("beacon", reflect.TypeOf(Beacon{}))
+
+    # Container configuration
+    - descriptor: "pip-services:persistence:file:beacon:1.0"
+      path: "./data/beacons.json"
+      options:
+        max_page_size: 50
+*/
+type DefaultDataFactory struct {
+	cbuild.Factory
+	lock       sync.RWMutex
+	prototypes map[string]reflect.Type
+}
+
+// Create a new instance of the factory.
+// Returns *DefaultDataFactory
+func NewDefaultDataFactory() *DefaultDataFactory {
+	c := &DefaultDataFactory{
+		Factory:    *cbuild.NewFactory(),
+		prototypes: make(map[string]reflect.Type),
+	}
+
+	c.Register(MemoryPersistenceDescriptor, func(locator interface{}) interface{} {
+		prototype, _ := c.prototypeFor(locator)
+		return persistence.NewIdentifiableMemoryPersistence(prototype)
+	})
+	c.Register(FilePersistenceDescriptor, func(locator interface{}) interface{} {
+		prototype, _ := c.prototypeFor(locator)
+		return persistence.NewIdentifiableFilePersistence(prototype, nil)
+	})
+
+	return c
+}
+
+// Registers a prototype of stored items under a name used in descriptors.
+// Parameters:
+//   - name string
+//   a name of the prototype that matches the name part of descriptors.
+//   - prototype reflect.Type
+//   a type of stored items.
+func (c *DefaultDataFactory) RegisterPrototype(name string, prototype reflect.Type) {
+	if prototype == nil {
+		panic("Prototype cannot be nil")
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.prototypes[name] = prototype
+}
+
+// Gets a prototype registered under a name.
+// Parameters:
+//   - name string
+//   a name of the prototype.
+// Returns reflect.Type
+// the registered prototype or nil if it is not registered.
+func (c *DefaultDataFactory) GetPrototype(name string) reflect.Type {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.prototypes[name]
+}
+
+// Checks if the factory can create a component identified by the locator.
+// Components are created only for registered prototype names, MapPrototypeName and "*".
+// Parameters:
+//   - locator interface{}
+//   a locator to identify component to be created.
+// Returns interface{}
+// a locator for a component that the factory is able to create or nil.
+func (c *DefaultDataFactory) CanCreate(locator interface{}) interface{} {
+	if _, ok := c.prototypeFor(locator); !ok {
+		return nil
+	}
+	return c.Factory.CanCreate(locator)
+}
+
+// Creates a component identified by the given locator.
+// Parameters:
+//   - locator interface{}
+//   a locator to identify component to be created.
+// Returns interface{}, error
+// the created component or CreateError when the prototype name is not registered.
+func (c *DefaultDataFactory) Create(locator interface{}) (interface{}, error) {
+	if _, ok := c.prototypeFor(locator); !ok && c.Factory.CanCreate(locator) != nil {
+		return nil, cbuild.NewCreateError("", "Prototype is not registered for "+
+			convert.StringConverter.ToString(locator)).WithDetails("locator", locator)
+	}
+	return c.Factory.Create(locator)
+}
+
+// Gets a prototype for the name part of the locator,
+// map prototype for MapPrototypeName and "*" or false when the name is not registered.
+func (c *DefaultDataFactory) prototypeFor(locator interface{}) (reflect.Type, bool) {
+	descriptor, ok := locator.(*refer.Descriptor)
+	if !ok {
+		return nil, false
+	}
+	name := descriptor.Name()
+	if prototype := c.GetPrototype(name); prototype != nil {
+		return prototype, true
+	}
+	if name == MapPrototypeName || name == "*" {
+		return reflect.TypeOf(map[string]interface{}{}), true
+	}
+	return nil, false
+}
@@ -0,0 +1,4 @@
+/*
+Package build contains a factory that creates generic memory and file persistence components by their descriptors,
+so simple data services can be defined in container configuration without wiring code. */
+package build
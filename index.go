@@ -1,5 +1,6 @@
 package persistence
 
 import (
+	_ "github.com/pip-services3-go/pip-services3-data-go/build"
 	_ "github.com/pip-services3-go/pip-services3-data-go/persistence"
 )
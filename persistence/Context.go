@@ -0,0 +1,81 @@
+package persistence
+
+import (
+	"context"
+)
+
+type correlationIdKey struct{}
+
+type tenantIdKey struct{}
+
+// Number of items processed by scans between checks of context cancellation
+const contextCheckInterval = 1000
+
+// Creates a context that carries a correlation id.
+// Parameters:
+//   - ctx context.Context
+//   a parent context.
+//   - correlationId string
+//   transaction id to trace execution through call chain.
+// Returns context.Context
+// a new context with the correlation id.
+func ContextWithCorrelationId(ctx context.Context, correlationId string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, correlationIdKey{}, correlationId)
+}
+
+// Gets a correlation id carried by a context.
+// Parameters:
+//   - ctx context.Context
+//   a context created by ContextWithCorrelationId.
+// Returns string
+// the correlation id or empty string when it is not set.
+func CorrelationIdFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	correlationId, _ := ctx.Value(correlationIdKey{}).(string)
+	return correlationId
+}
+
+// Checks if the context is cancelled or its deadline is exceeded
+// after every contextCheckInterval processed items.
+// Returns the context error or nil.
+func checkContext(ctx context.Context, processed int) error {
+	if processed%contextCheckInterval != 0 {
+		return nil
+	}
+	return ctx.Err()
+}
+
+// Creates a context that carries a tenant id.
+// Persistences in tenant mode scope all operations to this tenant.
+// Parameters:
+//   - ctx context.Context
+//   a parent context.
+//   - tenantId string
+//   an id of the tenant.
+// Returns context.Context
+// a new context with the tenant id.
+func ContextWithTenantId(ctx context.Context, tenantId string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, tenantIdKey{}, tenantId)
+}
+
+// Gets a tenant id carried by a context.
+// Parameters:
+//   - ctx context.Context
+//   a context created by ContextWithTenantId.
+// Returns string
+// the tenant id or empty string when it is not set.
+func TenantIdFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	tenantId, _ := ctx.Value(tenantIdKey{}).(string)
+	return tenantId
+}
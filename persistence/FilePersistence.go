@@ -25,6 +25,7 @@ Configuration parameters
 References
 
 - *:logger:*:*:1.0  (optional) ILogger components to pass log messages
+- *:counters:*:*:1.0  (optional) ICounters components to pass collected measurements
 
 Example
   type MyJsonFilePersistence struct {
@@ -82,5 +83,6 @@ func NewFilePersistence(prototype reflect.Type, persister *JsonFilePersister) *F
 // Configures component by passing configuration parameters.
 //  - config    configuration parameters to be set.
 func (c *FilePersistence) Configure(conf *config.ConfigParams) {
+	c.MemoryPersistence.Configure(conf)
 	c.Persister.Configure(conf)
 }
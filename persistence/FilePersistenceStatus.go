@@ -0,0 +1,236 @@
+package persistence
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pip-services3-go/pip-services3-commons-go/convert"
+	"github.com/pip-services3-go/pip-services3-commons-go/errors"
+)
+
+/*
+Status of a file persistence returned by GetStatus method of FilePersistence
+and IdentifiableFilePersistence. It is intended to be reported by health endpoints.
+
+In tenant mode, when data of each tenant is stored in its own file, Path is the path template,
+Tenants holds status of the file of each tenant and the other file properties summarize them.
+*/
+type FilePersistenceStatus struct {
+	// True when the persistence is opened
+	Opened bool `json:"opened"`
+	// Path to the data file
+	Path string `json:"path"`
+	// True when the data file exists
+	Exists bool `json:"exists"`
+	// True when the data file can be read, or does not exist yet
+	Readable bool `json:"readable"`
+	// True when the data file, or its directory when the file does not exist, can be written
+	Writable bool `json:"writable"`
+	// Size of the data file in bytes
+	FileSize int64 `json:"file_size"`
+	// Number of items stored in memory
+	ItemCount int `json:"item_count"`
+	// True when items were changed after the last successful save
+	Dirty bool `json:"dirty"`
+	// Time of the last successful load, zero if items were never loaded
+	LastLoadTime time.Time `json:"last_load_time"`
+	// Time of the last successful save, zero if items were never saved
+	LastSaveTime time.Time `json:"last_save_time"`
+	// Message of the last load or save error, empty if the last operation succeeded
+	LastError string `json:"last_error,omitempty"`
+	// Status of files of tenants by tenant ids, set in tenant mode only
+	Tenants map[string]*FilePersistenceStatus `json:"tenants,omitempty"`
+}
+
+// Collects status of a memory persistence that stores data in a file
+func getFileStatus(persistence *MemoryPersistence, persister *JsonFilePersister) *FilePersistenceStatus {
+	status := &FilePersistenceStatus{
+		Opened:    persistence.IsOpen(),
+		ItemCount: persistence.GetItemCount(),
+		Dirty:     persistence.IsDirty(),
+	}
+	if tenantPersister, ok := persistence.Saver.(*TenantFilePersister); ok {
+		collectTenantFileStatus(persistence, tenantPersister, status)
+		return status
+	}
+	if persister != nil {
+		collectPersisterStatus(persister, status)
+	}
+	return status
+}
+
+// Collects status of a data file of a persister
+func collectPersisterStatus(persister *JsonFilePersister, status *FilePersistenceStatus) {
+	status.Path = persister.Path()
+	status.LastLoadTime = persister.LastLoadTime()
+	status.LastSaveTime = persister.LastSaveTime()
+	if err := persister.LastError(); err != nil {
+		status.LastError = err.Error()
+	}
+	if status.Path == "" {
+		return
+	}
+
+	info, err := os.Stat(status.Path)
+	if err == nil && !info.IsDir() {
+		status.Exists = true
+		status.FileSize = info.Size()
+		if file, err := os.Open(status.Path); err == nil {
+			status.Readable = true
+			file.Close()
+		}
+		if file, err := os.OpenFile(status.Path, os.O_WRONLY, 0); err == nil {
+			status.Writable = true
+			file.Close()
+		}
+	} else if os.IsNotExist(err) {
+		status.Readable = true
+		status.Writable = isDirWritable(filepath.Dir(status.Path))
+	}
+}
+
+// Checks if a directory exists and can be written
+func isDirWritable(path string) bool {
+	dir, err := os.Stat(path)
+	return err == nil && dir.IsDir() && dir.Mode().Perm()&0222 != 0
+}
+
+// Collects status of files of all tenants which data was loaded or saved.
+// New tenant files are created in the directory that precedes the tenant placeholder.
+func collectTenantFileStatus(persistence *MemoryPersistence, persister *TenantFilePersister,
+	status *FilePersistenceStatus) {
+
+	status.Path = persister.Path()
+	if status.Path == "" {
+		return
+	}
+	base := status.Path
+	if index := strings.Index(base, TenantPathPlaceholder); index >= 0 {
+		base = base[:index]
+	}
+	status.Readable = true
+	status.Writable = isDirWritable(filepath.Dir(base + "_"))
+
+	counts := make(map[string]int)
+	items, unlock := persistence.readItems()
+	for _, item := range items {
+		counts[convert.StringConverter.ToString(persistence.getProperty(item, persistence.TenantField))]++
+	}
+	unlock()
+
+	persisters := persister.tenantPersisters()
+	status.Tenants = make(map[string]*FilePersistenceStatus, len(persisters))
+	for _, tenantId := range sortedTenants(persisters) {
+		tenantStatus := &FilePersistenceStatus{
+			Opened:    status.Opened,
+			ItemCount: counts[tenantId],
+			Dirty:     status.Dirty,
+		}
+		collectPersisterStatus(persisters[tenantId], tenantStatus)
+		status.Tenants[tenantId] = tenantStatus
+
+		status.Exists = status.Exists || tenantStatus.Exists
+		status.Readable = status.Readable && tenantStatus.Readable
+		status.Writable = status.Writable && tenantStatus.Writable
+		status.FileSize += tenantStatus.FileSize
+		if tenantStatus.LastLoadTime.After(status.LastLoadTime) {
+			status.LastLoadTime = tenantStatus.LastLoadTime
+		}
+		if tenantStatus.LastSaveTime.After(status.LastSaveTime) {
+			status.LastSaveTime = tenantStatus.LastSaveTime
+		}
+		if status.LastError == "" && tenantStatus.LastError != "" {
+			status.LastError = tenantStatus.LastError
+		}
+	}
+}
+
+// Gets sorted ids of tenants
+func sortedTenants(persisters map[string]*JsonFilePersister) []string {
+	tenants := make([]string, 0, len(persisters))
+	for tenantId := range persisters {
+		tenants = append(tenants, tenantId)
+	}
+	sort.Strings(tenants)
+	return tenants
+}
+
+// Checks status of a file persistence and returns an error describing the first problem.
+// In tenant mode files of tenants are checked first, so errors point to the failed file.
+func checkFileStatus(correlationId string, status *FilePersistenceStatus) error {
+	switch {
+	case !status.Opened:
+		return errors.NewInvalidStateError(correlationId, "NOT_OPENED", "Persistence is not opened")
+	case status.Path == "":
+		return errors.NewConfigError(correlationId, "NO_PATH", "Data file path is not set")
+	}
+
+	tenants := make([]string, 0, len(status.Tenants))
+	for tenantId := range status.Tenants {
+		tenants = append(tenants, tenantId)
+	}
+	sort.Strings(tenants)
+	for _, tenantId := range tenants {
+		if err := checkFileStatus(correlationId, status.Tenants[tenantId]); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case !status.Readable:
+		return errors.NewFileError(correlationId, "NOT_READABLE", "Data file is not readable: "+status.Path)
+	case !status.Writable:
+		return errors.NewFileError(correlationId, "NOT_WRITABLE", "Data file is not writable: "+status.Path)
+	case status.LastError != "":
+		return errors.NewFileError(correlationId, "LAST_OPERATION_FAILED",
+			"Last load or save failed: "+status.LastError).WithDetails("path", status.Path)
+	default:
+		return nil
+	}
+}
+
+// Gets status of the persistence and its data file.
+// Parameters:
+//   - correlationId string
+//   (optional) transaction id to trace execution through call chain.
+// Returns *FilePersistenceStatus
+// the persistence status.
+func (c *FilePersistence) GetStatus(correlationId string) *FilePersistenceStatus {
+	return getFileStatus(&c.MemoryPersistence, c.Persister)
+}
+
+// Checks if the persistence is opened, its data file is readable and writable
+// and the last load or save succeeded.
+// Parameters:
+//   - correlationId string
+//   (optional) transaction id to trace execution through call chain.
+// Returns error
+// error describing the problem or nil if the persistence is healthy.
+func (c *FilePersistence) CheckHealth(correlationId string) error {
+	return checkFileStatus(correlationId, c.GetStatus(correlationId))
+}
+
+// Gets status of the persistence and its data file.
+// In tenant mode the status includes files of all tenants which data was loaded or saved.
+// Parameters:
+//   - correlationId string
+//   (optional) transaction id to trace execution through call chain.
+// Returns *FilePersistenceStatus
+// the persistence status.
+func (c *IdentifiableFilePersistence) GetStatus(correlationId string) *FilePersistenceStatus {
+	return getFileStatus(&c.MemoryPersistence, c.Persister)
+}
+
+// Checks if the persistence is opened, its data file is readable and writable
+// and the last load or save succeeded. In tenant mode files of all tenants are checked.
+// Parameters:
+//   - correlationId string
+//   (optional) transaction id to trace execution through call chain.
+// Returns error
+// error describing the problem or nil if the persistence is healthy.
+func (c *IdentifiableFilePersistence) CheckHealth(correlationId string) error {
+	return checkFileStatus(correlationId, c.GetStatus(correlationId))
+}
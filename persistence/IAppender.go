@@ -0,0 +1,17 @@
+package persistence
+
+/*
+  Interface for data processing components that append data items
+  to previously saved ones instead of rewriting all of them.
+*/
+type IAppender interface {
+
+	// Appends given data items.
+	// Parameters:
+	//  - correlation_id string
+	//  transaction id to trace execution through call chain.
+	//  - items []interface{}
+	//  a list of items to append.
+	// Retuirns error or nil for success.
+	Append(correlation_id string, items []interface{}) error
+}
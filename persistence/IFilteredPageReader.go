@@ -1,6 +1,10 @@
 package persistence
 
-import "github.com/pip-services3-go/pip-services3-commons-go/data"
+import (
+	"context"
+
+	"github.com/pip-services3-go/pip-services3-commons-go/data"
+)
 
 /*
 IFilteredPageReader is
@@ -22,3 +26,25 @@ type IFilteredPageReader interface {
 	// list of items or error.
 	GetPageByFilter(correlation_id string, filter *data.FilterParams, paging *data.PagingParams, sort *data.SortParams) (page interface{}, err error)
 }
+
+/*
+IFilteredPageReaderWithContext is
+interface for data processing components that can retrieve a page of data items by a filter
+and honor deadlines and cancellation of a context.
+*/
+type IFilteredPageReaderWithContext interface {
+
+	// Gets a page of data items using filter
+	// Parameters
+	//   - ctx context.Context
+	//   a context with deadline, cancellation and correlation id.
+	//   - filter  data.FilterParams
+	//   filter parameters
+	//   - paging data.PagingParams
+	//   paging parameters
+	//   - sort data.SortParams
+	//   sort parameters
+	// Retrun  interface{}, error
+	// list of items or error.
+	GetPageByFilterWithContext(ctx context.Context, filter *data.FilterParams, paging *data.PagingParams, sort *data.SortParams) (page interface{}, err error)
+}
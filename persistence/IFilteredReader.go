@@ -1,6 +1,10 @@
 package persistence
 
-import "github.com/pip-services3-go/pip-services3-commons-go/data"
+import (
+	"context"
+
+	"github.com/pip-services3-go/pip-services3-commons-go/data"
+)
 
 /*
   Interface for data processing components that can retrieve a list of data items by filter.
@@ -19,3 +23,22 @@ type IFilteredReader interface {
 	// receives list of items or error.
 	GetListByFilter(correlation_id string, filter *data.FilterParams, sort *data.SortParams) (items []interface{}, err error)
 }
+
+/*
+  Interface for data processing components that can retrieve a list of data items by filter
+  and honor deadlines and cancellation of a context.
+*/
+type IFilteredReaderWithContext interface {
+
+	// Gets a list of data items using filter
+	// Parameters:
+	//   - ctx context.Context
+	//   a context with deadline, cancellation and correlation id.
+	//   - filter data.FilterParams
+	//   filter parameters
+	//   - sort  data.SortParams
+	//   sort parameters
+	// Returns []interfcace{}, error
+	// receives list of items or error.
+	GetListByFilterWithContext(ctx context.Context, filter *data.FilterParams, sort *data.SortParams) (items []interface{}, err error)
+}
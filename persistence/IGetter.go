@@ -1,5 +1,7 @@
 package persistence
 
+import "context"
+
 /*
   Interface for data processing components that can get data items.
 */
@@ -14,3 +16,20 @@ type IGetter interface {
 	// item or error
 	GetOneById(correlation_id string, id interface{}) (item interface{}, err error)
 }
+
+/*
+  Interface for data processing components that can get data items
+  and honor deadlines and cancellation of a context.
+*/
+type IGetterWithContext interface {
+
+	//  Gets a data items by its unique id.
+	//  Parameters:
+	//   - ctx context.Context
+	//   a context with deadline, cancellation and correlation id.
+	//   - id interface{}
+	//   an id of item to be retrieved.
+	//  Return interface{}, error
+	// item or error
+	GetOneByIdWithContext(ctx context.Context, id interface{}) (item interface{}, err error)
+}
@@ -1,5 +1,7 @@
 package persistence
 
+import "context"
+
 /*
   Interface for data processing components that load data items.
 */
@@ -13,3 +15,18 @@ type ILoader interface {
 	// a list of data items or error.
 	Load(correlation_id string) (items []interface{}, err error)
 }
+
+/*
+  Interface for data processing components that load data items
+  and honor deadlines and cancellation of a context.
+*/
+type ILoaderWithContext interface {
+
+	// Loads data items.
+	// Parameters:
+	//   - ctx context.Context
+	//   a context with deadline, cancellation and correlation id.
+	// Retruns []interface{}, error
+	// a list of data items or error.
+	LoadWithContext(ctx context.Context) (items []interface{}, err error)
+}
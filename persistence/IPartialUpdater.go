@@ -1,6 +1,10 @@
 package persistence
 
-import "github.com/pip-services3-go/pip-services3-commons-go/data"
+import (
+	"context"
+
+	"github.com/pip-services3-go/pip-services3-commons-go/data"
+)
 
 /*
   Interface for data processing components to update data items partially.
@@ -20,3 +24,22 @@ type IPartialUpdater interface {
 	// updated item or error.
 	UpdatePartially(correlation_id string, id interface{}, data *data.AnyValueMap) (item interface{}, err error)
 }
+
+/*
+  Interface for data processing components to update data items partially
+  and honor deadlines and cancellation of a context.
+*/
+type IPartialUpdaterWithContext interface {
+
+	// Updates only few selected fields in a data item.
+	// Parameters:
+	//   - ctx context.Context
+	//   a context with deadline, cancellation and correlation id.
+	//   - id interface{}
+	//   an id of data item to be updated.
+	//   - data data.AnyValueMap
+	//   a map with fields to be updated.
+	// Returns interface{}, error
+	// updated item or error.
+	UpdatePartiallyWithContext(ctx context.Context, id interface{}, data *data.AnyValueMap) (item interface{}, err error)
+}
@@ -1,6 +1,10 @@
 package persistence
 
-import "github.com/pip-services3-go/pip-services3-commons-go/data"
+import (
+	"context"
+
+	"github.com/pip-services3-go/pip-services3-commons-go/data"
+)
 
 /*
   Interface for data processing components that can query a page of data items.
@@ -21,3 +25,24 @@ type IQuerablePageReader interface {
 	// receives list of items or error.
 	GetPageByQuery(correlation_id string, query string, paging *data.PagingParams, sort *data.SortParams) (page interface{}, err error)
 }
+
+/*
+  Interface for data processing components that can query a page of data items
+  and honor deadlines and cancellation of a context.
+*/
+type IQuerablePageReaderWithContext interface {
+
+	//  Gets a page of data items using a query string.
+	//  Parameters:
+	//   - ctx context.Context
+	//   a context with deadline, cancellation and correlation id.
+	//   - query string
+	//    a query string
+	//   - paging data.PagingParams
+	//    paging parameters
+	//   - sort  data.SortParams
+	//    sort parameters
+	// Returns interface{}, error
+	// receives list of items or error.
+	GetPageByQueryWithContext(ctx context.Context, query string, paging *data.PagingParams, sort *data.SortParams) (page interface{}, err error)
+}
@@ -1,6 +1,10 @@
 package persistence
 
-import "github.com/pip-services3-go/pip-services3-commons-go/data"
+import (
+	"context"
+
+	"github.com/pip-services3-go/pip-services3-commons-go/data"
+)
 
 /*
   Interface for data processing components that can query a list of data items.
@@ -19,3 +23,22 @@ type IQuerableReader interface {
 	// list of items or error.
 	GetListByQuery(correlation_id string, query string, sort *data.SortParams) (items []interface{}, err error)
 }
+
+/*
+  Interface for data processing components that can query a list of data items
+  and honor deadlines and cancellation of a context.
+*/
+type IQuerableReaderWithContext interface {
+
+	//  Gets a list of data items using a query string.
+	//  Prameters:
+	//   - ctx context.Context
+	//   a context with deadline, cancellation and correlation id.
+	//   - query string
+	//   a query string
+	//   - sort data.SortParams
+	//   sort parameters
+	// Returns []interface{}, error
+	// list of items or error.
+	GetListByQueryWithContext(ctx context.Context, query string, sort *data.SortParams) (items []interface{}, err error)
+}
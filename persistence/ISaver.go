@@ -1,5 +1,7 @@
 package persistence
 
+import "context"
+
 /*
   Interface for data processing components that save data items.
 */
@@ -14,3 +16,19 @@ type ISaver interface {
 	// Retuirns error or nil for success.
 	Save(correlation_id string, items []interface{}) error
 }
+
+/*
+  Interface for data processing components that save data items
+  and honor deadlines and cancellation of a context.
+*/
+type ISaverWithContext interface {
+
+	// Saves given data items.
+	// Parameters:
+	//   - ctx context.Context
+	//   a context with deadline, cancellation and correlation id.
+	//  - items []interface{}
+	//  a list of items to save.
+	// Retuirns error or nil for success.
+	SaveWithContext(ctx context.Context, items []interface{}) error
+}
@@ -1,5 +1,7 @@
 package persistence
 
+import "context"
+
 /*
   Interface for data processing components that can set (create or update) data items.
 */
@@ -16,3 +18,21 @@ type ISetter interface {
 	// updated item or error.
 	Set(correlation_id string, item interface{}) (value interface{}, err error)
 }
+
+/*
+  Interface for data processing components that can set (create or update) data items
+  and honor deadlines and cancellation of a context.
+*/
+type ISetterWithContext interface {
+
+	// Sets a data item. If the data item exists it updates it,
+	// otherwise it create a new data item.
+	// Parameters:
+	//   - ctx context.Context
+	//   a context with deadline, cancellation and correlation id.
+	//   - item  interface{}
+	//   a item to be set.
+	// Retruns interface{}, error
+	// updated item or error.
+	SetWithContext(ctx context.Context, item interface{}) (value interface{}, err error)
+}
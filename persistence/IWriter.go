@@ -1,5 +1,7 @@
 package persistence
 
+import "context"
+
 /*
   Interface for data processing components that can create, update and delete data items.
 */
@@ -35,3 +37,40 @@ type IWriter interface {
 	//  deleted item or error.
 	DeleteById(correlation_id string, id interface{}) (value interface{}, err error)
 }
+
+/*
+  Interface for data processing components that can create, update and delete data items
+  and honor deadlines and cancellation of a context.
+*/
+type IWriterWithContext interface {
+
+	// Creates a data item.
+	// Parameters:
+	//   - ctx context.Context
+	//   a context with deadline, cancellation and correlation id.
+	//   - item interface{}
+	//   an item to be created.
+	// Returns  interface{}, error
+	// created item or error.
+	CreateWithContext(ctx context.Context, item interface{}) (value interface{}, err error)
+
+	// Updates a data item.
+	// Parameters:
+	//   - ctx context.Context
+	//   a context with deadline, cancellation and correlation id.
+	//   - item interface{}
+	//   an item to be updated.
+	// Returns: interface{}, error
+	// updated item or error.
+	UpdateWithContext(ctx context.Context, item interface{}) (value interface{}, err error)
+
+	// Deleted a data item by it's unique id.
+	// Parameters:
+	//   - ctx context.Context
+	//   a context with deadline, cancellation and correlation id.
+	//   - id interface{}
+	//   an id of the item to be deleted
+	// Returns: interface{}, error
+	// deleted item or error.
+	DeleteByIdWithContext(ctx context.Context, id interface{}) (value interface{}, err error)
+}
@@ -0,0 +1,287 @@
+package persistence
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pip-services3-go/pip-services3-commons-go/convert"
+	cdata "github.com/pip-services3-go/pip-services3-commons-go/data"
+)
+
+// Names of id generators supported by options.id_generator
+const (
+	// Random 32 characters hex strings generated by cdata.IdGenerator.NextLong
+	IdGeneratorLong = "long"
+	// Random UUIDs version 4
+	IdGeneratorUuid = "uuid"
+	// Time-ordered UUIDs version 7
+	IdGeneratorUuid7 = "uuid7"
+	// Time-ordered ULIDs
+	IdGeneratorUlid = "ulid"
+	// Auto-increment integers continued from the largest stored id
+	IdGeneratorSequence = "sequence"
+)
+
+// IIdGenerator is the interface for components that generate ids for new items.
+type IIdGenerator interface {
+	// Generates a new unique id.
+	// Returns interface{}
+	// a new id, it is converted to the type of the id field when it is set.
+	NextId() interface{}
+}
+
+// IdGeneratorFunc is an adapter to use ordinary functions as id generators.
+//
+// Example
+//
+//     persistence.IdGenerator = IdGeneratorFunc(func() interface{} {
+//         return "item-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+//     })
+type IdGeneratorFunc func() interface{}
+
+// Generates a new id by calling the function.
+func (f IdGeneratorFunc) NextId() interface{} {
+	return f()
+}
+
+// Generators that keep track of ids created outside of them, like sequences
+type idObserver interface {
+	Observe(id interface{})
+}
+
+// Creates an id generator by its name.
+// Unknown names fall back to IdGeneratorLong.
+// Parameters:
+//   - name string
+//   a name of the generator: long, uuid, uuid7, ulid or sequence
+// Returns IIdGenerator
+// a new id generator
+func NewIdGenerator(name string) IIdGenerator {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case IdGeneratorUuid:
+		return NewUuidIdGenerator()
+	case IdGeneratorUuid7:
+		return NewUuid7IdGenerator()
+	case IdGeneratorUlid:
+		return NewUlidIdGenerator()
+	case IdGeneratorSequence:
+		return NewSequenceIdGenerator()
+	default:
+		return NewLongIdGenerator()
+	}
+}
+
+// LongIdGenerator generates random 32 characters hex strings.
+// That is the default generator for string ids.
+type LongIdGenerator struct{}
+
+// Creates a new generator of random hex strings.
+func NewLongIdGenerator() *LongIdGenerator {
+	return &LongIdGenerator{}
+}
+
+// Generates a new random hex string.
+func (c *LongIdGenerator) NextId() interface{} {
+	return cdata.IdGenerator.NextLong()
+}
+
+// Formats 16 bytes as a UUID string
+func formatUuid(uuid []byte) string {
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], uuid[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], uuid[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], uuid[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], uuid[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], uuid[10:])
+	return string(buf)
+}
+
+// UuidIdGenerator generates random UUIDs version 4.
+type UuidIdGenerator struct{}
+
+// Creates a new generator of random UUIDs.
+func NewUuidIdGenerator() *UuidIdGenerator {
+	return &UuidIdGenerator{}
+}
+
+// Generates a new random UUID.
+func (c *UuidIdGenerator) NextId() interface{} {
+	uuid := make([]byte, 16)
+	rand.Read(uuid)
+	uuid[6] = (uuid[6] & 0x0f) | 0x40
+	uuid[8] = (uuid[8] & 0x3f) | 0x80
+	return formatUuid(uuid)
+}
+
+// Keeps ids generated within the same millisecond in order:
+// it returns the current time and a counter incremented within the millisecond
+type monotonicClock struct {
+	lock     sync.Mutex
+	lastTime int64
+	counter  uint64
+}
+
+func (c *monotonicClock) next(maxCounter uint64, randomCounter func() uint64) (int64, uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	if now > c.lastTime {
+		c.lastTime = now
+		c.counter = randomCounter()
+	} else if c.counter < maxCounter {
+		c.counter++
+	} else {
+		// Borrow the next millisecond when the counter overflows
+		c.lastTime++
+		c.counter = randomCounter()
+	}
+	return c.lastTime, c.counter
+}
+
+func randomUint64() uint64 {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return binary.BigEndian.Uint64(buf)
+}
+
+// Uuid7IdGenerator generates time-ordered UUIDs version 7.
+// UUIDs generated by the same generator are strictly increasing.
+type Uuid7IdGenerator struct {
+	clock monotonicClock
+}
+
+// Creates a new generator of time-ordered UUIDs.
+func NewUuid7IdGenerator() *Uuid7IdGenerator {
+	return &Uuid7IdGenerator{}
+}
+
+// Generates a new time-ordered UUID.
+func (c *Uuid7IdGenerator) NextId() interface{} {
+	// The counter uses 12 bits of rand_a, starting from a random value below half of the range
+	millis, counter := c.clock.next(0xfff, func() uint64 { return randomUint64() & 0x7ff })
+
+	uuid := make([]byte, 16)
+	binary.BigEndian.PutUint64(uuid[0:8], uint64(millis)<<16)
+	rand.Read(uuid[8:])
+	uuid[6] = 0x70 | byte(counter>>8)
+	uuid[7] = byte(counter)
+	uuid[8] = (uuid[8] & 0x3f) | 0x80
+	return formatUuid(uuid)
+}
+
+const ulidAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// UlidIdGenerator generates time-ordered ULIDs.
+// ULIDs generated by the same generator are strictly increasing.
+type UlidIdGenerator struct {
+	clock monotonicClock
+}
+
+// Creates a new generator of time-ordered ULIDs.
+func NewUlidIdGenerator() *UlidIdGenerator {
+	return &UlidIdGenerator{}
+}
+
+// Generates a new time-ordered ULID.
+func (c *UlidIdGenerator) NextId() interface{} {
+	// The first 40 of 80 random bits work as a counter, starting from a random value below half of the range
+	millis, counter := c.clock.next(1<<40-1, func() uint64 { return randomUint64() & (1<<39 - 1) })
+
+	// 48 bits of time, 40 bits of counter and 40 random bits
+	data := make([]byte, 16)
+	binary.BigEndian.PutUint64(data[0:8], uint64(millis)<<16|counter>>24)
+	binary.BigEndian.PutUint64(data[8:16], counter<<40)
+	random := make([]byte, 5)
+	rand.Read(random)
+	copy(data[11:], random)
+
+	// Encode 128 bits as 26 characters of Crockford's base32 with 2 leading zero bits
+	hi := binary.BigEndian.Uint64(data[0:8])
+	lo := binary.BigEndian.Uint64(data[8:16])
+	buf := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		buf[i] = ulidAlphabet[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(buf)
+}
+
+// SequenceIdGenerator generates auto-increment integer ids.
+// Persistences pass it ids of loaded and stored items, so the sequence
+// continues from the largest stored id after restarts.
+type SequenceIdGenerator struct {
+	last int64
+}
+
+// Creates a new generator of auto-increment integers starting from 1.
+func NewSequenceIdGenerator() *SequenceIdGenerator {
+	return &SequenceIdGenerator{}
+}
+
+// Generates the next integer id.
+func (c *SequenceIdGenerator) NextId() interface{} {
+	return atomic.AddInt64(&c.last, 1)
+}
+
+// Gets the last generated or observed id.
+func (c *SequenceIdGenerator) Last() int64 {
+	return atomic.LoadInt64(&c.last)
+}
+
+// Moves the sequence forward when a larger integer id is stored.
+// Parameters:
+//   - id interface{}
+//   an id of a stored item, ids that are not integers are ignored.
+func (c *SequenceIdGenerator) Observe(id interface{}) {
+	value := convert.LongConverter.ToNullableLong(id)
+	if value == nil {
+		return
+	}
+	for {
+		last := atomic.LoadInt64(&c.last)
+		if *value <= last || atomic.CompareAndSwapInt64(&c.last, last, *value) {
+			return
+		}
+	}
+}
+
+// Chooses a generator for a prototype: a sequence for integer ids
+// and nil to use the default generator of GenerateObjectId for others
+func defaultIdGenerator(prototype reflect.Type, fields []string) IIdGenerator {
+	if prototype == nil {
+		return nil
+	}
+	metadata := getTypeMetadata(prototype)
+	idField := metadata.idField
+	if len(fields) == 1 && metadata.fields != nil {
+		idField, _ = metadata.fields.find(fields[0])
+	}
+	if idField != nil && isNumericKind(idField.typ.Kind()) {
+		return NewSequenceIdGenerator()
+	}
+	return nil
+}
+
+// Generates an id of a new item when it is empty using the configured generator
+func (c *MemoryPersistence) generateId(item *interface{}) error {
+	return generateObjectIdByFields(item, c.keyFields(), c.IdGenerator, c.PropertyTag)
+}
+
+// Passes an id of a stored item to generators that track stored ids
+func (c *MemoryPersistence) observeId(item interface{}) {
+	if observer, ok := c.IdGenerator.(idObserver); ok {
+		observer.Observe(c.getId(item))
+	}
+}
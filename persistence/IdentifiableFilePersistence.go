@@ -2,6 +2,7 @@ package persistence
 
 import (
 	"reflect"
+	"strings"
 
 	"github.com/pip-services3-go/pip-services3-commons-go/config"
 )
@@ -25,13 +26,31 @@ See MemoryPersistence
 
 Configuration parameters
 
-  - path:                    path to the file where data is stored
+  - path:                    path to the file where data is stored,
+                             in tenant mode it may contain {tenant} placeholder to store each tenant in its own file
   - options:
       - max_page_size:       Maximum number of items returned in a single page (default: 100)
+      - shards:              Number of shards, 0 or 1 keeps all items in Items under Lock (default: 0)
+      - copy_on_write:       Reads items published by writers without locks (default: false)
+      - max_items:           Maximum number of stored items, 0 for unlimited (default: 0)
+      - eviction_policy:     Eviction policy: fifo, lru or lfu (default: fifo)
+      - text_fields:         Comma-separated names of properties for the full-text index used by MatchText
+      - latitude_field:      Name of the latitude property for the geospatial index
+      - longitude_field:     Name of the longitude property for the geospatial index
+      - geo_cell_size:       Size of geospatial index cells in degrees (default: 1)
+      - tenant_field:        Name of the property with tenant id, enables tenant mode when set
+      - clone_strategy:      Strategy to copy stored and returned items: deep, shallow or none (default: deep)
+      - id_generator:        Generator of ids for new items: long, uuid, uuid7, ulid or sequence (default: sequence for integer ids, long for others)
+      - id_field:            Comma-separated names of id fields, several fields form a composite key (default: fields with persist:"id" tag or Id)
+  - history:
+      - enabled:             Records versions of items on every change (default: false)
+      - max_versions:        Maximum number of versions kept per item, 0 for unlimited (default: 0)
+      - path:                path to the file where history is stored, new versions are appended to it as JSON lines
 
  References
 
 - *:logger:*:*:1.0      (optional)  ILogger components to pass log messages
+- *:counters:*:*:1.0    (optional)  ICounters components to pass collected measurements
 
 Examples
   type MyFilePersistence  struct {
@@ -110,6 +129,20 @@ func NewIdentifiableFilePersistence(prototype reflect.Type, persister *JsonFileP
 // Parameters:
 //   - config    configuration parameters to be set.
 func (c *IdentifiableFilePersistence) Configure(config *config.ConfigParams) {
-	c.Configure(config)
+	c.IdentifiableMemoryPersistence.Configure(config)
 	c.Persister.Configure(config)
+
+	if c.TenantField != "" && strings.Contains(c.Persister.Path(), TenantPathPlaceholder) {
+		tenantPersister := NewTenantFilePersister(c.Prototype, c.Persister.Path(), c.TenantField)
+		tenantPersister.PropertyTag = c.PropertyTag
+		c.Loader = tenantPersister
+		c.Saver = tenantPersister
+	}
+
+	historyPath := config.GetAsString("history.path")
+	if historyPath != "" {
+		historyPersister := NewJsonLinesFilePersister(c.Prototype, historyPath)
+		c.HistoryLoader = historyPersister
+		c.HistorySaver = historyPersister
+	}
 }
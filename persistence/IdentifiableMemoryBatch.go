@@ -0,0 +1,332 @@
+package persistence
+
+import (
+	"context"
+
+	cdata "github.com/pip-services3-go/pip-services3-commons-go/data"
+	"github.com/pip-services3-go/pip-services3-commons-go/errors"
+)
+
+/*
+Result of processing a single item by batch operations
+of IdentifiableMemoryPersistence.
+*/
+type BatchResult struct {
+	// Index of the item in the batch
+	Index int
+	// Processed item or nil when the item failed
+	Item interface{}
+	// Error for the failed item or nil for success
+	Err error
+}
+
+// Creates multiple data items.
+// Locks of all partitions are acquired once and items are saved once for the entire batch.
+// Items with ids that already exist in the persistence or repeat in the batch
+// are skipped and reported with ConflictError.
+// Parameters:
+//   - correlationId string
+//   (optional) transaction id to trace execution through call chain.
+//   - items []interface{}
+//   items to be created.
+// Returns: []BatchResult, error
+// results for each item in the batch or error when saving failed.
+func (c *IdentifiableMemoryPersistence) CreateMany(correlationId string, items []interface{}) (results []BatchResult, err error) {
+	return c.CreateManyWithContext(ContextWithCorrelationId(context.Background(), correlationId), items)
+}
+
+// Creates multiple data items.
+// The context is checked before items are changed, so the batch is never interrupted halfway.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - items []interface{}
+//   items to be created.
+// Returns: []BatchResult, error
+// results for each item in the batch or error when saving failed.
+func (c *IdentifiableMemoryPersistence) CreateManyWithContext(ctx context.Context, items []interface{}) (results []BatchResult, err error) {
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "create_many")
+	defer func() { timing.end(err) }()
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	var tenantId string
+	if tenantId, err = c.getTenantId(ctx); err != nil {
+		return nil, err
+	}
+
+	results = make([]BatchResult, len(items))
+	created := make([]interface{}, 0, len(items))
+
+	unlock := c.lockAll()
+
+	indexes := c.indexItemsById()
+	for i, item := range items {
+		results[i].Index = i
+
+		newItem := c.cloneItem(item)
+		if results[i].Err = c.generateId(&newItem); results[i].Err != nil {
+			continue
+		}
+		c.stampTenant(&newItem, tenantId)
+		id := c.getId(newItem)
+		key := c.itemKey(newItem)
+		if _, ok := indexes[key]; ok {
+			results[i].Err = errors.NewConflictError(correlationId, "ITEM_EXISTS", "Item "+toIdKey(id)+" already exists").
+				WithDetails("id", id)
+			continue
+		}
+
+		p := c.partition(key)
+		indexes[key] = len(*p.items)
+		c.appendItem(p.items, newItem)
+		c.recordHistory(correlationId, HistoryCreated, newItem)
+		created = append(created, newItem)
+		results[i].Item = newItem
+	}
+
+	unlock()
+	c.Logger.Trace(correlationId, "Created %d of %d items", len(created), len(items))
+	evicted := c.evictItems(correlationId, "")
+	c.notifyEvicted(correlationId, evicted)
+
+	return c.completeBatch(ctx, results, len(created))
+}
+
+// Updates multiple data items.
+// Locks of all partitions are acquired once and items are saved once for the entire batch.
+// Items that do not exist are skipped and reported with NotFoundError.
+// Parameters:
+//   - correlationId string
+//   (optional) transaction id to trace execution through call chain.
+//   - items []interface{}
+//   items to be updated.
+// Returns: []BatchResult, error
+// results for each item in the batch or error when saving failed.
+func (c *IdentifiableMemoryPersistence) UpdateMany(correlationId string, items []interface{}) (results []BatchResult, err error) {
+	return c.UpdateManyWithContext(ContextWithCorrelationId(context.Background(), correlationId), items)
+}
+
+// Updates multiple data items.
+// The context is checked before items are changed, so the batch is never interrupted halfway.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - items []interface{}
+//   items to be updated.
+// Returns: []BatchResult, error
+// results for each item in the batch or error when saving failed.
+func (c *IdentifiableMemoryPersistence) UpdateManyWithContext(ctx context.Context, items []interface{}) (results []BatchResult, err error) {
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "update_many")
+	defer func() { timing.end(err) }()
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	var tenantId string
+	if tenantId, err = c.getTenantId(ctx); err != nil {
+		return nil, err
+	}
+
+	results = make([]BatchResult, len(items))
+	updated := make([]interface{}, 0, len(items))
+
+	unlock := c.lockAll()
+
+	indexes := c.indexItemsById()
+	for i, item := range items {
+		results[i].Index = i
+
+		id := c.getId(item)
+		key := c.tenantKey(tenantId, id)
+		index, ok := indexes[key]
+		if !ok {
+			results[i].Err = errors.NewNotFoundError(correlationId, "ITEM_NOT_FOUND", "Item "+toIdKey(id)+" was not found").
+				WithDetails("id", id)
+			continue
+		}
+
+		newItem := c.cloneItem(item)
+		c.stampTenant(&newItem, tenantId)
+		c.replaceItem(c.partition(key).items, index, newItem)
+		c.recordHistory(correlationId, HistoryUpdated, newItem)
+		updated = append(updated, newItem)
+		results[i].Item = newItem
+	}
+
+	unlock()
+	c.Logger.Trace(correlationId, "Updated %d of %d items", len(updated), len(items))
+
+	return c.completeBatch(ctx, results, len(updated))
+}
+
+// Sets multiple data items. Existing items are updated,
+// and the rest are created.
+// Locks of all partitions are acquired once and items are saved once for the entire batch.
+// Parameters:
+//   - correlationId string
+//   (optional) transaction id to trace execution through call chain.
+//   - items []interface{}
+//   items to be set.
+// Returns: []BatchResult, error
+// results for each item in the batch or error when saving failed.
+func (c *IdentifiableMemoryPersistence) SetMany(correlationId string, items []interface{}) (results []BatchResult, err error) {
+	return c.SetManyWithContext(ContextWithCorrelationId(context.Background(), correlationId), items)
+}
+
+// Sets multiple data items. Existing items are updated,
+// and the rest are created.
+// The context is checked before items are changed, so the batch is never interrupted halfway.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - items []interface{}
+//   items to be set.
+// Returns: []BatchResult, error
+// results for each item in the batch or error when saving failed.
+func (c *IdentifiableMemoryPersistence) SetManyWithContext(ctx context.Context, items []interface{}) (results []BatchResult, err error) {
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "set_many")
+	defer func() { timing.end(err) }()
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	var tenantId string
+	if tenantId, err = c.getTenantId(ctx); err != nil {
+		return nil, err
+	}
+
+	results = make([]BatchResult, len(items))
+
+	unlock := c.lockAll()
+
+	indexes := c.indexItemsById()
+	for i, item := range items {
+		results[i].Index = i
+
+		newItem := c.cloneItem(item)
+		if results[i].Err = c.generateId(&newItem); results[i].Err != nil {
+			continue
+		}
+		c.stampTenant(&newItem, tenantId)
+		key := c.itemKey(newItem)
+		p := c.partition(key)
+		if index, ok := indexes[key]; ok {
+			c.replaceItem(p.items, index, newItem)
+			c.recordHistory(correlationId, HistoryUpdated, newItem)
+		} else {
+			indexes[key] = len(*p.items)
+			c.appendItem(p.items, newItem)
+			c.recordHistory(correlationId, HistoryCreated, newItem)
+		}
+		results[i].Item = newItem
+	}
+
+	unlock()
+	c.Logger.Trace(correlationId, "Set %d items", len(items))
+	evicted := c.evictItems(correlationId, "")
+	c.notifyEvicted(correlationId, evicted)
+
+	return c.completeBatch(ctx, results, len(items))
+}
+
+// Updates only few selected fields in all data items that match to a given filter.
+// Parameters:
+//   - correlationId string
+//   (optional) transaction id to trace execution through call chain.
+//   - filterFunc func(interface{}) bool
+//   (optional) a filter function to filter items.
+//   - data  *cdata.AnyValueMap
+//   a map with fields to be updated.
+// Returns: int, error
+// number of updated items or error.
+func (c *IdentifiableMemoryPersistence) UpdatePartiallyByFilter(correlationId string, filterFunc func(interface{}) bool,
+	data *cdata.AnyValueMap) (count int, err error) {
+	return c.UpdatePartiallyByFilterWithContext(ContextWithCorrelationId(context.Background(), correlationId), filterFunc, data)
+}
+
+// Updates only few selected fields in all data items that match to a given filter.
+// The context is checked before items are changed, so the update is never interrupted halfway.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - filterFunc func(interface{}) bool
+//   (optional) a filter function to filter items.
+//   - data  *cdata.AnyValueMap
+//   a map with fields to be updated.
+// Returns: int, error
+// number of updated items or error.
+func (c *IdentifiableMemoryPersistence) UpdatePartiallyByFilterWithContext(ctx context.Context, filterFunc func(interface{}) bool,
+	data *cdata.AnyValueMap) (count int, err error) {
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "update_partially_by_filter")
+	defer func() { timing.end(err) }()
+	if err = ctx.Err(); err != nil {
+		return 0, err
+	}
+	var tenantId string
+	if tenantId, err = c.getTenantId(ctx); err != nil {
+		return 0, err
+	}
+	if filterFunc, err = c.tenantFilter(ctx, filterFunc); err != nil {
+		return 0, err
+	}
+
+	updated := make([]interface{}, 0)
+
+	unlock := c.lockAll()
+
+	for _, p := range c.partitions() {
+		for i, item := range *p.items {
+			if filterFunc != nil && !filterFunc(item) {
+				continue
+			}
+
+			newItem := c.applyPartialUpdate(item, data)
+			c.stampTenant(&newItem, tenantId)
+			c.replaceItem(p.items, i, newItem)
+			c.recordHistory(correlationId, HistoryUpdated, newItem)
+			updated = append(updated, newItem)
+		}
+	}
+
+	unlock()
+	c.Logger.Trace(correlationId, "Partially updated %d items", len(updated))
+
+	_, err = c.completeBatch(ctx, nil, len(updated))
+	return len(updated), err
+}
+
+// Maps keys of item ids within tenants to indexes of the items in their partitions.
+// The method shall be called under lock of all partitions.
+func (c *IdentifiableMemoryPersistence) indexItemsById() map[string]int {
+	indexes := make(map[string]int)
+	for _, p := range c.partitions() {
+		for i, v := range *p.items {
+			indexes[c.itemKey(v)] = i
+		}
+	}
+	return indexes
+}
+
+// Prepares batch results to be returned to the caller
+// and saves items once when any of them were changed.
+func (c *IdentifiableMemoryPersistence) completeBatch(ctx context.Context,
+	results []BatchResult, changed int) ([]BatchResult, error) {
+	for i := range results {
+		if results[i].Item != nil {
+			results[i].Item = c.cloneResult(results[i].Item)
+		}
+	}
+
+	if changed == 0 {
+		return results, nil
+	}
+
+	err := c.SaveWithContext(ctx)
+	if err == nil {
+		err = c.saveHistory(CorrelationIdFromContext(ctx))
+	}
+	return results, err
+}
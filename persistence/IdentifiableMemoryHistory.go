@@ -0,0 +1,342 @@
+package persistence
+
+import (
+	"context"
+	"time"
+)
+
+// Opens the component and loads recorded history
+// when history loader is configured.
+// Parameters:
+//   - correlationId  string
+//   (optional) transaction id to trace execution through call chain.
+// Returns  error or null no errors occured.
+func (c *IdentifiableMemoryPersistence) Open(correlationId string) error {
+	return c.OpenWithContext(ContextWithCorrelationId(context.Background(), correlationId))
+}
+
+// Opens the component and loads recorded history
+// when history loader is configured.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+// Returns  error or null no errors occured.
+func (c *IdentifiableMemoryPersistence) OpenWithContext(ctx context.Context) error {
+	err := c.MemoryPersistence.OpenWithContext(ctx)
+	if err == nil {
+		err = c.loadHistory(CorrelationIdFromContext(ctx))
+	}
+	if err != nil {
+		c.opened = false
+	}
+	return err
+}
+
+// Deletes data items that match to a given filter
+// and records their last versions when history mode is enabled.
+// Parameters:
+//   - correlationId  string
+//   (optional) transaction id to trace execution through call chain.
+//   - filter  filter func(interface{}) bool
+//   (optional) a filter function to filter items.
+// Retruns: error
+// error or nil for success.
+func (c *IdentifiableMemoryPersistence) DeleteByFilter(correlationId string, filterFunc func(interface{}) bool) (err error) {
+	return c.DeleteByFilterWithContext(ContextWithCorrelationId(context.Background(), correlationId), filterFunc)
+}
+
+// Deletes data items that match to a given filter,
+// records their last versions when history mode is enabled
+// and applies delete actions of declared relations.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - filter  filter func(interface{}) bool
+//   (optional) a filter function to filter items.
+// Retruns: error
+// error or nil for success.
+func (c *IdentifiableMemoryPersistence) DeleteByFilterWithContext(ctx context.Context, filterFunc func(interface{}) bool) (err error) {
+	if !c.HistoryEnabled && !c.hasDeleteActions() {
+		return c.MemoryPersistence.DeleteByFilterWithContext(ctx, filterFunc)
+	}
+
+	correlationId := CorrelationIdFromContext(ctx)
+	deleted, err := c.deleteByFilter(ctx, filterFunc, func(items []interface{}) error {
+		if err := c.checkDeleteRestrictions(ctx, items); err != nil {
+			return err
+		}
+		c.recordHistories(correlationId, HistoryDeleted, items)
+		return nil
+	})
+
+	if err == nil && len(deleted) > 0 {
+		err = c.saveHistory(correlationId)
+	}
+	if err == nil {
+		err = c.cascadeDelete(ctx, deleted)
+	}
+	return err
+}
+
+// Clears component state and records last versions of removed items
+// when history mode is enabled.
+// Parameters:
+//  - correlationId string
+//  (optional) transaction id to trace execution through call chain.
+//  Returns error or null no errors occured.
+func (c *IdentifiableMemoryPersistence) Clear(correlationId string) error {
+	return c.ClearWithContext(ContextWithCorrelationId(context.Background(), correlationId))
+}
+
+// Clears component state and records last versions of removed items
+// when history mode is enabled.
+// In tenant mode only items of the tenant from the context are removed.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//  Returns error or null no errors occured.
+func (c *IdentifiableMemoryPersistence) ClearWithContext(ctx context.Context) (err error) {
+	if !c.HistoryEnabled {
+		return c.MemoryPersistence.ClearWithContext(ctx)
+	}
+
+	correlationId := CorrelationIdFromContext(ctx)
+	err = c.clear(ctx, func(items []interface{}) {
+		c.recordHistories(correlationId, HistoryDeleted, items)
+	})
+	if err == nil {
+		err = c.saveHistory(correlationId)
+	}
+	return err
+}
+
+// Restores state of the persistence from a snapshot and records
+// changed items when history mode is enabled. Items missing in the snapshot
+// are recorded as deleted, new and changed items as created and updated.
+// Parameters:
+//   - correlationId string
+//   (optional) transaction id to trace execution through call chain.
+//   - snapshot *MemorySnapshot
+//   a snapshot previously captured by Snapshot or loaded by LoadMemorySnapshot.
+// Returns error or nil for success.
+func (c *IdentifiableMemoryPersistence) Restore(correlationId string, snapshot *MemorySnapshot) error {
+	if !c.HistoryEnabled {
+		return c.MemoryPersistence.Restore(correlationId, snapshot)
+	}
+
+	err := c.restore(correlationId, snapshot, func(oldItems []interface{}, newItems []interface{}) {
+		c.recordRestoredHistory(correlationId, oldItems, newItems)
+	}, func(item interface{}) {
+		c.recordHistory(correlationId, HistoryEvicted, item)
+	})
+	if err == nil {
+		err = c.saveHistory(correlationId)
+	}
+	return err
+}
+
+// Gets all recorded versions of a data item.
+// Parameters:
+//   - correlationId  string
+//   (optional) transaction id to trace execution through call chain.
+//   - id interface{}
+//   an id of data item.
+// Returns: []HistoryEntry, error
+// versions ordered from the oldest to the newest or error.
+func (c *IdentifiableMemoryPersistence) GetHistoryById(correlationId string, id interface{}) (result []HistoryEntry, err error) {
+	return c.GetHistoryByIdWithContext(ContextWithCorrelationId(context.Background(), correlationId), id)
+}
+
+// Gets all recorded versions of a data item.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - id interface{}
+//   an id of data item.
+// Returns: []HistoryEntry, error
+// versions ordered from the oldest to the newest or error.
+func (c *IdentifiableMemoryPersistence) GetHistoryByIdWithContext(ctx context.Context, id interface{}) (result []HistoryEntry, err error) {
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "get_history_by_id")
+	defer func() { timing.end(err) }()
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	var tenantId string
+	if tenantId, err = c.getTenantId(ctx); err != nil {
+		return nil, err
+	}
+
+	versions := c.history.get(c.tenantKey(tenantId, c.toKey(id)))
+	result = make([]HistoryEntry, len(versions))
+	for i, v := range versions {
+		result[i] = *v
+		if v.Item != nil {
+			result[i].Item = c.cloneResult(v.Item)
+		}
+	}
+
+	timing.size(len(result))
+	c.Logger.Trace(correlationId, "Retrieved %d versions of item %s", len(result), id)
+	return result, nil
+}
+
+// Gets a data item as it was at specified point in time.
+// Parameters:
+//   - correlationId  string
+//   (optional) transaction id to trace execution through call chain.
+//   - id interface{}
+//   an id of data item.
+//   - asOf time.Time
+//   a point in time.
+// Returns: interface{}, error
+// the data item, nil if it didn't exist at that time, or error.
+func (c *IdentifiableMemoryPersistence) GetAsOf(correlationId string, id interface{}, asOf time.Time) (result interface{}, err error) {
+	return c.GetAsOfWithContext(ContextWithCorrelationId(context.Background(), correlationId), id, asOf)
+}
+
+// Gets a data item as it was at specified point in time.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - id interface{}
+//   an id of data item.
+//   - asOf time.Time
+//   a point in time.
+// Returns: interface{}, error
+// the data item, nil if it didn't exist at that time or was deleted or evicted, or error.
+func (c *IdentifiableMemoryPersistence) GetAsOfWithContext(ctx context.Context, id interface{}, asOf time.Time) (result interface{}, err error) {
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "get_as_of")
+	defer func() { timing.end(err) }()
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	var tenantId string
+	if tenantId, err = c.getTenantId(ctx); err != nil {
+		return nil, err
+	}
+
+	entry := c.history.asOf(c.tenantKey(tenantId, c.toKey(id)), asOf)
+	if entry == nil || entry.Operation == HistoryDeleted || entry.Operation == HistoryEvicted {
+		c.Logger.Trace(correlationId, "Cannot find item %s as of %v", id, asOf)
+		return nil, nil
+	}
+
+	c.Logger.Trace(correlationId, "Retrieved item %s as of %v", id, asOf)
+	return c.cloneResult(entry.Item), nil
+}
+
+// Records a new version of the item when history mode is enabled.
+// The method shall be called under write lock, so versions follow the order of changes.
+func (c *IdentifiableMemoryPersistence) recordHistory(correlationId string, operation string, item interface{}) {
+	if !c.HistoryEnabled {
+		return
+	}
+
+	entry := &HistoryEntry{
+		Id:            c.getId(item),
+		TenantId:      c.getItemTenantId(item),
+		Time:          time.Now().UTC(),
+		CorrelationId: correlationId,
+		Operation:     operation,
+		Item:          c.cloneItem(item),
+	}
+	c.history.record(c.tenantKey(entry.TenantId, entry.Id), entry, c.MaxHistoryVersions)
+}
+
+// Records new versions of items when history mode is enabled.
+// The method shall be called under write lock.
+func (c *IdentifiableMemoryPersistence) recordHistories(correlationId string, operation string, items []interface{}) {
+	for _, item := range items {
+		c.recordHistory(correlationId, operation, item)
+	}
+}
+
+// Records versions of items replaced by restore: items that are missing among
+// new items are recorded as deleted, new and changed items as created and updated.
+// The method shall be called under write lock.
+func (c *IdentifiableMemoryPersistence) recordRestoredHistory(correlationId string,
+	oldItems []interface{}, newItems []interface{}) {
+	oldByKey := make(map[string]interface{}, len(oldItems))
+	for _, item := range oldItems {
+		oldByKey[c.itemKey(item)] = item
+	}
+
+	restored := make(map[string]bool, len(newItems))
+	for _, item := range newItems {
+		key := c.itemKey(item)
+		restored[key] = true
+		if oldItem, ok := oldByKey[key]; !ok {
+			c.recordHistory(correlationId, HistoryCreated, item)
+		} else if !ValueComparer.Equals(oldItem, item) {
+			c.recordHistory(correlationId, HistoryUpdated, item)
+		}
+	}
+	for _, item := range oldItems {
+		if !restored[c.itemKey(item)] {
+			c.recordHistory(correlationId, HistoryDeleted, item)
+		}
+	}
+}
+
+// Evicts extra items and records them in history under write locks of their partitions.
+// The method shall be called after the lock of added items is released.
+// Returns a list of evicted items.
+func (c *IdentifiableMemoryPersistence) evictItems(correlationId string, keep string) []interface{} {
+	return c.evict(keep, func(item interface{}) {
+		c.recordHistory(correlationId, HistoryEvicted, item)
+	})
+}
+
+func (c *IdentifiableMemoryPersistence) loadHistory(correlationId string) error {
+	if !c.HistoryEnabled || c.HistoryLoader == nil {
+		return nil
+	}
+
+	values, err := c.HistoryLoader.Load(correlationId)
+	if err != nil {
+		return err
+	}
+
+	entries := toHistoryEntries(values, c.Prototype)
+	c.history.replace(entries, func(entry *HistoryEntry) string {
+		return c.tenantKey(entry.TenantId, c.toKey(entry.Id))
+	}, c.MaxHistoryVersions)
+	c.Logger.Trace(correlationId, "Loaded %d history entries", len(entries))
+	return nil
+}
+
+// Saves versions recorded after the last save.
+// Savers that implement IAppender receive only new versions,
+// other savers receive all versions. Versions that failed to save
+// are saved again with the next change.
+func (c *IdentifiableMemoryPersistence) saveHistory(correlationId string) (err error) {
+	if !c.HistoryEnabled {
+		return nil
+	}
+
+	if c.HistorySaver == nil {
+		c.history.clearPending()
+		return nil
+	}
+
+	appender, canAppend := c.HistorySaver.(IAppender)
+	entries, all := c.history.takePending(!canAppend)
+	if entries == nil {
+		return nil
+	}
+
+	if all {
+		err = c.HistorySaver.Save(correlationId, entries)
+	} else {
+		err = appender.Append(correlationId, entries)
+	}
+	if err != nil {
+		c.history.restorePending(entries, all)
+		return err
+	}
+
+	c.Logger.Trace(correlationId, "Saved %d history entries", len(entries))
+	return nil
+}
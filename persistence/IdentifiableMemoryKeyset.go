@@ -0,0 +1,142 @@
+package persistence
+
+import (
+	"context"
+	"sort"
+)
+
+// Gets a page of data items using keyset paging.
+// Items are ordered by a sort field and then by id, and the next page starts right after
+// the last item of the previous one, so pages are stable under concurrent writes.
+// Parameters:
+//   - correlationId string
+//   (optional) transaction id to trace execution through call chain.
+//   - filterFunc func(interface{}) bool
+//   (optional) a filter function to filter items
+//   - paging *KeysetPagingParams
+//   (optional) keyset paging parameters
+//   - sortField string
+//   (optional) name of the property to sort by, empty to sort by id only
+//   - descending bool
+//   true to sort items in descending order
+//   - selectFunc func(in interface{}) (out interface{})
+//   (optional) projection parameters
+// Returns *KeysetPage, error
+// data page or error.
+func (c *IdentifiableMemoryPersistence) GetPageByKeyset(correlationId string, filterFunc func(interface{}) bool,
+	paging *KeysetPagingParams, sortField string, descending bool,
+	selectFunc func(in interface{}) (out interface{})) (page *KeysetPage, err error) {
+	return c.GetPageByKeysetWithContext(ContextWithCorrelationId(context.Background(), correlationId),
+		filterFunc, paging, sortField, descending, selectFunc)
+}
+
+// Gets a page of data items using keyset paging.
+// The scan over items stops when the context is cancelled.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - filterFunc func(interface{}) bool
+//   (optional) a filter function to filter items
+//   - paging *KeysetPagingParams
+//   (optional) keyset paging parameters
+//   - sortField string
+//   (optional) name of the property to sort by, empty to sort by id only
+//   - descending bool
+//   true to sort items in descending order
+//   - selectFunc func(in interface{}) (out interface{})
+//   (optional) projection parameters
+// Returns *KeysetPage, error
+// data page or error.
+func (c *IdentifiableMemoryPersistence) GetPageByKeysetWithContext(ctx context.Context, filterFunc func(interface{}) bool,
+	paging *KeysetPagingParams, sortField string, descending bool,
+	selectFunc func(in interface{}) (out interface{})) (page *KeysetPage, err error) {
+
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "get_page_by_keyset")
+	defer func() { timing.end(err) }()
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	if filterFunc, err = c.tenantFilter(ctx, filterFunc); err != nil {
+		return nil, err
+	}
+
+	if paging == nil {
+		paging = NewKeysetPagingParams("", 0)
+	}
+
+	var lastKey, lastId interface{}
+	hasPosition := paging.Token != ""
+	if hasPosition {
+		lastKey, lastId, err = decodeKeysetToken(correlationId, paging.Token, sortField, descending)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	take := paging.Take
+	if take <= 0 || (c.MaxPageSize > 0 && take > int64(c.MaxPageSize)) {
+		take = int64(c.MaxPageSize)
+	}
+
+	getKey := func(item interface{}) interface{} {
+		if sortField == "" {
+			return nil
+		}
+		return c.getProperty(item, sortField)
+	}
+	compare := func(key1, id1, key2, id2 interface{}) int {
+		result := ValueComparer.Compare(key1, key2)
+		if result == 0 {
+			result = ValueComparer.Compare(id1, id2)
+		}
+		if descending {
+			result = -result
+		}
+		return result
+	}
+
+	stored, unlock := c.readItems()
+	defer unlock()
+
+	// Apply filtering and skip items up to the last position
+	items := make([]interface{}, 0)
+	for i, v := range stored {
+		if err = checkContext(ctx, i); err != nil {
+			return nil, err
+		}
+		if filterFunc != nil && !filterFunc(v) {
+			continue
+		}
+		if hasPosition && compare(getKey(v), c.getId(v), lastKey, lastId) <= 0 {
+			continue
+		}
+		items = append(items, v)
+	}
+
+	// Apply sorting
+	sort.SliceStable(items, func(i, j int) bool {
+		return compare(getKey(items[i]), c.getId(items[i]), getKey(items[j]), c.getId(items[j])) < 0
+	})
+
+	// Extract a page
+	token := ""
+	if take > 0 && int64(len(items)) > take {
+		items = items[:take]
+		last := items[len(items)-1]
+		token = encodeKeysetToken(sortField, descending, getKey(last), c.getId(last))
+	}
+
+	timing.size(len(items))
+	c.Logger.Trace(correlationId, "Retrieved %d items", len(items))
+
+	data := make([]interface{}, len(items))
+	for i, v := range items {
+		if selectFunc != nil {
+			v = selectFunc(v)
+		}
+		data[i] = c.cloneResult(v)
+	}
+
+	return NewKeysetPage(token, data), nil
+}
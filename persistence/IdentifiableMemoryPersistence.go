@@ -1,10 +1,12 @@
 package persistence
 
 import (
+	"context"
 	"reflect"
 
 	"github.com/pip-services3-go/pip-services3-commons-go/config"
 	cdata "github.com/pip-services3-go/pip-services3-commons-go/data"
+	"github.com/pip-services3-go/pip-services3-commons-go/errors"
 	refl "github.com/pip-services3-go/pip-services3-commons-go/reflect"
 	"github.com/pip-services3-go/pip-services3-components-go/log"
 )
@@ -20,7 +22,12 @@ All other operations can be used out of the box.
 
 In complex scenarios child structes can implement additional operations by
 accessing cached items via c.Items property and calling Save method
-on updates.
+on updates. Items stays empty in sharded mode, see MemoryPersistence.
+
+In tenant mode operations receive the tenant id in context created by ContextWithTenantId.
+Items of other tenants are invisible: reads skip them, and updates and deletes
+return NotFoundError for them as well as for items that do not exist.
+Ids are unique within a tenant, so different tenants may have items with the same id.
 
 See MemoryPersistence
 
@@ -28,10 +35,26 @@ Configuration parameters
 
 - options:
     - max_page_size:       Maximum number of items returned in a single page (default: 100)
+    - shards:              Number of shards, 0 or 1 keeps all items in Items under Lock (default: 0)
+    - copy_on_write:       Reads items published by writers without locks (default: false)
+    - max_items:           Maximum number of stored items, 0 for unlimited (default: 0)
+    - eviction_policy:     Eviction policy: fifo, lru or lfu (default: fifo)
+    - text_fields:         Comma-separated names of properties for the full-text index used by MatchText
+    - latitude_field:      Name of the latitude property for the geospatial index
+    - longitude_field:     Name of the longitude property for the geospatial index
+    - geo_cell_size:       Size of geospatial index cells in degrees (default: 1)
+    - tenant_field:        Name of the property with tenant id, enables tenant mode when set
+    - clone_strategy:      Strategy to copy stored and returned items: deep, shallow or none (default: deep)
+    - id_generator:        Generator of ids for new items: long, uuid, uuid7, ulid or sequence (default: sequence for integer ids, long for others)
+    - id_field:            Comma-separated names of id fields, several fields form a composite key (default: fields with persist:"id" tag or Id)
+- history:
+    - enabled:             Records versions of items on every change (default: false)
+    - max_versions:        Maximum number of versions kept per item, 0 for unlimited (default: 0)
 
  References
 
 - *:logger:*:*:1.0     (optional) ILogger components to pass log messages
+- *:counters:*:*:1.0   (optional) ICounters components to pass collected measurements
 
  Examples
 
@@ -77,6 +100,16 @@ Configuration parameters
 // extends MemoryPersistence  implements IConfigurable, IWriter, IGetter, ISetter
 type IdentifiableMemoryPersistence struct {
 	MemoryPersistence
+	// Enables recording of item versions on every change
+	HistoryEnabled bool
+	// Maximum number of versions kept per item, 0 means unlimited
+	MaxHistoryVersions int
+	// Optional loader for recorded history
+	HistoryLoader ILoader
+	// Optional saver for recorded history
+	HistorySaver ISaver
+	history      *itemHistory
+	relations    []*Relation
 }
 
 // Creates a new empty instance of the persistence.
@@ -90,6 +123,7 @@ func NewIdentifiableMemoryPersistence(prototype reflect.Type) (c *IdentifiableMe
 	c.MemoryPersistence = *NewMemoryPersistence(prototype)
 	c.Logger = log.NewCompositeLogger()
 	c.MaxPageSize = 100
+	c.history = newItemHistory()
 	return c
 }
 
@@ -98,7 +132,10 @@ func NewIdentifiableMemoryPersistence(prototype reflect.Type) (c *IdentifiableMe
 //  - config  *config.ConfigParams
 //  configuration parameters to be set.
 func (c *IdentifiableMemoryPersistence) Configure(config *config.ConfigParams) {
+	c.MemoryPersistence.Configure(config)
 	c.MaxPageSize = config.GetAsIntegerWithDefault("options.max_page_size", c.MaxPageSize)
+	c.HistoryEnabled = config.GetAsBooleanWithDefault("history.enabled", c.HistoryEnabled)
+	c.MaxHistoryVersions = config.GetAsIntegerWithDefault("history.max_versions", c.MaxHistoryVersions)
 }
 
 // Gets a list of data items retrieved by given unique ids.
@@ -110,19 +147,29 @@ func (c *IdentifiableMemoryPersistence) Configure(config *config.ConfigParams) {
 // Returns  []interface{}, error
 // data list or error.
 func (c *IdentifiableMemoryPersistence) GetListByIds(correlationId string, ids []interface{}) (result []interface{}, err error) {
+	return c.GetListByIdsWithContext(ContextWithCorrelationId(context.Background(), correlationId), ids)
+}
+
+// Gets a list of data items retrieved by given unique ids.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - ids  []interface{}
+//   ids of data items to be retrieved
+// Returns  []interface{}, error
+// data list or error.
+func (c *IdentifiableMemoryPersistence) GetListByIdsWithContext(ctx context.Context, ids []interface{}) (result []interface{}, err error) {
 	filter := func(item interface{}) bool {
 		exist := false
-		id := GetObjectId(item)
 		for _, v := range ids {
-			vId := refl.ObjectReader.GetValue(v)
-			if CompareValues(id, vId) {
+			if c.hasId(item, c.toKey(refl.ObjectReader.GetValue(v))) {
 				exist = true
 				break
 			}
 		}
 		return exist
 	}
-	return c.GetListByFilter(correlationId, filter, nil, nil)
+	return c.GetListByFilterWithContext(ctx, filter, nil, nil)
 }
 
 // Gets a data item by its unique id.
@@ -134,21 +181,47 @@ func (c *IdentifiableMemoryPersistence) GetListByIds(correlationId string, ids [
 // Returns:  interface{}, error
 // data item or error.
 func (c *IdentifiableMemoryPersistence) GetOneById(correlationId string, id interface{}) (result interface{}, err error) {
-	c.Lock.RLock()
-	defer c.Lock.RUnlock()
+	return c.GetOneByIdWithContext(ContextWithCorrelationId(context.Background(), correlationId), id)
+}
+
+// Gets a data item by its unique id.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - id interface{}
+//   an id of data item to be retrieved.
+// Returns:  interface{}, error
+// data item or error.
+func (c *IdentifiableMemoryPersistence) GetOneByIdWithContext(ctx context.Context, id interface{}) (result interface{}, err error) {
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "get_one_by_id")
+	defer func() { timing.end(err) }()
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	var tenantId string
+	if tenantId, err = c.getTenantId(ctx); err != nil {
+		return nil, err
+	}
+
+	id = c.toKey(id)
+	key := c.tenantKey(tenantId, id)
+
+	stored, unlock := c.readPartition(key)
+	defer unlock()
 
 	var items []interface{}
-	for _, v := range c.Items {
-		vId := GetObjectId(v)
-		if CompareValues(vId, id) {
+	for _, v := range stored {
+		if c.hasId(v, id) && c.belongsToTenant(v, tenantId) {
 			items = append(items, v)
 		}
 	}
 
 	var item interface{} = nil
 	if len(items) > 0 {
+		c.tracker.touch(c.itemKey(items[0]))
 		//item = CloneObject(items[0])
-		item = CloneObjectForResult(items[0], c.Prototype)
+		item = c.cloneResult(items[0])
 	}
 	if item != nil {
 		c.Logger.Trace(correlationId, "Retrieved item %s", id)
@@ -158,13 +231,14 @@ func (c *IdentifiableMemoryPersistence) GetOneById(correlationId string, id inte
 	return item, err
 }
 
-// Get index by "Id" field
+// Get index by "Id" field in Items.
+// In sharded mode Items stays empty, so the index is always -1.
 // return index number
 func (c *IdentifiableMemoryPersistence) GetIndexById(id interface{}) int {
 	var index int = -1
+	id = c.toKey(id)
 	for i, v := range c.Items {
-		vId := GetObjectId(v)
-		if CompareValues(vId, id) {
+		if c.hasId(v, id) {
 			index = i
 			break
 		}
@@ -181,18 +255,60 @@ func (c *IdentifiableMemoryPersistence) GetIndexById(id interface{}) int {
 // Returns:  interface{}, error
 // created item or error.
 func (c *IdentifiableMemoryPersistence) Create(correlationId string, item interface{}) (result interface{}, err error) {
-	c.Lock.Lock()
+	return c.CreateWithContext(ContextWithCorrelationId(context.Background(), correlationId), item)
+}
 
-	newItem := CloneObject(item, c.Prototype)
-	GenerateObjectId(&newItem)
-	id := GetObjectId(newItem)
-	c.Items = append(c.Items, newItem)
+// Creates a data item.
+// In tenant mode returns ConflictError when an item with the same id already exists in the tenant.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - item  string
+//   an item to be created.
+// Returns:  interface{}, error
+// created item or error.
+func (c *IdentifiableMemoryPersistence) CreateWithContext(ctx context.Context, item interface{}) (result interface{}, err error) {
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "create")
+	defer func() { timing.end(err) }()
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	var tenantId string
+	if tenantId, err = c.getTenantId(ctx); err != nil {
+		return nil, err
+	}
 
-	c.Lock.Unlock()
+	newItem := c.cloneItem(item)
+	if err = c.generateId(&newItem); err != nil {
+		return nil, err
+	}
+	c.stampTenant(&newItem, tenantId)
+	id := c.getId(newItem)
+	key := c.itemKey(newItem)
+
+	p := c.partition(key)
+	p.lock.Lock()
+
+	// In tenant mode ids are unique within the tenant
+	if tenantId != "" && id != nil && c.getTenantIndexById(*p.items, id, tenantId) >= 0 {
+		p.lock.Unlock()
+		return nil, errors.NewConflictError(correlationId, "ITEM_EXISTS", "Item "+toIdKey(id)+" already exists").
+			WithDetails("id", id)
+	}
+	c.appendItem(p.items, newItem)
+	c.recordHistory(correlationId, HistoryCreated, newItem)
+
+	p.lock.Unlock()
 	c.Logger.Trace(correlationId, "Created item %s", id)
+	evicted := c.evictItems(correlationId, key)
+	c.notifyEvicted(correlationId, evicted)
 
-	errsave := c.Save(correlationId)
-	result = CloneObjectForResult(newItem, c.Prototype)
+	errsave := c.SaveWithContext(ctx)
+	if errsave == nil {
+		errsave = c.saveHistory(correlationId)
+	}
+	result = c.cloneResult(newItem)
 
 	return result, errsave
 }
@@ -207,25 +323,61 @@ func (c *IdentifiableMemoryPersistence) Create(correlationId string, item interf
 // Returns:  interface{}, error
 // updated item or error.
 func (c *IdentifiableMemoryPersistence) Set(correlationId string, item interface{}) (result interface{}, err error) {
-	c.Lock.Lock()
+	return c.SetWithContext(ContextWithCorrelationId(context.Background(), correlationId), item)
+}
 
-	newItem := CloneObject(item, c.Prototype)
-	GenerateObjectId(&newItem)
+// Sets a data item. If the data item exists it updates it,
+// otherwise it create a new data item.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - item  interface{}
+//   a item to be set.
+// Returns:  interface{}, error
+// updated item or error.
+func (c *IdentifiableMemoryPersistence) SetWithContext(ctx context.Context, item interface{}) (result interface{}, err error) {
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "set")
+	defer func() { timing.end(err) }()
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	var tenantId string
+	if tenantId, err = c.getTenantId(ctx); err != nil {
+		return nil, err
+	}
 
-	id := GetObjectId(item)
-	index := c.GetIndexById(id)
+	newItem := c.cloneItem(item)
+	if err = c.generateId(&newItem); err != nil {
+		return nil, err
+	}
+	c.stampTenant(&newItem, tenantId)
+	id := c.getId(item)
+	key := c.itemKey(newItem)
+
+	p := c.partition(key)
+	p.lock.Lock()
+
+	index := c.getTenantIndexById(*p.items, c.getId(newItem), tenantId)
 	if index < 0 {
-		c.Items = append(c.Items, newItem)
+		c.appendItem(p.items, newItem)
+		c.recordHistory(correlationId, HistoryCreated, newItem)
 	} else {
-		c.Items[index] = newItem
+		c.replaceItem(p.items, index, newItem)
+		c.recordHistory(correlationId, HistoryUpdated, newItem)
 	}
 
-	c.Lock.Unlock()
+	p.lock.Unlock()
 	c.Logger.Trace(correlationId, "Set item %s", id)
+	evicted := c.evictItems(correlationId, key)
+	c.notifyEvicted(correlationId, evicted)
 
-	errsav := c.Save(correlationId)
+	errsav := c.SaveWithContext(ctx)
+	if errsav == nil {
+		errsav = c.saveHistory(correlationId)
+	}
 
-	result = CloneObjectForResult(newItem, c.Prototype)
+	result = c.cloneResult(newItem)
 	return result, errsav
 }
 
@@ -238,24 +390,56 @@ func (c *IdentifiableMemoryPersistence) Set(correlationId string, item interface
 // Returns:   interface{}, error
 // updated item or error.
 func (c *IdentifiableMemoryPersistence) Update(correlationId string, item interface{}) (result interface{}, err error) {
-	c.Lock.Lock()
+	return c.UpdateWithContext(ContextWithCorrelationId(context.Background(), correlationId), item)
+}
 
-	id := GetObjectId(item)
-	index := c.GetIndexById(id)
+// Updates a data item.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - item  interface{}
+//   an item to be updated.
+// Returns:   interface{}, error
+// updated item or error.
+func (c *IdentifiableMemoryPersistence) UpdateWithContext(ctx context.Context, item interface{}) (result interface{}, err error) {
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "update")
+	defer func() { timing.end(err) }()
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	var tenantId string
+	if tenantId, err = c.getTenantId(ctx); err != nil {
+		return nil, err
+	}
+
+	id := c.getId(item)
+	p := c.partition(c.tenantKey(tenantId, id))
+	p.lock.Lock()
+
+	index := c.getTenantIndexById(*p.items, id, tenantId)
 	if index < 0 {
 		c.Logger.Trace(correlationId, "Item %s was not found", id)
-		c.Lock.Unlock()
+		p.lock.Unlock()
+		if tenantId != "" {
+			return nil, newTenantItemNotFoundError(correlationId, id)
+		}
 		return nil, nil
 	}
-	newItem := CloneObject(item, c.Prototype)
-	c.Items[index] = newItem
+	newItem := c.cloneItem(item)
+	c.stampTenant(&newItem, tenantId)
+	c.replaceItem(p.items, index, newItem)
+	c.recordHistory(correlationId, HistoryUpdated, newItem)
 
-	c.Lock.Unlock()
+	p.lock.Unlock()
 	c.Logger.Trace(correlationId, "Updated item %s", id)
 
-	errsave := c.Save(correlationId)
+	errsave := c.SaveWithContext(ctx)
+	if errsave == nil {
+		errsave = c.saveHistory(correlationId)
+	}
 
-	result = CloneObjectForResult(newItem, c.Prototype)
+	result = c.cloneResult(newItem)
 	return result, errsave
 }
 
@@ -270,35 +454,58 @@ func (c *IdentifiableMemoryPersistence) Update(correlationId string, item interf
 // Returns: interface{}, error
 // updated item or error.
 func (c *IdentifiableMemoryPersistence) UpdatePartially(correlationId string, id interface{}, data *cdata.AnyValueMap) (result interface{}, err error) {
-	c.Lock.Lock()
+	return c.UpdatePartiallyWithContext(ContextWithCorrelationId(context.Background(), correlationId), id, data)
+}
 
-	index := c.GetIndexById(id)
-	if index < 0 {
-		c.Logger.Trace(correlationId, "Item %s was not found", id)
-		c.Lock.Unlock()
-		return nil, nil
+// Updates only few selected fields in a data item.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - id interface{}
+//   an id of data item to be updated.
+//   - data  cdata.AnyValueMap
+//   a map with fields to be updated.
+// Returns: interface{}, error
+// updated item or error.
+func (c *IdentifiableMemoryPersistence) UpdatePartiallyWithContext(ctx context.Context, id interface{}, data *cdata.AnyValueMap) (result interface{}, err error) {
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "update_partially")
+	defer func() { timing.end(err) }()
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	var tenantId string
+	if tenantId, err = c.getTenantId(ctx); err != nil {
+		return nil, err
 	}
 
-	newItem := CloneObject(c.Items[index], c.Prototype)
+	p := c.partition(c.tenantKey(tenantId, c.toKey(id)))
+	p.lock.Lock()
 
-	if reflect.ValueOf(newItem).Kind() == reflect.Map {
-		refl.ObjectWriter.SetProperties(newItem, data.Value())
-	} else {
-		objPointer := reflect.New(reflect.TypeOf(newItem))
-		objPointer.Elem().Set(reflect.ValueOf(newItem))
-		intPointer := objPointer.Interface()
-		refl.ObjectWriter.SetProperties(intPointer, data.Value())
-		newItem = reflect.ValueOf(intPointer).Elem().Interface()
+	index := c.getTenantIndexById(*p.items, id, tenantId)
+	if index < 0 {
+		c.Logger.Trace(correlationId, "Item %s was not found", id)
+		p.lock.Unlock()
+		if tenantId != "" {
+			return nil, newTenantItemNotFoundError(correlationId, id)
+		}
+		return nil, nil
 	}
 
-	c.Items[index] = newItem
+	newItem := c.applyPartialUpdate((*p.items)[index], data)
+	c.stampTenant(&newItem, tenantId)
+	c.replaceItem(p.items, index, newItem)
+	c.recordHistory(correlationId, HistoryUpdated, newItem)
 
-	c.Lock.Unlock()
+	p.lock.Unlock()
 	c.Logger.Trace(correlationId, "Partially updated item %s", id)
 
-	errsave := c.Save(correlationId)
+	errsave := c.SaveWithContext(ctx)
+	if errsave == nil {
+		errsave = c.saveHistory(correlationId)
+	}
 
-	result = CloneObjectForResult(newItem, c.Prototype)
+	result = c.cloneResult(newItem)
 	return result, errsave
 }
 
@@ -311,29 +518,70 @@ func (c *IdentifiableMemoryPersistence) UpdatePartially(correlationId string, id
 // Retruns:  interface{}, error
 // deleted item or error.
 func (c *IdentifiableMemoryPersistence) DeleteById(correlationId string, id interface{}) (result interface{}, err error) {
-	c.Lock.Lock()
+	return c.DeleteByIdWithContext(ContextWithCorrelationId(context.Background(), correlationId), id)
+}
+
+// Deleted a data item by it's unique id.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - id interface{}
+//   an id of the item to be deleted
+// Retruns:  interface{}, error
+// deleted item or error.
+func (c *IdentifiableMemoryPersistence) DeleteByIdWithContext(ctx context.Context, id interface{}) (result interface{}, err error) {
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "delete_by_id")
+	defer func() { timing.end(err) }()
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	var tenantId string
+	if tenantId, err = c.getTenantId(ctx); err != nil {
+		return nil, err
+	}
+	id = c.toKey(id)
 
-	index := c.GetIndexById(id)
+	p := c.partition(c.tenantKey(tenantId, id))
+	var unlock func()
+	if c.hasSelfRestrictions() {
+		// Restrictions of relations to the persistence itself count items of all partitions
+		unlock = c.lockAll()
+	} else {
+		p.lock.Lock()
+		unlock = p.lock.Unlock
+	}
+
+	index := c.getTenantIndexById(*p.items, id, tenantId)
 	if index < 0 {
 		c.Logger.Trace(correlationId, "Item %s was not found", id)
-		c.Lock.Unlock()
+		unlock()
+		if tenantId != "" {
+			return nil, newTenantItemNotFoundError(correlationId, id)
+		}
 		return nil, nil
 	}
 
-	oldItem := c.Items[index]
-
-	if index == len(c.Items) {
-		c.Items = c.Items[:index-1]
-	} else {
-		c.Items = append(c.Items[:index], c.Items[index+1:]...)
+	// Restrictions are checked under the lock, so the item cannot change before it is removed
+	if err = c.checkDeleteRestrictions(ctx, []interface{}{(*p.items)[index]}); err != nil {
+		unlock()
+		return nil, err
 	}
+	oldItem := c.removeItem(p.items, index)
+	c.recordHistory(correlationId, HistoryDeleted, oldItem)
 
-	c.Lock.Unlock()
+	unlock()
 	c.Logger.Trace(correlationId, "Deleted item by %s", id)
 
-	errsave := c.Save(correlationId)
+	errsave := c.SaveWithContext(ctx)
+	if errsave == nil {
+		errsave = c.saveHistory(correlationId)
+	}
+	if errsave == nil {
+		errsave = c.cascadeDelete(ctx, []interface{}{oldItem})
+	}
 	//result = CloneObject(oldItem)
-	result = CloneObjectForResult(oldItem, c.Prototype)
+	result = c.cloneResult(oldItem)
 	return result, errsave
 }
 
@@ -346,11 +594,22 @@ func (c *IdentifiableMemoryPersistence) DeleteById(correlationId string, id inte
 // Returns: error
 // error or null for success.
 func (c *IdentifiableMemoryPersistence) DeleteByIds(correlationId string, ids []interface{}) (err error) {
+	return c.DeleteByIdsWithContext(ContextWithCorrelationId(context.Background(), correlationId), ids)
+}
+
+// Deletes multiple data items by their unique ids.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - ids []interface{}
+//   ids of data items to be deleted.
+// Returns: error
+// error or null for success.
+func (c *IdentifiableMemoryPersistence) DeleteByIdsWithContext(ctx context.Context, ids []interface{}) (err error) {
 	filterFunc := func(item interface{}) bool {
 		exist := false
-		itemId := GetObjectId(item)
 		for _, v := range ids {
-			if CompareValues(v, itemId) {
+			if c.hasId(item, c.toKey(v)) {
 				exist = true
 				break
 			}
@@ -358,5 +617,21 @@ func (c *IdentifiableMemoryPersistence) DeleteByIds(correlationId string, ids []
 		return exist
 	}
 
-	return c.DeleteByFilter(correlationId, filterFunc)
+	return c.DeleteByFilterWithContext(ctx, filterFunc)
+}
+
+// Creates a copy of the item with fields updated from the map
+func (c *IdentifiableMemoryPersistence) applyPartialUpdate(item interface{}, data *cdata.AnyValueMap) interface{} {
+	newItem := CloneObject(item, c.Prototype)
+
+	if reflect.ValueOf(newItem).Kind() == reflect.Map {
+		setProperties(newItem, data.Value(), c.PropertyTag)
+	} else {
+		objPointer := reflect.New(reflect.TypeOf(newItem))
+		objPointer.Elem().Set(reflect.ValueOf(newItem))
+		intPointer := objPointer.Interface()
+		setProperties(intPointer, data.Value(), c.PropertyTag)
+		newItem = reflect.ValueOf(intPointer).Elem().Interface()
+	}
+	return newItem
 }
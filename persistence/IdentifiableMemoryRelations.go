@@ -0,0 +1,405 @@
+package persistence
+
+import (
+	"context"
+
+	cdata "github.com/pip-services3-go/pip-services3-commons-go/data"
+	"github.com/pip-services3-go/pip-services3-commons-go/errors"
+)
+
+// Kinds of relations between persistences
+const (
+	// Local item references a single target item by its id stored in LocalField
+	RelationOne = "one"
+	// Local item is referenced by many target items that store its key in ForeignField
+	RelationMany = "many"
+)
+
+// Actions performed on related items when a local item is deleted
+const (
+	// Related items are left as they are
+	OnDeleteNone = ""
+	// Related items are deleted together with the local item
+	OnDeleteCascade = "cascade"
+	// Local item cannot be deleted while related items exist
+	OnDeleteRestrict = "restrict"
+)
+
+/*
+Relation from items of one IdentifiableMemoryPersistence to items of another one.
+Relations are declared with AddRelation and resolved by GetOneByIdWithIncludes
+and GetPageByFilterWithIncludes.
+
+Example
+
+    // Each dummy belongs to an owner
+    dummies.AddRelation(&Relation{
+        Name: "owner", Kind: RelationOne, Target: &owners.IdentifiableMemoryPersistence,
+        LocalField: "owner_id",
+    })
+    // Each owner has many dummies, that are deleted together with the owner
+    owners.AddRelation(&Relation{
+        Name: "dummies", Kind: RelationMany, Target: &dummies.IdentifiableMemoryPersistence,
+        ForeignField: "owner_id", OnDelete: OnDeleteCascade,
+    })
+*/
+type Relation struct {
+	// Name of the relation used in includes
+	Name string
+	// Kind of the relation: RelationOne or RelationMany
+	Kind string
+	// Persistence with related items
+	Target *IdentifiableMemoryPersistence
+	// For one relation: name of the local property with id of the target item.
+	// For many relation: name of the local property with the key, id by default.
+	LocalField string
+	// For many relation: name of the target property with the key of the local item
+	ForeignField string
+	// Action on related items when a local item is deleted: none, cascade or restrict
+	OnDelete string
+	owner    *IdentifiableMemoryPersistence
+}
+
+/*
+Data item with related items returned by GetOneByIdWithIncludes and GetPageByFilterWithIncludes.
+*/
+type IncludedItem struct {
+	// The data item
+	Item interface{} `json:"item"`
+	// Related items by relation names: a single item for one relation
+	// (nil when it is not found) and a list of items for many relation
+	Includes map[string]interface{} `json:"includes"`
+}
+
+/*
+Page of data items with related items returned by GetPageByFilterWithIncludes.
+*/
+type IncludedPage struct {
+	// Total number of items when it was requested
+	Total *int64 `json:"total"`
+	// Items of the page
+	Data []*IncludedItem `json:"data"`
+}
+
+// Declares a relation to items of another persistence.
+// Relations shall be declared before the persistence is used.
+// Parameters:
+//   - relation *Relation
+//   a relation to declare.
+// Returns error
+// error when the relation is invalid or nil for success.
+func (c *IdentifiableMemoryPersistence) AddRelation(relation *Relation) error {
+	var err error
+	switch {
+	case relation == nil || relation.Name == "":
+		err = errors.NewConfigError("", "NO_RELATION_NAME", "Relation name is not set")
+	case relation.Target == nil:
+		err = errors.NewConfigError("", "NO_RELATION_TARGET", "Relation target is not set")
+	case relation.Kind == RelationOne && relation.LocalField == "":
+		err = errors.NewConfigError("", "NO_LOCAL_FIELD", "Local field is not set for one relation")
+	case relation.Kind == RelationMany && relation.ForeignField == "":
+		err = errors.NewConfigError("", "NO_FOREIGN_FIELD", "Foreign field is not set for many relation")
+	case relation.Kind != RelationOne && relation.Kind != RelationMany:
+		err = errors.NewConfigError("", "INVALID_RELATION_KIND", "Relation kind "+relation.Kind+" is not supported")
+	case relation.OnDelete != OnDeleteNone && relation.OnDelete != OnDeleteCascade && relation.OnDelete != OnDeleteRestrict:
+		err = errors.NewConfigError("", "INVALID_ON_DELETE", "On delete action "+relation.OnDelete+" is not supported")
+	case c.getRelation(relation.Name) != nil:
+		err = errors.NewConfigError("", "DUPLICATE_RELATION", "Relation "+relation.Name+" is already declared")
+	}
+	if err != nil {
+		if relation != nil {
+			err.(*errors.ApplicationError).WithDetails("relation", relation.Name)
+		}
+		return err
+	}
+
+	relation.owner = c
+	c.relations = append(c.relations, relation)
+	return nil
+}
+
+// Gets a declared relation by its name.
+// Parameters:
+//   - name string
+//   a name of the relation.
+// Returns *Relation
+// the relation or nil if it is not declared.
+func (c *IdentifiableMemoryPersistence) getRelation(name string) *Relation {
+	for _, relation := range c.relations {
+		if relation.Name == name {
+			return relation
+		}
+	}
+	return nil
+}
+
+// Gets a data item by its unique id together with related items.
+// Parameters:
+//   - correlationId  string
+//   (optional) transaction id to trace execution through call chain.
+//   - id interface{}
+//   an id of data item to be retrieved.
+//   - includes []string
+//   names of relations to include.
+// Returns:  *IncludedItem, error
+// data item with related items, nil when the item is not found, or error.
+func (c *IdentifiableMemoryPersistence) GetOneByIdWithIncludes(correlationId string, id interface{},
+	includes []string) (result *IncludedItem, err error) {
+	return c.GetOneByIdWithIncludesWithContext(ContextWithCorrelationId(context.Background(), correlationId), id, includes)
+}
+
+// Gets a data item by its unique id together with related items.
+// The context is passed to related persistences, so they stop when it is cancelled.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - id interface{}
+//   an id of data item to be retrieved.
+//   - includes []string
+//   names of relations to include.
+// Returns:  *IncludedItem, error
+// data item with related items, nil when the item is not found, or error.
+func (c *IdentifiableMemoryPersistence) GetOneByIdWithIncludesWithContext(ctx context.Context, id interface{},
+	includes []string) (result *IncludedItem, err error) {
+
+	item, err := c.GetOneByIdWithContext(ctx, id)
+	if err != nil || item == nil {
+		return nil, err
+	}
+
+	results, err := c.resolveIncludes(ctx, []interface{}{item}, includes)
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+// Gets a page of data items retrieved by a given filter together with related items.
+// Related items are retrieved with a single query to each related persistence.
+// Parameters:
+//   - correlationId string
+//   (optional) transaction id to trace execution through call chain.
+//   - filterFunc func(interface{}) bool
+//   (optional) a filter function to filter items
+//   - paging *cdata.PagingParams
+//   (optional) paging parameters
+//   - sortFunc func(a, b interface{}) bool
+//   (optional) sorting compare function
+//   - includes []string
+//   names of relations to include.
+// Returns *IncludedPage, error
+// data page or error.
+func (c *IdentifiableMemoryPersistence) GetPageByFilterWithIncludes(correlationId string, filterFunc func(interface{}) bool,
+	paging *cdata.PagingParams, sortFunc func(a, b interface{}) bool, includes []string) (page *IncludedPage, err error) {
+	return c.GetPageByFilterWithIncludesWithContext(ContextWithCorrelationId(context.Background(), correlationId),
+		filterFunc, paging, sortFunc, includes)
+}
+
+// Gets a page of data items retrieved by a given filter together with related items.
+// The context is passed to related persistences, so they stop when it is cancelled.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - filterFunc func(interface{}) bool
+//   (optional) a filter function to filter items
+//   - paging *cdata.PagingParams
+//   (optional) paging parameters
+//   - sortFunc func(a, b interface{}) bool
+//   (optional) sorting compare function
+//   - includes []string
+//   names of relations to include.
+// Returns *IncludedPage, error
+// data page or error.
+func (c *IdentifiableMemoryPersistence) GetPageByFilterWithIncludesWithContext(ctx context.Context, filterFunc func(interface{}) bool,
+	paging *cdata.PagingParams, sortFunc func(a, b interface{}) bool, includes []string) (page *IncludedPage, err error) {
+
+	dataPage, err := c.GetPageByFilterWithContext(ctx, filterFunc, paging, sortFunc, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := c.resolveIncludes(ctx, dataPage.Data, includes)
+	if err != nil {
+		return nil, err
+	}
+	return &IncludedPage{Total: dataPage.Total, Data: data}, nil
+}
+
+// Retrieves related items for a list of items
+func (c *IdentifiableMemoryPersistence) resolveIncludes(ctx context.Context, items []interface{},
+	includes []string) ([]*IncludedItem, error) {
+
+	results := make([]*IncludedItem, len(items))
+	for i, item := range items {
+		results[i] = &IncludedItem{Item: item, Includes: make(map[string]interface{}, len(includes))}
+	}
+
+	for _, name := range includes {
+		relation := c.getRelation(name)
+		if relation == nil {
+			return nil, errors.NewBadRequestError(CorrelationIdFromContext(ctx), "UNKNOWN_RELATION",
+				"Relation "+name+" is not declared").WithDetails("relation", name)
+		}
+
+		related, err := relation.getRelated(ctx, items)
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range results {
+			key := relation.localKey(result.Item)
+			if relation.Kind == RelationOne {
+				if values := related[key]; len(values) > 0 {
+					result.Includes[name] = values[0]
+				} else {
+					result.Includes[name] = nil
+				}
+			} else {
+				values := related[key]
+				if values == nil {
+					values = make([]interface{}, 0)
+				}
+				result.Includes[name] = values
+			}
+		}
+	}
+	return results, nil
+}
+
+// Gets the key of a local item that relates it to target items
+func (c *Relation) localKey(item interface{}) string {
+	if c.LocalField == "" {
+		return toIdKey(c.owner.getId(item))
+	}
+	return toIdKey(c.owner.getProperty(item, c.LocalField))
+}
+
+// Gets the key of a target item that relates it to local items
+func (c *Relation) targetKey(item interface{}) string {
+	if c.Kind == RelationOne {
+		return toIdKey(c.Target.getId(item))
+	}
+	return toIdKey(c.Target.getProperty(item, c.ForeignField))
+}
+
+// Creates a filter for target items related to the local items
+func (c *Relation) targetFilter(items []interface{}) func(interface{}) bool {
+	keys := make(map[string]bool, len(items))
+	for _, item := range items {
+		if key := c.localKey(item); key != "" {
+			keys[key] = true
+		}
+	}
+	return func(item interface{}) bool {
+		return keys[c.targetKey(item)]
+	}
+}
+
+// Retrieves target items related to the local items grouped by their keys
+func (c *Relation) getRelated(ctx context.Context, items []interface{}) (map[string][]interface{}, error) {
+	related, err := c.Target.GetListByFilterWithContext(ctx, c.targetFilter(items), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]interface{})
+	for _, item := range related {
+		key := c.targetKey(item)
+		result[key] = append(result[key], item)
+	}
+	return result, nil
+}
+
+// Checks if any relation has an action on delete
+func (c *IdentifiableMemoryPersistence) hasDeleteActions() bool {
+	for _, relation := range c.relations {
+		if relation.OnDelete != OnDeleteNone {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks if a restricting relation points to the persistence itself,
+// so checks of restrictions count items of all partitions.
+func (c *IdentifiableMemoryPersistence) hasSelfRestrictions() bool {
+	for _, relation := range c.relations {
+		if relation.OnDelete == OnDeleteRestrict && relation.Target == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks that items can be deleted and returns ConflictError
+// when a restricting relation has related items.
+// The method shall be called under write lock, so the items cannot change
+// before they are removed. Target persistences are locked for reading,
+// so restricting relations between persistences shall not form cycles.
+func (c *IdentifiableMemoryPersistence) checkDeleteRestrictions(ctx context.Context, items []interface{}) error {
+	for _, relation := range c.relations {
+		if relation.OnDelete != OnDeleteRestrict {
+			continue
+		}
+
+		var count int64
+		var err error
+		if relation.Target == c {
+			count, err = c.countRelatedUnlocked(ctx, relation, items)
+		} else {
+			count, err = relation.Target.GetCountByFilterWithContext(ctx, relation.targetFilter(items))
+		}
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.NewConflictError(CorrelationIdFromContext(ctx), "RELATED_ITEMS_EXIST",
+				"Items cannot be deleted while related "+relation.Name+" exist").
+				WithDetails("relation", relation.Name).WithDetails("count", count)
+		}
+	}
+	return nil
+}
+
+// Counts items of the persistence related to the items through a relation to itself.
+// Related items that are deleted together with the items are not counted.
+// The method shall be called under lock of all partitions.
+func (c *IdentifiableMemoryPersistence) countRelatedUnlocked(ctx context.Context, relation *Relation,
+	items []interface{}) (int64, error) {
+
+	deleted := make(map[string]bool, len(items))
+	for _, item := range items {
+		deleted[c.itemKey(item)] = true
+	}
+	filterFunc, err := c.tenantFilter(ctx, relation.targetFilter(items))
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	for i, item := range c.storedItems() {
+		if err := checkContext(ctx, i); err != nil {
+			return 0, err
+		}
+		if filterFunc(item) && !deleted[c.itemKey(item)] {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Deletes target items related to deleted items through cascading relations
+func (c *IdentifiableMemoryPersistence) cascadeDelete(ctx context.Context, deleted []interface{}) error {
+	if len(deleted) == 0 {
+		return nil
+	}
+
+	for _, relation := range c.relations {
+		if relation.OnDelete != OnDeleteCascade {
+			continue
+		}
+		if err := relation.Target.DeleteByFilterWithContext(ctx, relation.targetFilter(deleted)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
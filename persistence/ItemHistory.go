@@ -0,0 +1,215 @@
+package persistence
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pip-services3-go/pip-services3-commons-go/convert"
+)
+
+// Operations recorded in the history of identifiable items
+const (
+	HistoryCreated = "create"
+	HistoryUpdated = "update"
+	HistoryDeleted = "delete"
+	HistoryEvicted = "evict"
+)
+
+/*
+Version of an identifiable item recorded by IdentifiableMemoryPersistence
+when history mode is enabled.
+
+Each entry keeps the state of the item right after the operation.
+Entries for deleted and evicted items keep the last state of the item before removal.
+In tenant mode entries keep the tenant id, since items of different tenants may have the same id.
+*/
+type HistoryEntry struct {
+	Id            interface{} `json:"id"`
+	TenantId      string      `json:"tenant_id,omitempty"`
+	Time          time.Time   `json:"time"`
+	CorrelationId string      `json:"correlation_id"`
+	Operation     string      `json:"operation"`
+	Item          interface{} `json:"item"`
+}
+
+/*
+Helper struct that keeps versions of identifiable items ordered by time.
+Versions are grouped by keys of item ids within tenants.
+Versions are recorded under the write lock of the persistence, so they follow
+the order of changes. The history has its own lock for reads and saves
+that run without the write lock.
+*/
+type itemHistory struct {
+	lock    sync.RWMutex
+	entries map[string][]*HistoryEntry
+	// Versions recorded after the last save
+	pending []*HistoryEntry
+	// Set when all versions shall be saved instead of pending ones
+	rewrite bool
+}
+
+func newItemHistory() *itemHistory {
+	return &itemHistory{
+		entries: make(map[string][]*HistoryEntry),
+	}
+}
+
+// Adds a new version of the item and drops the oldest versions above the limit.
+// Zero maxVersions means unlimited number of versions.
+func (c *itemHistory) record(key string, entry *HistoryEntry, maxVersions int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	versions := append(c.entries[key], entry)
+	if maxVersions > 0 && len(versions) > maxVersions {
+		versions = versions[len(versions)-maxVersions:]
+	}
+	c.entries[key] = versions
+	c.pending = append(c.pending, entry)
+}
+
+// Gets all versions of the item ordered from the oldest to the newest
+func (c *itemHistory) get(key string) []*HistoryEntry {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	versions := c.entries[key]
+	result := make([]*HistoryEntry, len(versions))
+	copy(result, versions)
+	return result
+}
+
+// Gets the latest version of the item recorded not later than specified time
+func (c *itemHistory) asOf(key string, time time.Time) *HistoryEntry {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	var result *HistoryEntry
+	for _, v := range c.entries[key] {
+		if v.Time.After(time) {
+			break
+		}
+		result = v
+	}
+	return result
+}
+
+// Takes versions recorded after the last save.
+// All versions are taken instead when they were replaced after the last save
+// or rewrite is set, for instance for savers that cannot append.
+// Returns taken versions and true when they are all versions.
+func (c *itemHistory) takePending(rewrite bool) ([]interface{}, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	rewrite = rewrite || c.rewrite
+	if !rewrite && len(c.pending) == 0 {
+		return nil, false
+	}
+
+	var result []interface{}
+	if rewrite {
+		result = c.list()
+	} else {
+		result = make([]interface{}, len(c.pending))
+		for i, v := range c.pending {
+			result[i] = v
+		}
+	}
+	c.pending = nil
+	c.rewrite = false
+	return result, rewrite
+}
+
+// Forgets versions recorded after the last save when there is nowhere to save them
+func (c *itemHistory) clearPending() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.pending = nil
+	c.rewrite = false
+}
+
+// Returns versions taken by takePending back when they failed to save
+func (c *itemHistory) restorePending(entries []interface{}, all bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if all {
+		c.rewrite = true
+		return
+	}
+
+	pending := make([]*HistoryEntry, 0, len(entries)+len(c.pending))
+	for _, v := range entries {
+		pending = append(pending, v.(*HistoryEntry))
+	}
+	c.pending = append(pending, c.pending...)
+}
+
+// Gets all versions of all items ordered by time.
+// The method shall be called under lock.
+func (c *itemHistory) list() []interface{} {
+	entries := make([]*HistoryEntry, 0)
+	for _, versions := range c.entries {
+		entries = append(entries, versions...)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+
+	result := make([]interface{}, len(entries))
+	for i, v := range entries {
+		result[i] = v
+	}
+	return result
+}
+
+// Replaces all versions with the loaded ones and drops the oldest versions above the limit.
+// All versions are saved on the next save, so the saved history is compacted.
+func (c *itemHistory) replace(entries []*HistoryEntry, getKey func(entry *HistoryEntry) string, maxVersions int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.entries = make(map[string][]*HistoryEntry)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	for _, v := range entries {
+		key := getKey(v)
+		versions := append(c.entries[key], v)
+		if maxVersions > 0 && len(versions) > maxVersions {
+			versions = versions[len(versions)-maxVersions:]
+		}
+		c.entries[key] = versions
+	}
+	c.pending = nil
+	c.rewrite = true
+}
+
+// Converts history entries received from a loader.
+// Loaded entries are expected to be maps decoded from JSON.
+func toHistoryEntries(values []interface{}, prototype reflect.Type) []*HistoryEntry {
+	entries := make([]*HistoryEntry, 0, len(values))
+	for _, v := range values {
+		value := convert.MapConverter.ToNullableMap(v)
+		if value == nil {
+			continue
+		}
+		m := *value
+		entry := &HistoryEntry{
+			Id:            m["id"],
+			TenantId:      convert.StringConverter.ToString(m["tenant_id"]),
+			Time:          convert.DateTimeConverter.ToDateTime(m["time"]),
+			CorrelationId: convert.StringConverter.ToString(m["correlation_id"]),
+			Operation:     convert.StringConverter.ToString(m["operation"]),
+		}
+		if m["item"] != nil {
+			entry.Item = convertToPrototype([]interface{}{m["item"]}, prototype)[0]
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
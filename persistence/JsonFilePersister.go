@@ -1,13 +1,18 @@
 package persistence
 
 import (
+	"context"
 	"io/ioutil"
 	"os"
 	"reflect"
+	"sync"
+	"time"
 
 	"github.com/pip-services3-go/pip-services3-commons-go/config"
 	"github.com/pip-services3-go/pip-services3-commons-go/convert"
 	"github.com/pip-services3-go/pip-services3-commons-go/errors"
+	"github.com/pip-services3-go/pip-services3-commons-go/refer"
+	"github.com/pip-services3-go/pip-services3-components-go/trace"
 )
 
 /*
@@ -19,6 +24,10 @@ It is used by FilePersistence, but can be useful on its own.
 
   - path:          path to the file where data is stored
 
+ References
+
+  - *:tracer:*:*:1.0    (optional) ITracer components to record load and save traces
+
  Example
 
   persister := NewJsonFilePersister(reflect.TypeOf(MyData{}), "./data/data.json");
@@ -30,10 +39,17 @@ It is used by FilePersistence, but can be useful on its own.
   		fmt.Println(items);// Result: ["A", "B", "C"]
   	}
 */
-// implements ILoader, ISaver, IConfigurable
+// implements ILoader, ISaver, ILoaderWithContext, ISaverWithContext, IConfigurable, IReferenceable
 type JsonFilePersister struct {
 	path      string
 	Prototype reflect.Type
+	// Tracer to record load and save traces
+	Tracer *trace.CompositeTracer
+
+	statusLock   sync.Mutex
+	lastLoadTime time.Time
+	lastSaveTime time.Time
+	lastError    error
 }
 
 // Creates a new instance of the persistence.
@@ -42,6 +58,7 @@ type JsonFilePersister struct {
 //  (optional) a path to the file where data is stored.
 func NewJsonFilePersister(prototype reflect.Type, path string) *JsonFilePersister {
 	var c = &JsonFilePersister{path: path, Prototype: prototype}
+	c.Tracer = trace.NewCompositeTracer(nil)
 	return c
 }
 
@@ -67,6 +84,57 @@ func (c *JsonFilePersister) Configure(config *config.ConfigParams) {
 	c.path = config.GetAsStringWithDefault("path", c.path)
 }
 
+// Sets references to dependent components.
+// Parameters:
+//  - references refer.IReferences
+//  references to locate the component dependencies.
+func (c *JsonFilePersister) SetReferences(references refer.IReferences) {
+	c.Tracer.SetReferences(references)
+}
+
+// Gets the time when data was successfully loaded last time.
+// Returns time.Time
+// the time of the last load or zero time if data was never loaded.
+func (c *JsonFilePersister) LastLoadTime() time.Time {
+	c.statusLock.Lock()
+	defer c.statusLock.Unlock()
+	return c.lastLoadTime
+}
+
+// Gets the time when data was successfully saved last time.
+// Returns time.Time
+// the time of the last save or zero time if data was never saved.
+func (c *JsonFilePersister) LastSaveTime() time.Time {
+	c.statusLock.Lock()
+	defer c.statusLock.Unlock()
+	return c.lastSaveTime
+}
+
+// Gets the error of the last load or save operation.
+// Returns error
+// the error or nil if the last operation succeeded.
+func (c *JsonFilePersister) LastError() error {
+	c.statusLock.Lock()
+	defer c.statusLock.Unlock()
+	return c.lastError
+}
+
+// Ends the trace of a load or save operation and records its result
+func (c *JsonFilePersister) endOperation(timing *trace.TraceTiming, lastTime *time.Time, err error) {
+	c.statusLock.Lock()
+	c.lastError = err
+	if err == nil {
+		*lastTime = time.Now()
+	}
+	c.statusLock.Unlock()
+
+	if err != nil {
+		timing.EndFailure(err)
+	} else {
+		timing.EndTrace()
+	}
+}
+
 // Loads data items from external JSON file.
 // Parameters:
 //  - correlation_id  string
@@ -74,6 +142,23 @@ func (c *JsonFilePersister) Configure(config *config.ConfigParams) {
 // Returns []interface{}, error
 // loaded items or error.
 func (c *JsonFilePersister) Load(correlation_id string) (data []interface{}, err error) {
+	return c.LoadWithContext(ContextWithCorrelationId(context.Background(), correlation_id))
+}
+
+// Loads data items from external JSON file.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+// Returns []interface{}, error
+// loaded items or error.
+func (c *JsonFilePersister) LoadWithContext(ctx context.Context) (data []interface{}, err error) {
+	correlation_id := CorrelationIdFromContext(ctx)
+	timing := c.Tracer.BeginTrace(correlation_id, "persister.json", "load")
+	defer func() { c.endOperation(timing, &c.lastLoadTime, err) }()
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	if c.path == "" {
 		data = nil
 		err = errors.NewConfigError("", "NO_PATH", "Data file path is not set")
@@ -117,11 +202,34 @@ func (c *JsonFilePersister) Load(correlation_id string) (data []interface{}, err
 //  Retruns error
 //  error or nil for success.
 func (c *JsonFilePersister) Save(correlationId string, items []interface{}) error {
+	return c.SaveWithContext(ContextWithCorrelationId(context.Background(), correlationId), items)
+}
+
+// Saves given data items to external JSON file.
+// The file is not written when the context is cancelled while items are converted.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - items []interface[]
+//   list of data items to save
+//  Retruns error
+//  error or nil for success.
+func (c *JsonFilePersister) SaveWithContext(ctx context.Context, items []interface{}) (err error) {
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.Tracer.BeginTrace(correlationId, "persister.json", "save")
+	defer func() { c.endOperation(timing, &c.lastSaveTime, err) }()
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
 	json, jsonerr := convert.ToJson(items)
 	if jsonerr != nil {
 		err := errors.NewInternalError(correlationId, "CAN'T_CONVERT", "Failed convert to JSON")
 		return err
 	}
+	if err = ctx.Err(); err != nil {
+		return err
+	}
 	werr := ioutil.WriteFile(c.path, ([]byte)(json), 0777)
 	if werr != nil {
 		err := errors.NewFileError(correlationId, "WRITE_FAILED", "Failed to write data file: "+c.path).WithCause(werr)
@@ -0,0 +1,248 @@
+package persistence
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"reflect"
+
+	"github.com/pip-services3-go/pip-services3-commons-go/config"
+	"github.com/pip-services3-go/pip-services3-commons-go/convert"
+	"github.com/pip-services3-go/pip-services3-commons-go/errors"
+	"github.com/pip-services3-go/pip-services3-commons-go/refer"
+	"github.com/pip-services3-go/pip-services3-components-go/trace"
+)
+
+/*
+Persistence component that loads and saves data from/to flat file
+with one JSON object per line.
+
+Unlike JsonFilePersister it can append new items to the end of the file
+without rewriting items that were saved before, so it is used to store
+history of IdentifiableMemoryPersistence. Files with a JSON array written
+by JsonFilePersister are loaded as well.
+
+ Configuration parameters
+
+  - path:          path to the file where data is stored
+
+ References
+
+  - *:tracer:*:*:1.0    (optional) ITracer components to record load and save traces
+
+ Example
+
+  persister := NewJsonLinesFilePersister(reflect.TypeOf(MyData{}), "./data/history.jsonl")
+
+  err := persister.Append("123", []interface{}{MyData{Id: "1"}})
+  err = persister.Append("123", []interface{}{MyData{Id: "2"}})
+  items, err := persister.Load("123")
+  fmt.Println(len(items)) // Result: 2
+*/
+// implements ILoader, ISaver, IAppender, ILoaderWithContext, ISaverWithContext, IConfigurable, IReferenceable
+type JsonLinesFilePersister struct {
+	path      string
+	Prototype reflect.Type
+	// Tracer to record load and save traces
+	Tracer *trace.CompositeTracer
+}
+
+// Creates a new instance of the persister.
+// Parameters:
+//  - prototype reflect.Type
+//  type of contained data
+//  - path string
+//  (optional) a path to the file where data is stored.
+func NewJsonLinesFilePersister(prototype reflect.Type, path string) *JsonLinesFilePersister {
+	c := &JsonLinesFilePersister{path: path, Prototype: prototype}
+	c.Tracer = trace.NewCompositeTracer(nil)
+	return c
+}
+
+// Gets the file path where data is stored.
+// Returns the file path where data is stored.
+func (c *JsonLinesFilePersister) Path() string {
+	return c.path
+}
+
+// Configures component by passing configuration parameters.
+// Parameters:
+//  - config  config.ConfigParams
+//  parameters to be set.
+func (c *JsonLinesFilePersister) Configure(config *config.ConfigParams) {
+	c.path = config.GetAsStringWithDefault("path", c.path)
+}
+
+// Sets references to dependent components.
+// Parameters:
+//  - references refer.IReferences
+//  references to locate the component dependencies.
+func (c *JsonLinesFilePersister) SetReferences(references refer.IReferences) {
+	c.Tracer.SetReferences(references)
+}
+
+// Ends the trace of a load or save operation
+func (c *JsonLinesFilePersister) endOperation(timing *trace.TraceTiming, err error) {
+	if err != nil {
+		timing.EndFailure(err)
+	} else {
+		timing.EndTrace()
+	}
+}
+
+// Converts data items into lines of JSON
+func (c *JsonLinesFilePersister) toLines(correlationId string, items []interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	for _, item := range items {
+		json, err := convert.ToJson(item)
+		if err != nil {
+			return nil, errors.NewInternalError(correlationId, "CAN'T_CONVERT", "Failed convert to JSON").WithCause(err)
+		}
+		buffer.WriteString(json)
+		buffer.WriteByte('\n')
+	}
+	return buffer.Bytes(), nil
+}
+
+// Loads data items from external JSON file.
+// Parameters:
+//  - correlationId  string
+//  transaction id to trace execution through call chain.
+// Returns []interface{}, error
+// loaded items or error.
+func (c *JsonLinesFilePersister) Load(correlationId string) (data []interface{}, err error) {
+	return c.LoadWithContext(ContextWithCorrelationId(context.Background(), correlationId))
+}
+
+// Loads data items from external JSON file.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+// Returns []interface{}, error
+// loaded items or error.
+func (c *JsonLinesFilePersister) LoadWithContext(ctx context.Context) (data []interface{}, err error) {
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.Tracer.BeginTrace(correlationId, "persister.json_lines", "load")
+	defer func() { c.endOperation(timing, err) }()
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if c.path == "" {
+		return nil, errors.NewConfigError(correlationId, "NO_PATH", "Data file path is not set")
+	}
+
+	content, readErr := ioutil.ReadFile(c.path)
+	if os.IsNotExist(readErr) {
+		return nil, nil
+	}
+	if readErr != nil {
+		return nil, errors.NewFileError(correlationId, "READ_FAILED", "Failed to read data file: "+c.path).WithCause(readErr)
+	}
+
+	// Files written by JsonFilePersister keep all items in a single array
+	content = bytes.TrimSpace(content)
+	if len(content) > 0 && content[0] == '[' {
+		list, err := convert.FromJson(string(content))
+		if err != nil || list == nil {
+			return nil, err
+		}
+		return convert.ArrayConverter.ListToArray(list), nil
+	}
+
+	data = make([]interface{}, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(content)+1)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		item, err := convert.FromJson(string(line))
+		if err != nil {
+			return nil, errors.NewFileError(correlationId, "READ_FAILED", "Failed to parse data file: "+c.path).WithCause(err)
+		}
+		data = append(data, item)
+	}
+	return data, nil
+}
+
+// Saves given data items to external JSON file replacing all items saved before.
+// Parameters:
+//   - correlationId string
+//   transaction id to trace execution through call chain.
+//   - items []interface[]
+//   list of data items to save
+// Retruns error
+// error or nil for success.
+func (c *JsonLinesFilePersister) Save(correlationId string, items []interface{}) error {
+	return c.SaveWithContext(ContextWithCorrelationId(context.Background(), correlationId), items)
+}
+
+// Saves given data items to external JSON file replacing all items saved before.
+// The file is not written when the context is cancelled while items are converted.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - items []interface[]
+//   list of data items to save
+// Retruns error
+// error or nil for success.
+func (c *JsonLinesFilePersister) SaveWithContext(ctx context.Context, items []interface{}) (err error) {
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.Tracer.BeginTrace(correlationId, "persister.json_lines", "save")
+	defer func() { c.endOperation(timing, err) }()
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
+	content, err := c.toLines(correlationId, items)
+	if err != nil {
+		return err
+	}
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	if writeErr := ioutil.WriteFile(c.path, content, 0777); writeErr != nil {
+		return errors.NewFileError(correlationId, "WRITE_FAILED", "Failed to write data file: "+c.path).WithCause(writeErr)
+	}
+	return nil
+}
+
+// Appends given data items to the end of external JSON file.
+// The file is created when it does not exist.
+// Parameters:
+//   - correlationId string
+//   transaction id to trace execution through call chain.
+//   - items []interface[]
+//   list of data items to append
+// Retruns error
+// error or nil for success.
+func (c *JsonLinesFilePersister) Append(correlationId string, items []interface{}) (err error) {
+	timing := c.Tracer.BeginTrace(correlationId, "persister.json_lines", "append")
+	defer func() { c.endOperation(timing, err) }()
+
+	if c.path == "" {
+		return errors.NewConfigError(correlationId, "NO_PATH", "Data file path is not set")
+	}
+
+	content, err := c.toLines(correlationId, items)
+	if err != nil {
+		return err
+	}
+
+	file, openErr := os.OpenFile(c.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0777)
+	if openErr != nil {
+		return errors.NewFileError(correlationId, "WRITE_FAILED", "Failed to open data file: "+c.path).WithCause(openErr)
+	}
+	_, writeErr := file.Write(content)
+	if closeErr := file.Close(); writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr != nil {
+		return errors.NewFileError(correlationId, "WRITE_FAILED", "Failed to write data file: "+c.path).WithCause(writeErr)
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package persistence
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/pip-services3-go/pip-services3-commons-go/errors"
+)
+
+/*
+Keyset paging parameters used by IdentifiableMemoryPersistence.GetPageByKeyset.
+
+Unlike skip/take paging, keyset paging continues from the position of the last
+returned item, so pages stay consistent when items are added or removed between
+requests.
+*/
+type KeysetPagingParams struct {
+	// Continuation token returned with the previous page, empty for the first page
+	Token string
+	// Maximum number of items to return
+	Take int64
+}
+
+// Creates a new instance of keyset paging parameters.
+// Parameters:
+//   - token string
+//   (optional) continuation token returned with the previous page.
+//   - take int64
+//   maximum number of items to return, 0 to use max page size.
+// Returns *KeysetPagingParams
+func NewKeysetPagingParams(token string, take int64) *KeysetPagingParams {
+	return &KeysetPagingParams{Token: token, Take: take}
+}
+
+/*
+Page of data items retrieved using keyset paging.
+*/
+type KeysetPage struct {
+	// Opaque token to retrieve the next page, empty when there are no more items
+	Token string `json:"token"`
+	// Items of the page
+	Data []interface{} `json:"data"`
+}
+
+// Creates a new instance of keyset page.
+// Parameters:
+//   - token string
+//   continuation token for the next page.
+//   - data []interface{}
+//   items of the page.
+// Returns *KeysetPage
+func NewKeysetPage(token string, data []interface{}) *KeysetPage {
+	return &KeysetPage{Token: token, Data: data}
+}
+
+// Position of the last item of a page with the sort order it was taken in
+type keysetPosition struct {
+	Field      string      `json:"f"`
+	Descending bool        `json:"d"`
+	Key        *tokenValue `json:"k"`
+	Id         *tokenValue `json:"i"`
+}
+
+// Value encoded with its type, so it is decoded without losing precision or type
+type tokenValue struct {
+	Type  string        `json:"t"`
+	Value string        `json:"v,omitempty"`
+	List  []*tokenValue `json:"l,omitempty"`
+}
+
+// Types of values in keyset tokens
+const (
+	tokenNil    = "n"
+	tokenBool   = "b"
+	tokenInt    = "i"
+	tokenUint   = "u"
+	tokenFloat  = "f"
+	tokenString = "s"
+	tokenTime   = "t"
+	tokenKey    = "c"
+	tokenList   = "a"
+	tokenJson   = "j"
+)
+
+// Encodes a value with its type
+func encodeTokenValue(value interface{}) (*tokenValue, error) {
+	val := indirectValue(reflect.ValueOf(value))
+	if !val.IsValid() {
+		return &tokenValue{Type: tokenNil}, nil
+	}
+
+	kind := val.Kind()
+	switch {
+	case kind == reflect.Bool:
+		return &tokenValue{Type: tokenBool, Value: strconv.FormatBool(val.Bool())}, nil
+	case isSignedKind(kind):
+		return &tokenValue{Type: tokenInt, Value: strconv.FormatInt(val.Int(), 10)}, nil
+	case isUnsignedKind(kind):
+		return &tokenValue{Type: tokenUint, Value: strconv.FormatUint(val.Uint(), 10)}, nil
+	case kind == reflect.Float32 || kind == reflect.Float64:
+		return &tokenValue{Type: tokenFloat, Value: strconv.FormatFloat(val.Float(), 'g', -1, 64)}, nil
+	case kind == reflect.String || isBytes(val):
+		return &tokenValue{Type: tokenString, Value: string(textBytes(val))}, nil
+	case val.Type() == timeType && val.CanInterface():
+		return &tokenValue{Type: tokenTime, Value: val.Interface().(time.Time).Format(time.RFC3339Nano)}, nil
+	case kind == reflect.Slice || kind == reflect.Array:
+		typ := tokenList
+		if val.Type() == compositeKeyType {
+			typ = tokenKey
+		}
+		result := &tokenValue{Type: typ, List: make([]*tokenValue, val.Len())}
+		for i := range result.List {
+			item, err := encodeTokenValue(val.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			result.List[i] = item
+		}
+		return result, nil
+	default:
+		// Maps and structs are kept as JSON and decoded as maps
+		data, err := json.Marshal(val.Interface())
+		if err != nil {
+			return nil, err
+		}
+		return &tokenValue{Type: tokenJson, Value: string(data)}, nil
+	}
+}
+
+// Decodes a value encoded with its type
+func decodeTokenValue(value *tokenValue) (interface{}, error) {
+	if value == nil {
+		return nil, errors.NewBadRequestError("", "INVALID_TOKEN", "Token value is missing")
+	}
+
+	switch value.Type {
+	case tokenNil:
+		return nil, nil
+	case tokenBool:
+		return strconv.ParseBool(value.Value)
+	case tokenInt:
+		return strconv.ParseInt(value.Value, 10, 64)
+	case tokenUint:
+		return strconv.ParseUint(value.Value, 10, 64)
+	case tokenFloat:
+		return strconv.ParseFloat(value.Value, 64)
+	case tokenString:
+		return value.Value, nil
+	case tokenTime:
+		return time.Parse(time.RFC3339Nano, value.Value)
+	case tokenKey, tokenList:
+		result := make([]interface{}, len(value.List))
+		for i, item := range value.List {
+			var err error
+			if result[i], err = decodeTokenValue(item); err != nil {
+				return nil, err
+			}
+		}
+		if value.Type == tokenKey {
+			return CompositeKey(result), nil
+		}
+		return result, nil
+	case tokenJson:
+		var result interface{}
+		err := json.Unmarshal([]byte(value.Value), &result)
+		return result, err
+	default:
+		return nil, errors.NewBadRequestError("", "INVALID_TOKEN", "Token value type is unknown").
+			WithDetails("type", value.Type)
+	}
+}
+
+// Encodes sort order, sort key and id of the last item into an opaque token
+func encodeKeysetToken(sortField string, descending bool, key interface{}, id interface{}) string {
+	position := &keysetPosition{Field: sortField, Descending: descending}
+	var err error
+	if position.Key, err = encodeTokenValue(key); err != nil {
+		return ""
+	}
+	if position.Id, err = encodeTokenValue(id); err != nil {
+		return ""
+	}
+	value, err := json.Marshal(position)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(value)
+}
+
+// Decodes sort key and id of the last item from a token.
+// Tokens taken in a different sort order are rejected.
+func decodeKeysetToken(correlationId string, token string, sortField string, descending bool) (key interface{}, id interface{}, err error) {
+	value, err := base64.RawURLEncoding.DecodeString(token)
+	if err == nil {
+		position := &keysetPosition{}
+		if err = json.Unmarshal(value, position); err == nil {
+			if position.Field != sortField || position.Descending != descending {
+				return nil, nil, errors.NewBadRequestError(correlationId, "INVALID_TOKEN",
+					"Keyset paging token was taken in a different sort order").
+					WithDetails("token", token).
+					WithDetails("sort_field", sortField).
+					WithDetails("descending", descending)
+			}
+			if key, err = decodeTokenValue(position.Key); err == nil {
+				if id, err = decodeTokenValue(position.Id); err == nil {
+					return key, id, nil
+				}
+			}
+		}
+	}
+	return nil, nil, errors.NewBadRequestError(correlationId, "INVALID_TOKEN", "Keyset paging token is invalid").
+		WithDetails("token", token)
+}
@@ -0,0 +1,260 @@
+package persistence
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/pip-services3-go/pip-services3-commons-go/convert"
+	"github.com/pip-services3-go/pip-services3-commons-go/errors"
+)
+
+// Aggregation operations supported by MemoryPersistence.Aggregate
+const (
+	// Number of items, or number of non-empty values when field is set
+	AggregateCount = "count"
+	// Sum of numeric values
+	AggregateSum = "sum"
+	// Minimum value
+	AggregateMin = "min"
+	// Maximum value
+	AggregateMax = "max"
+	// Average of numeric values
+	AggregateAvg = "avg"
+	// Number of distinct values
+	AggregateDistinct = "distinct"
+)
+
+/*
+Aggregation calculated over a group of items by MemoryPersistence.Aggregate.
+*/
+type Aggregation struct {
+	// Name of the aggregated value in the result
+	Name string
+	// Aggregation operation: count, sum, min, max, avg or distinct
+	Operation string
+	// Name of the property to aggregate, optional for count
+	Field string
+}
+
+// Creates a new aggregation.
+// Parameters:
+//   - name string
+//   name of the aggregated value in the result.
+//   - operation string
+//   aggregation operation: count, sum, min, max, avg or distinct.
+//   - field string
+//   name of the property to aggregate, optional for count.
+// Returns Aggregation
+func NewAggregation(name string, operation string, field string) Aggregation {
+	return Aggregation{Name: name, Operation: operation, Field: field}
+}
+
+/*
+Group of items with aggregated values returned by MemoryPersistence.Aggregate.
+*/
+type AggregateGroup struct {
+	// Values of group by properties
+	Keys map[string]interface{}
+	// Aggregated values by aggregation names
+	Values map[string]interface{}
+}
+
+type aggregateState struct {
+	count    int64
+	sum      float64
+	value    interface{}
+	distinct map[string]bool
+}
+
+type aggregateGroupState struct {
+	keys   []interface{}
+	states []*aggregateState
+}
+
+// Calculates aggregated values over items that match to a given filter,
+// grouped by values of one or more properties.
+// Items are read in place under the read lock without being cloned.
+// Parameters:
+//   - correlationId string
+//   (optional) transaction id to trace execution through call chain.
+//   - filterFunc func(interface{}) bool
+//   (optional) a filter function to filter items
+//   - groupBy []string
+//   (optional) names of properties to group items by, empty to aggregate all items
+//   - aggregations []Aggregation
+//   aggregations to calculate in each group
+// Returns []AggregateGroup, error
+// groups ordered by their keys or error.
+func (c *MemoryPersistence) Aggregate(correlationId string, filterFunc func(interface{}) bool,
+	groupBy []string, aggregations []Aggregation) (result []AggregateGroup, err error) {
+	return c.AggregateWithContext(ContextWithCorrelationId(context.Background(), correlationId),
+		filterFunc, groupBy, aggregations)
+}
+
+// Calculates aggregated values over items that match to a given filter,
+// grouped by values of one or more properties.
+// The scan over items stops when the context is cancelled.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - filterFunc func(interface{}) bool
+//   (optional) a filter function to filter items
+//   - groupBy []string
+//   (optional) names of properties to group items by, empty to aggregate all items
+//   - aggregations []Aggregation
+//   aggregations to calculate in each group
+// Returns []AggregateGroup, error
+// groups ordered by their keys or error.
+func (c *MemoryPersistence) AggregateWithContext(ctx context.Context, filterFunc func(interface{}) bool,
+	groupBy []string, aggregations []Aggregation) (result []AggregateGroup, err error) {
+
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "aggregate")
+	defer func() { timing.end(err) }()
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	if filterFunc, err = c.tenantFilter(ctx, filterFunc); err != nil {
+		return nil, err
+	}
+
+	for _, aggregation := range aggregations {
+		switch aggregation.Operation {
+		case AggregateCount:
+		case AggregateSum, AggregateMin, AggregateMax, AggregateAvg, AggregateDistinct:
+			if aggregation.Field == "" {
+				return nil, errors.NewBadRequestError(correlationId, "NO_FIELD",
+					"Field is not set for "+aggregation.Operation+" aggregation").
+					WithDetails("name", aggregation.Name)
+			}
+		default:
+			return nil, errors.NewBadRequestError(correlationId, "INVALID_AGGREGATION",
+				"Aggregation operation "+aggregation.Operation+" is not supported").
+				WithDetails("name", aggregation.Name)
+		}
+	}
+
+	items, unlock := c.readItems()
+
+	groups := make(map[string]*aggregateGroupState)
+	order := make([]*aggregateGroupState, 0)
+	for index, item := range items {
+		if err = checkContext(ctx, index); err != nil {
+			unlock()
+			return nil, err
+		}
+		if filterFunc != nil && !filterFunc(item) {
+			continue
+		}
+
+		keys := make([]interface{}, len(groupBy))
+		keyStrs := make([]string, len(groupBy))
+		for i, field := range groupBy {
+			keys[i] = c.getProperty(item, field)
+			keyStrs[i] = toIdKey(keys[i])
+		}
+		groupKey := strings.Join(keyStrs, "\x1f")
+
+		group, ok := groups[groupKey]
+		if !ok {
+			group = &aggregateGroupState{
+				keys:   keys,
+				states: make([]*aggregateState, len(aggregations)),
+			}
+			for i := range group.states {
+				group.states[i] = &aggregateState{}
+			}
+			groups[groupKey] = group
+			order = append(order, group)
+		}
+
+		for i, aggregation := range aggregations {
+			accumulate(group.states[i], aggregation, item, c.PropertyTag)
+		}
+	}
+
+	unlock()
+
+	sort.SliceStable(order, func(i, j int) bool {
+		for k := range groupBy {
+			if cmp := ValueComparer.Compare(order[i].keys[k], order[j].keys[k]); cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+
+	result = make([]AggregateGroup, len(order))
+	for i, group := range order {
+		result[i].Keys = make(map[string]interface{}, len(groupBy))
+		for k, field := range groupBy {
+			result[i].Keys[field] = group.keys[k]
+		}
+		result[i].Values = make(map[string]interface{}, len(aggregations))
+		for k, aggregation := range aggregations {
+			result[i].Values[aggregation.Name] = group.states[k].result(aggregation.Operation)
+		}
+	}
+
+	timing.size(len(result))
+	c.Logger.Trace(correlationId, "Aggregated items into %d groups", len(result))
+	return result, nil
+}
+
+// Adds property value of the item to the aggregation state,
+// the property is matched by names from json tags and a struct tag
+func accumulate(state *aggregateState, aggregation Aggregation, item interface{}, tag string) {
+	if aggregation.Field == "" {
+		state.count++
+		return
+	}
+
+	value := GetPropertyWithTag(item, aggregation.Field, tag)
+	if value == nil {
+		return
+	}
+
+	switch aggregation.Operation {
+	case AggregateCount:
+		state.count++
+	case AggregateSum, AggregateAvg:
+		number := convert.DoubleConverter.ToNullableDouble(value)
+		if number != nil {
+			state.count++
+			state.sum += *number
+		}
+	case AggregateMin:
+		if state.value == nil || ValueComparer.Compare(value, state.value) < 0 {
+			state.value = value
+		}
+	case AggregateMax:
+		if state.value == nil || ValueComparer.Compare(value, state.value) > 0 {
+			state.value = value
+		}
+	case AggregateDistinct:
+		if state.distinct == nil {
+			state.distinct = make(map[string]bool)
+		}
+		state.distinct[toIdKey(value)] = true
+	}
+}
+
+// Gets the aggregated value from the state
+func (c *aggregateState) result(operation string) interface{} {
+	switch operation {
+	case AggregateCount:
+		return c.count
+	case AggregateSum:
+		return c.sum
+	case AggregateAvg:
+		if c.count == 0 {
+			return nil
+		}
+		return c.sum / float64(c.count)
+	case AggregateDistinct:
+		return int64(len(c.distinct))
+	default:
+		return c.value
+	}
+}
@@ -0,0 +1,122 @@
+package persistence
+
+/*
+Lock of a storage partition of MemoryPersistence.
+It is the RWMutex of the partition in default and sharded modes
+and copyOnWriteLock in copy-on-write mode.
+*/
+type partitionLock interface {
+	Lock()
+	Unlock()
+	RLock()
+	RUnlock()
+}
+
+/*
+Lock of Items in copy-on-write mode.
+Writers are serialized by Lock of the persistence and publish
+changed items when they release it, so readers that do not take
+the lock see every write as a whole.
+*/
+type copyOnWriteLock struct {
+	persistence *MemoryPersistence
+}
+
+func (c copyOnWriteLock) Lock() {
+	c.persistence.Lock.Lock()
+}
+
+func (c copyOnWriteLock) Unlock() {
+	c.persistence.publishItems()
+	c.persistence.Lock.Unlock()
+}
+
+func (c copyOnWriteLock) RLock() {
+	c.persistence.Lock.RLock()
+}
+
+func (c copyOnWriteLock) RUnlock() {
+	c.persistence.Lock.RUnlock()
+}
+
+// Checks if reads are served from published copies of items.
+// Returns true in copy-on-write mode.
+func (c *MemoryPersistence) IsCopyOnWrite() bool {
+	return c.copyOnWrite
+}
+
+// Switches copy-on-write mode on or off. The mode keeps items in Items,
+// so it shall be switched on in default mode, see setShardCount.
+// Parameters:
+//   - enabled bool
+//   true to switch copy-on-write mode on.
+func (c *MemoryPersistence) setCopyOnWrite(enabled bool) {
+	if enabled == c.copyOnWrite {
+		return
+	}
+
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+
+	c.copyOnWrite = enabled
+	c.itemsShared = false
+	if enabled {
+		c.publishItems()
+	}
+}
+
+// Publishes Items for readers when they were changed after the last publication.
+// Published items are never changed in place, see ownItems.
+// The method shall be called under write lock in copy-on-write mode.
+func (c *MemoryPersistence) publishItems() {
+	if c.itemsShared {
+		return
+	}
+	c.published.Store(c.Items)
+	c.itemsShared = true
+}
+
+// Gets items published by the last write in copy-on-write mode.
+func (c *MemoryPersistence) publishedItems() []interface{} {
+	items, _ := c.published.Load().([]interface{})
+	return items
+}
+
+// Copies items of a partition before they are changed when they are published,
+// so readers keep the items they loaded. Items are copied once per write.
+// The method shall be called under write lock of the partition.
+func (c *MemoryPersistence) ownItems(items *[]interface{}) {
+	if !c.copyOnWrite || !c.itemsShared {
+		return
+	}
+	owned := make([]interface{}, len(*items), len(*items)+1)
+	copy(owned, *items)
+	*items = owned
+	c.itemsShared = false
+}
+
+// Gets all stored items for reading. In copy-on-write mode it returns
+// published items without locks, in other modes it locks all partitions for reading.
+// Returned items shall not be changed.
+// Returns []interface{}, func()
+// stored items and a function that releases the locks.
+func (c *MemoryPersistence) readItems() ([]interface{}, func()) {
+	if c.copyOnWrite {
+		return c.publishedItems(), func() {}
+	}
+	unlock := c.rlockAll()
+	return c.storedItems(), unlock
+}
+
+// Gets items of the partition that stores the item with specified key for reading,
+// see readItems.
+// Returns []interface{}, func()
+// items of the partition and a function that releases the lock.
+func (c *MemoryPersistence) readPartition(key string) ([]interface{}, func()) {
+	if c.copyOnWrite {
+		return c.publishedItems(), func() {}
+	}
+	p := c.partition(key)
+	p.lock.RLock()
+	return *p.items, p.lock.RUnlock
+}
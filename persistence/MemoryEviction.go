@@ -0,0 +1,279 @@
+package persistence
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+
+	"github.com/pip-services3-go/pip-services3-commons-go/convert"
+)
+
+// Eviction policies used by MemoryPersistence when the number of stored
+// items exceeds options.max_items.
+const (
+	// Evicts the items that were added first
+	EvictionFifo = "fifo"
+	// Evicts the items that were accessed least recently
+	EvictionLru = "lru"
+	// Evicts the items that were accessed least frequently
+	EvictionLfu = "lfu"
+)
+
+// Normalizes eviction policy name and falls back to FIFO for unknown values.
+func toEvictionPolicy(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case EvictionLru, EvictionLfu:
+		return value
+	default:
+		return EvictionFifo
+	}
+}
+
+// Converts item id into a key suitable for internal lookup maps
+func toIdKey(id interface{}) string {
+	if id == nil {
+		return ""
+	}
+	if key, ok := id.(CompositeKey); ok {
+		return key.String()
+	}
+	return convert.StringConverter.ToString(id)
+}
+
+type accessEntry struct {
+	key  string
+	hits int64
+	// Element of the entry in the order list or in the entries of its bucket
+	element *list.Element
+	// Element of the LFU bucket that holds the entry
+	bucket *list.Element
+}
+
+// Entries with the same number of hits, ordered from the least recently accessed
+type hitBucket struct {
+	hits    int64
+	entries *list.List
+}
+
+/*
+Helper struct that tracks access to items in MemoryPersistence
+to choose victims for eviction without scanning stored items.
+For FIFO and LRU policies entries are kept in a list ordered from
+the first victim to the last one. For LFU policy entries are grouped
+into buckets by the number of hits, and buckets are ordered by hits.
+The tracker has its own lock because reads are tracked under
+the read lock of the persistence.
+*/
+type accessTracker struct {
+	lock    sync.Mutex
+	policy  string
+	entries map[string]*accessEntry
+	order   *list.List
+	buckets *list.List
+}
+
+func newAccessTracker() *accessTracker {
+	return &accessTracker{
+		policy:  EvictionFifo,
+		entries: make(map[string]*accessEntry),
+		order:   list.New(),
+		buckets: list.New(),
+	}
+}
+
+// Registers access to the item with specified key.
+// Items that are not tracked yet are added as just accessed.
+func (c *accessTracker) touch(key string) {
+	if key == "" {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &accessEntry{key: key, hits: 1}
+		c.entries[key] = entry
+		c.link(entry, c.buckets.Front())
+		return
+	}
+
+	entry.hits++
+	switch c.policy {
+	case EvictionLru:
+		c.order.MoveToBack(entry.element)
+	case EvictionLfu:
+		c.link(entry, c.unlink(entry))
+	}
+}
+
+// Adds the item with specified key to tracking without registering access,
+// so it becomes the first candidate for eviction among tracked items.
+func (c *accessTracker) track(key string) {
+	if key == "" {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.entries[key]; !ok {
+		entry := &accessEntry{key: key}
+		c.entries[key] = entry
+		c.link(entry, c.buckets.Front())
+	}
+}
+
+// Checks if the item with specified key is tracked
+func (c *accessTracker) has(key string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	_, ok := c.entries[key]
+	return ok
+}
+
+// Removes the item with specified key from tracking
+func (c *accessTracker) remove(key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if entry, ok := c.entries[key]; ok {
+		c.unlink(entry)
+		delete(c.entries, key)
+	}
+}
+
+// Removes all tracked items
+func (c *accessTracker) clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.entries = make(map[string]*accessEntry)
+	c.order.Init()
+	c.buckets.Init()
+}
+
+// Switches the tracker to another eviction policy.
+// Tracked items keep their current order of eviction.
+func (c *accessTracker) setPolicy(policy string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.switchPolicy(policy)
+}
+
+func (c *accessTracker) switchPolicy(policy string) {
+	if policy == c.policy {
+		return
+	}
+
+	entries := make([]*accessEntry, 0, len(c.entries))
+	c.collect(func(entry *accessEntry) bool {
+		entries = append(entries, entry)
+		return true
+	})
+
+	c.policy = policy
+	c.order.Init()
+	c.buckets.Init()
+	for _, entry := range entries {
+		entry.element = nil
+		entry.bucket = nil
+		c.link(entry, c.buckets.Front())
+	}
+}
+
+// Finds keys of the items that shall be evicted first.
+// The item with the keep key is never chosen, so just added items survive.
+// Parameters:
+//   - count int
+//   the number of items to evict.
+//   - policy string
+//   the eviction policy.
+//   - keep string
+//   (optional) key of the item that shall not be evicted.
+// Returns keys of the victims, fewer than count when not enough items are tracked.
+func (c *accessTracker) findVictims(count int, policy string, keep string) []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.switchPolicy(policy)
+
+	victims := make([]string, 0, count)
+	if count <= 0 {
+		return victims
+	}
+	c.collect(func(entry *accessEntry) bool {
+		if keep == "" || entry.key != keep {
+			victims = append(victims, entry.key)
+		}
+		return len(victims) < count
+	})
+	return victims
+}
+
+// Calls the callback for tracked entries from the first victim to the last one
+// until it returns false.
+func (c *accessTracker) collect(callback func(entry *accessEntry) bool) {
+	if c.policy != EvictionLfu {
+		for e := c.order.Front(); e != nil; e = e.Next() {
+			if !callback(e.Value.(*accessEntry)) {
+				return
+			}
+		}
+		return
+	}
+
+	for b := c.buckets.Front(); b != nil; b = b.Next() {
+		for e := b.Value.(*hitBucket).entries.Front(); e != nil; e = e.Next() {
+			if !callback(e.Value.(*accessEntry)) {
+				return
+			}
+		}
+	}
+}
+
+// Adds the entry to the end of the order list or of the bucket with its number of hits.
+// Buckets before the from bucket shall have fewer hits than the entry.
+func (c *accessTracker) link(entry *accessEntry, from *list.Element) {
+	if c.policy != EvictionLfu {
+		entry.element = c.order.PushBack(entry)
+		return
+	}
+
+	mark := from
+	for mark != nil && mark.Value.(*hitBucket).hits < entry.hits {
+		mark = mark.Next()
+	}
+	bucket := mark
+	if mark == nil {
+		bucket = c.buckets.PushBack(&hitBucket{hits: entry.hits, entries: list.New()})
+	} else if mark.Value.(*hitBucket).hits != entry.hits {
+		bucket = c.buckets.InsertBefore(&hitBucket{hits: entry.hits, entries: list.New()}, mark)
+	}
+	entry.bucket = bucket
+	entry.element = bucket.Value.(*hitBucket).entries.PushBack(entry)
+}
+
+// Removes the entry from the order list or from its bucket, empty buckets are dropped.
+// Returns the bucket that followed the bucket of the entry.
+func (c *accessTracker) unlink(entry *accessEntry) *list.Element {
+	if entry.bucket == nil {
+		c.order.Remove(entry.element)
+		return nil
+	}
+
+	bucket := entry.bucket
+	next := bucket.Next()
+	entries := bucket.Value.(*hitBucket).entries
+	entries.Remove(entry.element)
+	if entries.Len() == 0 {
+		c.buckets.Remove(bucket)
+	}
+	entry.bucket = nil
+	entry.element = nil
+	return next
+}
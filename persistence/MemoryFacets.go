@@ -0,0 +1,177 @@
+package persistence
+
+import (
+	"context"
+	"sort"
+)
+
+/*
+Distinct value of a property with the number of items
+that have it, returned by MemoryPersistence.GetFacets.
+*/
+type FacetValue struct {
+	// Value of the property
+	Value interface{} `json:"value"`
+	// Number of items with the value
+	Count int64 `json:"count"`
+}
+
+// Gets distinct values of a property in items that match to a given filter.
+// Properties are read using GetProperty, so nested and map items are supported.
+// Parameters:
+//   - correlationId string
+//   (optional) transaction id to trace execution through call chain.
+//   - field string
+//   a name of the property.
+//   - filterFunc func(interface{}) bool
+//   (optional) a filter function to filter items
+// Returns []interface{}, error
+// distinct values in ascending order or error.
+func (c *MemoryPersistence) GetDistinctValues(correlationId string, field string,
+	filterFunc func(interface{}) bool) (result []interface{}, err error) {
+	return c.GetDistinctValuesWithContext(ContextWithCorrelationId(context.Background(), correlationId), field, filterFunc)
+}
+
+// Gets distinct values of a property in items that match to a given filter.
+// The scan over items stops when the context is cancelled.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - field string
+//   a name of the property.
+//   - filterFunc func(interface{}) bool
+//   (optional) a filter function to filter items
+// Returns []interface{}, error
+// distinct values in ascending order or error.
+func (c *MemoryPersistence) GetDistinctValuesWithContext(ctx context.Context, field string,
+	filterFunc func(interface{}) bool) (result []interface{}, err error) {
+
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "get_distinct_values")
+	defer func() { timing.end(err) }()
+	facets, err := c.collectFacets(ctx, []string{field}, filterFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	result = make([]interface{}, len(facets[0]))
+	for i, v := range facets[0] {
+		result[i] = v.Value
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return ValueComparer.Compare(result[i], result[j]) < 0
+	})
+
+	timing.size(len(result))
+	c.Logger.Trace(correlationId, "Retrieved %d distinct values of %s", len(result), field)
+	return result, nil
+}
+
+// Gets faceted counts: distinct values of properties with numbers of items
+// that match to a given filter and have each value.
+// Parameters:
+//   - correlationId string
+//   (optional) transaction id to trace execution through call chain.
+//   - fields []string
+//   names of the properties.
+//   - filterFunc func(interface{}) bool
+//   (optional) a filter function to filter items
+// Returns map[string][]FacetValue, error
+// facet values for each property ordered by count in descending order
+// and then by value, or error.
+func (c *MemoryPersistence) GetFacets(correlationId string, fields []string,
+	filterFunc func(interface{}) bool) (result map[string][]FacetValue, err error) {
+	return c.GetFacetsWithContext(ContextWithCorrelationId(context.Background(), correlationId), fields, filterFunc)
+}
+
+// Gets faceted counts: distinct values of properties with numbers of items
+// that match to a given filter and have each value.
+// The scan over items stops when the context is cancelled.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - fields []string
+//   names of the properties.
+//   - filterFunc func(interface{}) bool
+//   (optional) a filter function to filter items
+// Returns map[string][]FacetValue, error
+// facet values for each property ordered by count in descending order
+// and then by value, or error.
+func (c *MemoryPersistence) GetFacetsWithContext(ctx context.Context, fields []string,
+	filterFunc func(interface{}) bool) (result map[string][]FacetValue, err error) {
+
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "get_facets")
+	defer func() { timing.end(err) }()
+	facets, err := c.collectFacets(ctx, fields, filterFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	result = make(map[string][]FacetValue, len(fields))
+	for i, field := range fields {
+		values := make([]FacetValue, len(facets[i]))
+		for k, v := range facets[i] {
+			values[k] = *v
+		}
+		sort.SliceStable(values, func(i, j int) bool {
+			if values[i].Count != values[j].Count {
+				return values[i].Count > values[j].Count
+			}
+			return ValueComparer.Compare(values[i].Value, values[j].Value) < 0
+		})
+		result[field] = values
+	}
+
+	c.Logger.Trace(correlationId, "Retrieved facets for %d fields", len(fields))
+	return result, nil
+}
+
+// Counts distinct values of properties in a single pass under the read lock.
+// Items with empty values are not counted.
+func (c *MemoryPersistence) collectFacets(ctx context.Context, fields []string,
+	filterFunc func(interface{}) bool) ([][]*FacetValue, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	filterFunc, err := c.tenantFilter(ctx, filterFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	items, unlock := c.readItems()
+	defer unlock()
+
+	indexes := make([]map[string]*FacetValue, len(fields))
+	facets := make([][]*FacetValue, len(fields))
+	for i := range fields {
+		indexes[i] = make(map[string]*FacetValue)
+		facets[i] = make([]*FacetValue, 0)
+	}
+
+	for index, item := range items {
+		if err := checkContext(ctx, index); err != nil {
+			return nil, err
+		}
+		if filterFunc != nil && !filterFunc(item) {
+			continue
+		}
+
+		for i, field := range fields {
+			value := c.getProperty(item, field)
+			if value == nil {
+				continue
+			}
+
+			key := toIdKey(value)
+			facet, ok := indexes[i][key]
+			if !ok {
+				facet = &FacetValue{Value: value}
+				indexes[i][key] = facet
+				facets[i] = append(facets[i], facet)
+			}
+			facet.Count++
+		}
+	}
+	return facets, nil
+}
@@ -0,0 +1,415 @@
+package persistence
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"github.com/pip-services3-go/pip-services3-commons-go/convert"
+	"github.com/pip-services3-go/pip-services3-commons-go/errors"
+)
+
+// Mean radius of the Earth in meters
+const earthRadius = 6371008.8
+
+// Length of one degree of latitude in meters
+const metersPerDegree = math.Pi * earthRadius / 180
+
+// Default size of geo index cells in degrees
+const DefaultGeoCellSize = 1.0
+
+/*
+Item found by a geospatial query with its distance to the query point.
+*/
+type GeoResult struct {
+	// Found item
+	Item interface{} `json:"item"`
+	// Distance from the query point in meters
+	Distance float64 `json:"distance"`
+}
+
+type geoPoint struct {
+	latitude  float64
+	longitude float64
+	cell      geoCell
+}
+
+type geoCell struct {
+	row    int
+	column int
+}
+
+/*
+Grid index over coordinates of items used by geospatial queries of MemoryPersistence.
+The surface is split into cells of equal size in degrees, and queries
+only check items in cells that overlap with the searched area.
+*/
+type geoIndex struct {
+	latitudeField  string
+	longitudeField string
+	tag            string
+	cellSize       float64
+	rows           int
+	columns        int
+	cells          map[geoCell]map[string]interface{}
+	points         map[string]geoPoint
+}
+
+func newGeoIndex(latitudeField string, longitudeField string, cellSize float64, tag string) *geoIndex {
+	if cellSize <= 0 || cellSize > 180 {
+		cellSize = DefaultGeoCellSize
+	}
+	c := &geoIndex{
+		latitudeField:  latitudeField,
+		longitudeField: longitudeField,
+		tag:            tag,
+		cellSize:       cellSize,
+		rows:           int(math.Ceil(180 / cellSize)),
+		columns:        int(math.Ceil(360 / cellSize)),
+	}
+	c.clear()
+	return c
+}
+
+// Reads coordinates of the item.
+// Returns false when coordinates are missing or out of range.
+func (c *geoIndex) coordinates(item interface{}) (latitude float64, longitude float64, ok bool) {
+	lat := convert.DoubleConverter.ToNullableDouble(GetPropertyWithTag(item, c.latitudeField, c.tag))
+	lon := convert.DoubleConverter.ToNullableDouble(GetPropertyWithTag(item, c.longitudeField, c.tag))
+	if lat == nil || lon == nil || math.Abs(*lat) > 90 || math.Abs(*lon) > 180 {
+		return 0, 0, false
+	}
+	return *lat, *lon, true
+}
+
+func (c *geoIndex) row(latitude float64) int {
+	row := int(math.Floor((latitude + 90) / c.cellSize))
+	if row >= c.rows {
+		row = c.rows - 1
+	}
+	if row < 0 {
+		row = 0
+	}
+	return row
+}
+
+func (c *geoIndex) column(longitude float64) int {
+	column := int(math.Floor((longitude + 180) / c.cellSize))
+	column %= c.columns
+	if column < 0 {
+		column += c.columns
+	}
+	return column
+}
+
+func (c *geoIndex) add(key string, item interface{}) {
+	latitude, longitude, ok := c.coordinates(item)
+	if !ok {
+		return
+	}
+
+	cell := geoCell{row: c.row(latitude), column: c.column(longitude)}
+	items, ok := c.cells[cell]
+	if !ok {
+		items = make(map[string]interface{})
+		c.cells[cell] = items
+	}
+	items[key] = item
+	c.points[key] = geoPoint{latitude: latitude, longitude: longitude, cell: cell}
+}
+
+func (c *geoIndex) remove(key string, item interface{}) {
+	point, ok := c.points[key]
+	if !ok {
+		return
+	}
+
+	items := c.cells[point.cell]
+	delete(items, key)
+	if len(items) == 0 {
+		delete(c.cells, point.cell)
+	}
+	delete(c.points, key)
+}
+
+func (c *geoIndex) clear() {
+	c.cells = make(map[geoCell]map[string]interface{})
+	c.points = make(map[string]geoPoint)
+}
+
+// Calls the callback for items in cells that overlap with the area.
+// Longitudes of the area may go beyond -180..180 range to wrap around the antimeridian.
+// The scan stops with the context error when the context is cancelled.
+func (c *geoIndex) scan(ctx context.Context, minLatitude, minLongitude, maxLatitude, maxLongitude float64,
+	callback func(key string, item interface{}, point geoPoint)) error {
+
+	minRow, maxRow := c.row(minLatitude), c.row(maxLatitude)
+	minColumn := int(math.Floor((minLongitude + 180) / c.cellSize))
+	maxColumn := int(math.Floor((maxLongitude + 180) / c.cellSize))
+	if maxColumn-minColumn >= c.columns {
+		minColumn, maxColumn = 0, c.columns-1
+	}
+
+	for row := minRow; row <= maxRow; row++ {
+		for column := minColumn; column <= maxColumn; column++ {
+			cell := geoCell{row: row, column: column % c.columns}
+			if cell.column < 0 {
+				cell.column += c.columns
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			for key, item := range c.cells[cell] {
+				callback(key, item, c.points[key])
+			}
+		}
+	}
+	return nil
+}
+
+// Calculates the great-circle distance between two points using the haversine formula.
+// Returns distance in meters.
+func geoDistance(latitude1, longitude1, latitude2, longitude2 float64) float64 {
+	lat1 := latitude1 * math.Pi / 180
+	lat2 := latitude2 * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (longitude2 - longitude1) * math.Pi / 180
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// Enables the geospatial index over item coordinates.
+// The index is maintained on writes and used by GetListWithinRadius and GetListWithinBox.
+// Items without valid coordinates are not indexed.
+// Parameters:
+//   - latitudeField string
+//   name of the property with latitude in degrees.
+//   - longitudeField string
+//   name of the property with longitude in degrees.
+//   - cellSize float64
+//   size of index cells in degrees, 0 to use DefaultGeoCellSize.
+func (c *MemoryPersistence) EnableGeoIndex(latitudeField string, longitudeField string, cellSize float64) {
+	unlock := c.lockAll()
+	defer unlock()
+
+	if c.geoIndex != nil {
+		c.removeIndex(c.geoIndex)
+		c.geoIndex = nil
+	}
+
+	if latitudeField != "" && longitudeField != "" {
+		c.geoIndex = newGeoIndex(latitudeField, longitudeField, cellSize, c.PropertyTag)
+		c.addIndex(c.geoIndex)
+	}
+}
+
+// Gets a list of items located within a distance from a point, ordered by the distance.
+// Parameters:
+//   - correlationId string
+//   (optional) transaction id to trace execution through call chain.
+//   - latitude float64
+//   latitude of the point in degrees.
+//   - longitude float64
+//   longitude of the point in degrees.
+//   - radius float64
+//   maximum distance from the point in meters.
+//   - filterFunc func(interface{}) bool
+//   (optional) a filter function to filter items
+// Returns []GeoResult, error
+// found items with distances or error.
+func (c *MemoryPersistence) GetListWithinRadius(correlationId string, latitude float64, longitude float64,
+	radius float64, filterFunc func(interface{}) bool) (result []GeoResult, err error) {
+	return c.GetListWithinRadiusWithContext(ContextWithCorrelationId(context.Background(), correlationId),
+		latitude, longitude, radius, filterFunc)
+}
+
+// Gets a list of items located within a distance from a point, ordered by the distance.
+// The scan over items stops when the context is cancelled.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - latitude float64
+//   latitude of the point in degrees.
+//   - longitude float64
+//   longitude of the point in degrees.
+//   - radius float64
+//   maximum distance from the point in meters.
+//   - filterFunc func(interface{}) bool
+//   (optional) a filter function to filter items
+// Returns []GeoResult, error
+// found items with distances or error.
+func (c *MemoryPersistence) GetListWithinRadiusWithContext(ctx context.Context, latitude float64, longitude float64,
+	radius float64, filterFunc func(interface{}) bool) (result []GeoResult, err error) {
+
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "get_list_within_radius")
+	defer func() { timing.end(err) }()
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	if filterFunc, err = c.tenantFilter(ctx, filterFunc); err != nil {
+		return nil, err
+	}
+
+	if math.Abs(latitude) > 90 || math.Abs(longitude) > 180 || radius < 0 {
+		return nil, errors.NewBadRequestError(correlationId, "INVALID_GEO_QUERY", "Point or radius is invalid").
+			WithDetails("latitude", latitude).WithDetails("longitude", longitude).WithDetails("radius", radius)
+	}
+
+	unlock := c.rlockAll()
+	defer unlock()
+
+	if c.geoIndex == nil {
+		return nil, errors.NewBadRequestError(correlationId, "NO_GEO_INDEX", "Geospatial index is not enabled")
+	}
+
+	// Bounding box of the circle, the whole parallel band when it covers a pole
+	deltaLatitude := radius / metersPerDegree
+	minLatitude, maxLatitude := latitude-deltaLatitude, latitude+deltaLatitude
+	minLongitude, maxLongitude := -180.0, 180.0
+	if minLatitude > -90 && maxLatitude < 90 {
+		cos := math.Cos(math.Max(math.Abs(minLatitude), math.Abs(maxLatitude)) * math.Pi / 180)
+		deltaLongitude := deltaLatitude / cos
+		if deltaLongitude < 180 {
+			minLongitude, maxLongitude = longitude-deltaLongitude, longitude+deltaLongitude
+		}
+	}
+
+	result = make([]GeoResult, 0)
+	err = c.geoIndex.scan(ctx, minLatitude, minLongitude, maxLatitude, maxLongitude,
+		func(key string, item interface{}, point geoPoint) {
+			distance := geoDistance(latitude, longitude, point.latitude, point.longitude)
+			if distance > radius || (filterFunc != nil && !filterFunc(item)) {
+				return
+			}
+			result = append(result, GeoResult{Item: item, Distance: distance})
+		})
+
+	if err != nil {
+		return nil, err
+	}
+
+	timing.size(len(result))
+	c.Logger.Trace(correlationId, "Retrieved %d items within %v meters", len(result), radius)
+	return c.completeGeoResult(result), nil
+}
+
+// Gets a list of items located within a bounding box, ordered by distance from the box center.
+// When minLongitude is greater than maxLongitude the box crosses the antimeridian.
+// Parameters:
+//   - correlationId string
+//   (optional) transaction id to trace execution through call chain.
+//   - minLatitude float64
+//   southern latitude of the box in degrees.
+//   - minLongitude float64
+//   western longitude of the box in degrees.
+//   - maxLatitude float64
+//   northern latitude of the box in degrees.
+//   - maxLongitude float64
+//   eastern longitude of the box in degrees.
+//   - filterFunc func(interface{}) bool
+//   (optional) a filter function to filter items
+// Returns []GeoResult, error
+// found items with distances from the box center or error.
+func (c *MemoryPersistence) GetListWithinBox(correlationId string, minLatitude float64, minLongitude float64,
+	maxLatitude float64, maxLongitude float64, filterFunc func(interface{}) bool) (result []GeoResult, err error) {
+	return c.GetListWithinBoxWithContext(ContextWithCorrelationId(context.Background(), correlationId),
+		minLatitude, minLongitude, maxLatitude, maxLongitude, filterFunc)
+}
+
+// Gets a list of items located within a bounding box, ordered by distance from the box center.
+// The scan over items stops when the context is cancelled.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - minLatitude float64
+//   southern latitude of the box in degrees.
+//   - minLongitude float64
+//   western longitude of the box in degrees.
+//   - maxLatitude float64
+//   northern latitude of the box in degrees.
+//   - maxLongitude float64
+//   eastern longitude of the box in degrees.
+//   - filterFunc func(interface{}) bool
+//   (optional) a filter function to filter items
+// Returns []GeoResult, error
+// found items with distances from the box center or error.
+func (c *MemoryPersistence) GetListWithinBoxWithContext(ctx context.Context, minLatitude float64, minLongitude float64,
+	maxLatitude float64, maxLongitude float64, filterFunc func(interface{}) bool) (result []GeoResult, err error) {
+
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "get_list_within_box")
+	defer func() { timing.end(err) }()
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	if filterFunc, err = c.tenantFilter(ctx, filterFunc); err != nil {
+		return nil, err
+	}
+
+	if math.Abs(minLatitude) > 90 || math.Abs(maxLatitude) > 90 || minLatitude > maxLatitude ||
+		math.Abs(minLongitude) > 180 || math.Abs(maxLongitude) > 180 {
+		return nil, errors.NewBadRequestError(correlationId, "INVALID_GEO_QUERY", "Bounding box is invalid").
+			WithDetails("min_latitude", minLatitude).WithDetails("min_longitude", minLongitude).
+			WithDetails("max_latitude", maxLatitude).WithDetails("max_longitude", maxLongitude)
+	}
+
+	unlock := c.rlockAll()
+	defer unlock()
+
+	if c.geoIndex == nil {
+		return nil, errors.NewBadRequestError(correlationId, "NO_GEO_INDEX", "Geospatial index is not enabled")
+	}
+
+	// Unwrap boxes that cross the antimeridian
+	if minLongitude > maxLongitude {
+		maxLongitude += 360
+	}
+	centerLatitude := (minLatitude + maxLatitude) / 2
+	centerLongitude := (minLongitude + maxLongitude) / 2
+	if centerLongitude > 180 {
+		centerLongitude -= 360
+	}
+
+	result = make([]GeoResult, 0)
+	err = c.geoIndex.scan(ctx, minLatitude, minLongitude, maxLatitude, maxLongitude,
+		func(key string, item interface{}, point geoPoint) {
+			longitude := point.longitude
+			if longitude < minLongitude {
+				longitude += 360
+			}
+			if point.latitude < minLatitude || point.latitude > maxLatitude ||
+				longitude < minLongitude || longitude > maxLongitude {
+				return
+			}
+			if filterFunc != nil && !filterFunc(item) {
+				return
+			}
+			distance := geoDistance(centerLatitude, centerLongitude, point.latitude, point.longitude)
+			result = append(result, GeoResult{Item: item, Distance: distance})
+		})
+
+	if err != nil {
+		return nil, err
+	}
+
+	timing.size(len(result))
+	c.Logger.Trace(correlationId, "Retrieved %d items within bounding box", len(result))
+	return c.completeGeoResult(result), nil
+}
+
+// Sorts found items by distance and clones them for the result
+func (c *MemoryPersistence) completeGeoResult(result []GeoResult) []GeoResult {
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Distance != result[j].Distance {
+			return result[i].Distance < result[j].Distance
+		}
+		return ValueComparer.Compare(c.getId(result[i].Item), c.getId(result[j].Item)) < 0
+	})
+	for i := range result {
+		result[i].Item = c.cloneResult(result[i].Item)
+	}
+	return result
+}
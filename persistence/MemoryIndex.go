@@ -0,0 +1,131 @@
+package persistence
+
+import "sync/atomic"
+
+/*
+Index over items stored in MemoryPersistence.
+Indexes are maintained on every write and rebuilt when items
+are replaced as a whole. Items are indexed by keys of their ids
+within tenants, see tenantKey. Indexes are changed under write lock
+of a partition and read under locks of all partitions. In sharded mode
+writes to different shards run concurrently, so writers also hold
+indexLock of the persistence and indexes do not need locks of their own.
+*/
+type itemIndex interface {
+	// Adds the item with specified key to the index
+	add(key string, item interface{})
+	// Removes the item with specified key from the index
+	remove(key string, item interface{})
+	// Removes all items from the index
+	clear()
+}
+
+// Appends a new item to a partition, registers access to it and adds it to indexes.
+// The method shall be called under write lock of the partition.
+func (c *MemoryPersistence) appendItem(items *[]interface{}, item interface{}) {
+	c.ownItems(items)
+	*items = append(*items, item)
+	atomic.AddInt64(&c.size, 1)
+	c.countItems()
+	c.markDirty()
+	c.observeId(item)
+
+	key := c.itemKey(item)
+	c.tracker.touch(key)
+	if key != "" {
+		c.indexLock.Lock()
+		for _, index := range c.indexes {
+			index.add(key, item)
+		}
+		c.indexLock.Unlock()
+	}
+}
+
+// Replaces the item at specified position of a partition, registers access to it and updates indexes.
+// The method shall be called under write lock of the partition.
+func (c *MemoryPersistence) replaceItem(items *[]interface{}, position int, item interface{}) {
+	c.ownItems(items)
+	oldItem := (*items)[position]
+	(*items)[position] = item
+	c.markDirty()
+
+	oldKey := c.itemKey(oldItem)
+	key := c.itemKey(item)
+	if oldKey != key {
+		c.tracker.remove(oldKey)
+	}
+	c.tracker.touch(key)
+
+	c.indexLock.Lock()
+	defer c.indexLock.Unlock()
+	for _, index := range c.indexes {
+		if oldKey != "" {
+			index.remove(oldKey, oldItem)
+		}
+		if key != "" {
+			index.add(key, item)
+		}
+	}
+}
+
+// Removes the item at specified position of a partition from items, access tracking and indexes.
+// The method shall be called under write lock of the partition.
+// Returns the removed item.
+func (c *MemoryPersistence) removeItem(items *[]interface{}, position int) interface{} {
+	c.ownItems(items)
+	item := (*items)[position]
+	*items = append((*items)[:position], (*items)[position+1:]...)
+	atomic.AddInt64(&c.size, -1)
+	c.countItems()
+	c.markDirty()
+
+	key := c.itemKey(item)
+	c.tracker.remove(key)
+	if key != "" {
+		c.indexLock.Lock()
+		for _, index := range c.indexes {
+			index.remove(key, item)
+		}
+		c.indexLock.Unlock()
+	}
+	return item
+}
+
+// Adds an index and fills it with stored items.
+// The method shall be called under write lock of all partitions.
+func (c *MemoryPersistence) addIndex(index itemIndex) {
+	c.indexes = append(c.indexes, index)
+	for _, item := range c.storedItems() {
+		if key := c.itemKey(item); key != "" {
+			index.add(key, item)
+		}
+	}
+}
+
+// Removes an index, so it is no longer maintained on writes.
+// The method shall be called under write lock of all partitions.
+func (c *MemoryPersistence) removeIndex(index itemIndex) {
+	for i, v := range c.indexes {
+		if v == index {
+			c.indexes = append(c.indexes[:i], c.indexes[i+1:]...)
+			return
+		}
+	}
+}
+
+// Refills all indexes from stored items.
+// The method shall be called under write lock of all partitions.
+func (c *MemoryPersistence) rebuildIndexes() {
+	for _, index := range c.indexes {
+		index.clear()
+	}
+	for _, item := range c.storedItems() {
+		key := c.itemKey(item)
+		if key == "" {
+			continue
+		}
+		for _, index := range c.indexes {
+			index.add(key, item)
+		}
+	}
+}
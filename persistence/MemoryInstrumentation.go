@@ -0,0 +1,83 @@
+package persistence
+
+import (
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pip-services3-go/pip-services3-components-go/count"
+	"github.com/pip-services3-go/pip-services3-components-go/trace"
+)
+
+// Gets default name used in counters and traces of a persistence with the prototype
+func defaultComponentName(prototype reflect.Type) string {
+	for prototype != nil && prototype.Kind() == reflect.Ptr {
+		prototype = prototype.Elem()
+	}
+	if prototype == nil || prototype.Name() == "" {
+		return "persistence"
+	}
+	return "persistence." + strings.ToLower(prototype.Name())
+}
+
+/*
+Measurement of a single persistence operation.
+It records number of calls, execution time and errors
+into counters named <ComponentName>.<operation>.calls, .exec_time and .errors,
+and traces the operation with its duration and error.
+*/
+type operationTiming struct {
+	counters *count.CompositeCounters
+	trace    *trace.TraceTiming
+	name     string
+	start    time.Time
+}
+
+// Starts measurement of a persistence operation.
+// Parameters:
+//   - correlationId string
+//   (optional) transaction id to trace execution through call chain.
+//   - operation string
+//   a name of the operation used in counter names and traces.
+// Returns *operationTiming
+// the measurement to be ended when the operation completes.
+func (c *MemoryPersistence) beginOperation(correlationId string, operation string) *operationTiming {
+	name := c.ComponentName + "." + operation
+	c.Counters.IncrementOne(name + ".calls")
+	return &operationTiming{
+		counters: c.Counters,
+		trace:    c.Tracer.BeginTrace(correlationId, c.ComponentName, operation),
+		name:     name,
+		start:    time.Now(),
+	}
+}
+
+// Ends measurement of the operation and records the error when it failed.
+// Parameters:
+//   - err error
+//   an error returned by the operation or nil.
+func (c *operationTiming) end(err error) {
+	elapsed := time.Since(c.start).Seconds() * 1000
+	c.counters.EndTiming(c.name+".exec_time", float32(elapsed))
+	if err != nil {
+		c.counters.IncrementOne(c.name + ".errors")
+		c.trace.EndFailure(err)
+	} else {
+		c.trace.EndTrace()
+	}
+}
+
+// Records the number of items returned by the operation.
+// Parameters:
+//   - size int
+//   a number of returned items.
+func (c *operationTiming) size(size int) {
+	c.counters.Last(c.name+".result_size", float32(size))
+}
+
+// Records the number of stored items.
+// The number is counted by writers of all partitions, so it does not need locks.
+func (c *MemoryPersistence) countItems() {
+	c.Counters.Last(c.ComponentName+".items", float32(atomic.LoadInt64(&c.size)))
+}
@@ -1,17 +1,21 @@
 package persistence
 
 import (
-	"encoding/json"
+	"context"
 	"math/rand"
 	"reflect"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/pip-services3-go/pip-services3-commons-go/convert"
+	"github.com/pip-services3-go/pip-services3-commons-go/config"
 	cdata "github.com/pip-services3-go/pip-services3-commons-go/data"
+	"github.com/pip-services3-go/pip-services3-commons-go/errors"
 	"github.com/pip-services3-go/pip-services3-commons-go/refer"
+	"github.com/pip-services3-go/pip-services3-components-go/count"
 	"github.com/pip-services3-go/pip-services3-components-go/log"
+	"github.com/pip-services3-go/pip-services3-components-go/trace"
 )
 
 /*
@@ -26,9 +30,59 @@ The component supports loading and saving items from another data source.
 That allows to use it as a base struct for file and other types
 of persistence components that cache all data in memory.
 
+When options.max_items is set the component works as a cache:
+after items are added it evicts extra items according to the
+configured eviction policy and reports them to the logger
+and to the OnEvicted callback.
+
+When options.shards is greater than 1 items are partitioned into shards
+by hash of their ids. Each shard has its own lock, so operations by id
+on different shards do not block each other. Queries lock all shards
+in order and scan them in the caller goroutine, so without a sort function
+items are returned in the order of shards, not in the order they were created.
+In sharded mode Items stays empty, so child structs shall not access it directly.
+
+When options.copy_on_write is true reads do not take locks. Writers are
+serialized by Lock, copy Items before the first change and publish the copy
+atomically when they release the lock, so reads never wait for writers and
+writers never wait for scans. Reads see items published by the last write,
+and changes made to Items directly are not seen until the next write.
+The mode suits read-heavy workloads, since every write copies all items.
+Full-text and geospatial queries still take the read lock. Copy-on-write mode
+keeps all items in Items, so options.shards is ignored when it is enabled.
+
+Configuration parameters
+
+- options:
+    - shards:              Number of shards, 0 or 1 keeps all items in Items under Lock (default: 0)
+    - copy_on_write:       Reads items published by writers without locks (default: false)
+    - max_items:           Maximum number of stored items, 0 for unlimited (default: 0)
+    - eviction_policy:     Eviction policy: fifo, lru or lfu (default: fifo)
+    - text_fields:         Comma-separated names of properties for the full-text index used by MatchText
+    - latitude_field:      Name of the latitude property for the geospatial index
+    - longitude_field:     Name of the longitude property for the geospatial index
+    - geo_cell_size:       Size of geospatial index cells in degrees (default: 1)
+    - tenant_field:        Name of the property with tenant id, enables tenant mode when set
+    - clone_strategy:      Strategy to copy stored and returned items: deep, shallow or none (default: deep)
+    - id_generator:        Generator of ids for new items: long, uuid, uuid7, ulid or sequence (default: sequence for integer ids, long for others)
+    - id_field:            Comma-separated names of id fields, several fields form a composite key (default: fields with persist:"id" tag or Id)
+    - property_tag:        Name of an additional struct tag with property names checked before json tags, like bson
+
 References
 
 - *:logger:*:*:1.0    ILogger components to pass log messages
+- *:counters:*:*:1.0  (optional) ICounters components to pass collected measurements
+- *:tracer:*:*:1.0    (optional) ITracer components to record operation traces
+
+The component records number of calls, execution time, errors and result sizes
+of operations, load and save durations and number of stored items into counters
+prefixed with ComponentName, for instance "persistence.mydata.create.exec_time".
+Each operation is also traced with ComponentName as the component name.
+References are passed to loader and saver components that implement IReferenceable.
+
+In tenant mode operations receive the tenant id in context created by ContextWithTenantId.
+Reads, deletes and Clear only see items of that tenant and created items are stamped with it.
+Operations without context cannot receive a tenant id, so they return BadRequestError.
 
 Example
 
@@ -58,14 +112,50 @@ Example
 */
 // implements IReferenceable, IOpenable, ICleanable
 type MemoryPersistence struct {
-	Logger      *log.CompositeLogger
-	Items       []interface{}
-	Loader      ILoader
-	Saver       ISaver
-	opened      bool
-	Prototype   reflect.Type
+	Logger *log.CompositeLogger
+	// Stored items in default mode, in sharded mode it stays empty
+	Items     []interface{}
+	Loader    ILoader
+	Saver     ISaver
+	opened    bool
+	Prototype reflect.Type
+	// Lock of Items in default mode
 	Lock        sync.RWMutex
 	MaxPageSize int
+	// Maximum number of stored items, 0 means unlimited
+	MaxItems int
+	// Eviction policy: EvictionFifo, EvictionLru or EvictionLfu
+	EvictionPolicy string
+	// Optional callback that receives items evicted from the persistence
+	OnEvicted func(correlationId string, item interface{})
+	// Counters to record performance metrics
+	Counters *count.CompositeCounters
+	// Tracer to record operation traces
+	Tracer *trace.CompositeTracer
+	// Name of the component in counters and traces, "persistence.<prototype name>" by default
+	ComponentName string
+	// Name of the property with tenant id, enables tenant mode when set
+	TenantField string
+	// Strategy to copy stored and returned items: CloneDeep, CloneShallow or CloneNone
+	CloneStrategy string
+	// Generator of ids for new items, by default ids are generated by GenerateObjectId
+	IdGenerator IIdGenerator
+	// Names of id fields, several fields form a composite key. By default fields with persist:"id" tag or Id are used
+	IdFields []string
+	// Name of an additional struct tag with property names, like "bson", checked before json tags
+	PropertyTag string
+	tracker     *accessTracker
+	indexes     []itemIndex
+	indexLock   sync.Mutex
+	textIndex   *textIndex
+	geoIndex    *geoIndex
+	shards      []*itemShard
+	copyOnWrite bool
+	published   atomic.Value
+	itemsShared bool
+	evictLock   sync.Mutex
+	size        int64
+	dirty       int32
 }
 
 // Creates a new instance of the MemoryPersistence
@@ -81,16 +171,70 @@ func NewMemoryPersistence(prototype reflect.Type) *MemoryPersistence {
 	c := &MemoryPersistence{}
 	c.Prototype = prototype
 	c.Logger = log.NewCompositeLogger()
+	c.Counters = count.NewCompositeCounters()
+	c.Tracer = trace.NewCompositeTracer(nil)
+	c.ComponentName = defaultComponentName(prototype)
 	c.Items = make([]interface{}, 0, 10)
+	c.EvictionPolicy = EvictionFifo
+	c.CloneStrategy = CloneDeep
+	c.IdGenerator = defaultIdGenerator(prototype, nil)
+	c.tracker = newAccessTracker()
 	return c
 }
 
+// Configures component by passing configuration parameters.
+// Parameters:
+//  - config  *config.ConfigParams
+//  configuration parameters to be set.
+func (c *MemoryPersistence) Configure(config *config.ConfigParams) {
+	if config.GetAsBooleanWithDefault("options.copy_on_write", c.copyOnWrite) {
+		c.setShardCount(0)
+		c.setCopyOnWrite(true)
+	} else {
+		c.setCopyOnWrite(false)
+		c.setShardCount(config.GetAsIntegerWithDefault("options.shards", len(c.shards)))
+	}
+	c.MaxItems = config.GetAsIntegerWithDefault("options.max_items", c.MaxItems)
+	c.EvictionPolicy = toEvictionPolicy(config.GetAsStringWithDefault("options.eviction_policy", c.EvictionPolicy))
+	c.tracker.setPolicy(c.EvictionPolicy)
+	c.TenantField = config.GetAsStringWithDefault("options.tenant_field", c.TenantField)
+	c.CloneStrategy = toCloneStrategy(config.GetAsStringWithDefault("options.clone_strategy", c.CloneStrategy))
+	c.PropertyTag = config.GetAsStringWithDefault("options.property_tag", c.PropertyTag)
+	if idFields := config.GetAsString("options.id_field"); idFields != "" {
+		c.IdFields = splitFieldNames(idFields)
+		c.IdGenerator = defaultIdGenerator(c.Prototype, c.IdFields)
+	}
+	if idGenerator := config.GetAsString("options.id_generator"); idGenerator != "" {
+		c.IdGenerator = NewIdGenerator(idGenerator)
+	}
+
+	if textFields := config.GetAsString("options.text_fields"); textFields != "" {
+		c.EnableTextIndex(splitFieldNames(textFields)...)
+	}
+
+	latitudeField := config.GetAsString("options.latitude_field")
+	longitudeField := config.GetAsString("options.longitude_field")
+	if latitudeField != "" && longitudeField != "" {
+		c.EnableGeoIndex(latitudeField, longitudeField,
+			config.GetAsDoubleWithDefault("options.geo_cell_size", DefaultGeoCellSize))
+	}
+}
+
 //  Sets references to dependent components.
 //  Parameters:
 //   - references refer.IReferences
 //   references to locate the component dependencies.
 func (c *MemoryPersistence) SetReferences(references refer.IReferences) {
 	c.Logger.SetReferences(references)
+	c.Counters.SetReferences(references)
+	c.Tracer.SetReferences(references)
+
+	if loader, ok := c.Loader.(refer.IReferenceable); ok {
+		loader.SetReferences(references)
+	}
+	if saver, ok := c.Saver.(refer.IReferenceable); ok && interface{}(c.Saver) != interface{}(c.Loader) {
+		saver.SetReferences(references)
+	}
 }
 
 //  Checks if the component is opened.
@@ -105,37 +249,49 @@ func (c *MemoryPersistence) IsOpen() bool {
 //   (optional) transaction id to trace execution through call chain.
 // Returns  error or null no errors occured.
 func (c *MemoryPersistence) Open(correlationId string) error {
-	c.Lock.Lock()
-	defer c.Lock.Unlock()
+	return c.OpenWithContext(ContextWithCorrelationId(context.Background(), correlationId))
+}
 
-	err := c.load(correlationId)
+// Opens the component.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+// Returns  error or null no errors occured.
+func (c *MemoryPersistence) OpenWithContext(ctx context.Context) error {
+	unlock := c.lockAll()
+	defer unlock()
+
+	err := c.load(ctx)
 	if err == nil {
 		c.opened = true
 	}
 	return err
 }
 
-func (c *MemoryPersistence) load(correlationId string) error {
+func (c *MemoryPersistence) load(ctx context.Context) (err error) {
 	if c.Loader == nil {
 		return nil
 	}
 
-	items, err := c.Loader.Load(correlationId)
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "load")
+	defer func() { timing.end(err) }()
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
+	var items []interface{}
+	if loader, ok := c.Loader.(ILoaderWithContext); ok {
+		items, err = loader.LoadWithContext(ctx)
+	} else {
+		items, err = c.Loader.Load(correlationId)
+	}
 	if err == nil && items != nil {
-		c.Items = items
-		c.Items = make([]interface{}, len(items))
-		for i, v := range items {
-			item := convert.MapConverter.ToNullableMap(v)
-			jsonMarshalStr, errJson := json.Marshal(item)
-			if errJson != nil {
-				panic("MemoryPersistence.Load Error can't convert from Json to type")
-			}
-			value := reflect.New(c.Prototype).Interface()
-			json.Unmarshal(jsonMarshalStr, value)
-			c.Items[i] = reflect.ValueOf(value).Elem().Interface() // load value
-		}
-		length := len(c.Items)
-		c.Logger.Trace(correlationId, "Loaded %d items", length)
+		items = convertToPrototype(items, c.Prototype)
+		c.placeItems(items)
+		c.rebuildState()
+		atomic.StoreInt32(&c.dirty, 0)
+		c.Logger.Trace(correlationId, "Loaded %d items", len(items))
 	}
 	return err
 }
@@ -146,7 +302,16 @@ func (c *MemoryPersistence) load(correlationId string) error {
 //  (optional) transaction id to trace execution through call chain.
 // Retruns: error or nil if no errors occured.
 func (c *MemoryPersistence) Close(correlationId string) error {
-	err := c.Save(correlationId)
+	return c.CloseWithContext(ContextWithCorrelationId(context.Background(), correlationId))
+}
+
+// Closes component and frees used resources.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+// Retruns: error or nil if no errors occured.
+func (c *MemoryPersistence) CloseWithContext(ctx context.Context) error {
+	err := c.SaveWithContext(ctx)
 	c.opened = false
 	return err
 }
@@ -157,17 +322,38 @@ func (c *MemoryPersistence) Close(correlationId string) error {
 //   (optional) transaction id to trace execution through call chain.
 // Return error or null for success.
 func (c *MemoryPersistence) Save(correlationId string) error {
-	c.Lock.RLock()
-	defer c.Lock.RUnlock()
+	return c.SaveWithContext(ContextWithCorrelationId(context.Background(), correlationId))
+}
 
+// Saves items to external data source using configured saver component.
+// Savers that implement ISaverWithContext receive the context.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+// Return error or null for success.
+func (c *MemoryPersistence) SaveWithContext(ctx context.Context) (err error) {
 	if c.Saver == nil {
 		return nil
 	}
 
-	err := c.Saver.Save(correlationId, c.Items)
+	items, unlock := c.readItems()
+	defer unlock()
+
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "save")
+	defer func() { timing.end(err) }()
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
+	if saver, ok := c.Saver.(ISaverWithContext); ok {
+		err = saver.SaveWithContext(ctx, items)
+	} else {
+		err = c.Saver.Save(correlationId, items)
+	}
 	if err == nil {
-		length := len(c.Items)
-		c.Logger.Trace(correlationId, "Saved %d items", length)
+		atomic.StoreInt32(&c.dirty, 0)
+		c.Logger.Trace(correlationId, "Saved %d items", len(items))
 	}
 	return err
 }
@@ -178,13 +364,64 @@ func (c *MemoryPersistence) Save(correlationId string) error {
 //  (optional) transaction id to trace execution through call chain.
 //  Returns error or null no errors occured.
 func (c *MemoryPersistence) Clear(correlationId string) error {
-	c.Lock.Lock()
+	return c.ClearWithContext(ContextWithCorrelationId(context.Background(), correlationId))
+}
+
+// Clears component state.
+// In tenant mode only items of the tenant from the context are removed.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//  Returns error or null no errors occured.
+func (c *MemoryPersistence) ClearWithContext(ctx context.Context) (err error) {
+	return c.clear(ctx, nil)
+}
 
-	c.Items = make([]interface{}, 0, 5)
-	c.Logger.Trace(correlationId, "Cleared items")
+// Removes all items or items of the tenant from the context in tenant mode.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - cleared func(items []interface{})
+//   (optional) a callback called for removed items under write lock.
+// Returns error or nil for success.
+func (c *MemoryPersistence) clear(ctx context.Context, cleared func(items []interface{})) (err error) {
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "clear")
+	defer func() { timing.end(err) }()
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	var tenantId string
+	if tenantId, err = c.getTenantId(ctx); err != nil {
+		return err
+	}
 
-	c.Lock.Unlock()
-	return c.Save(correlationId)
+	unlock := c.lockAll()
+
+	var removed []interface{}
+	if tenantId == "" {
+		removed = c.storedItems()
+		c.placeItems(make([]interface{}, 0, 5))
+		c.rebuildState()
+		c.Logger.Trace(correlationId, "Cleared items")
+	} else {
+		for _, p := range c.partitions() {
+			for i := 0; i < len(*p.items); {
+				if c.belongsToTenant((*p.items)[i], tenantId) {
+					removed = append(removed, c.removeItem(p.items, i))
+				} else {
+					i++
+				}
+			}
+		}
+		c.Logger.Trace(correlationId, "Cleared items of tenant %s", tenantId)
+	}
+	if cleared != nil && len(removed) > 0 {
+		cleared(removed)
+	}
+
+	unlock()
+	return c.SaveWithContext(ctx)
 }
 
 // Gets a page of data items retrieved by a given filter and sorted according to sort parameters.
@@ -205,49 +442,60 @@ func (c *MemoryPersistence) Clear(correlationId string) error {
 // data page or error.
 func (c *MemoryPersistence) GetPageByFilter(correlationId string, filterFunc func(interface{}) bool,
 	paging *cdata.PagingParams, sortFunc func(a, b interface{}) bool, selectFunc func(in interface{}) (out interface{})) (page *cdata.DataPage, err error) {
-	c.Lock.RLock()
-	defer c.Lock.RUnlock()
+	return c.GetPageByFilterWithContext(ContextWithCorrelationId(context.Background(), correlationId), filterFunc, paging, sortFunc, selectFunc)
+}
+
+// Gets a page of data items retrieved by a given filter and sorted according to sort parameters.
+// The scan over items stops when the context is cancelled.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - filter func(interface{}) bool
+//   (optional) a filter function to filter items
+//   - paging *cdata.PagingParams
+//   (optional) paging parameters
+//   - sortFunc func(a, b interface{}) bool
+//   (optional) sorting compare function func Less (a, b interface{}) bool  see sort.Interface Less function
+//   - selectFunc func(in interface{}) (out interface{})
+// (optional) projection parameters
+// Return cdata.DataPage, error
+// data page or error.
+func (c *MemoryPersistence) GetPageByFilterWithContext(ctx context.Context, filterFunc func(interface{}) bool,
+	paging *cdata.PagingParams, sortFunc func(a, b interface{}) bool, selectFunc func(in interface{}) (out interface{})) (page *cdata.DataPage, err error) {
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "get_page_by_filter")
+	defer func() { timing.end(err) }()
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	if filterFunc, err = c.tenantFilter(ctx, filterFunc); err != nil {
+		return nil, err
+	}
+
+	stored, unlock := c.readItems()
+	defer unlock()
 
 	var items []interface{}
 
 	// Apply filtering
 	if filterFunc != nil {
-		for _, v := range c.Items {
+		for i, v := range stored {
+			if err = checkContext(ctx, i); err != nil {
+				return nil, err
+			}
 			if filterFunc(v) {
 				items = append(items, v)
 			}
 		}
 	} else {
-		items = make([]interface{}, len(c.Items))
-		copy(items, c.Items)
-	}
-
-	// Apply sorting
-	if sortFunc != nil {
-		localSort := sorter{items: items, compFunc: sortFunc}
-		sort.Sort(localSort)
+		items = make([]interface{}, len(stored))
+		copy(items, stored)
 	}
 
-	// Extract a page
-	if paging == nil {
-		paging = cdata.NewEmptyPagingParams()
-	}
-	skip := paging.GetSkip(-1)
-	take := paging.GetTake((int64)(c.MaxPageSize))
+	// Apply sorting and extract a page
 	var total int64
-	if paging.Total {
-		total = (int64)(len(items))
-	}
-	if skip > 0 {
-		len := (int64)(len(items))
-		if skip >= len {
-			skip = len
-		}
-		items = items[skip:]
-	}
-	if (int64)(len(items)) >= take {
-		items = items[:take]
-	}
+	items, total = extractPage(items, paging, sortFunc, c.MaxPageSize)
+	timing.size(len(items))
 
 	// Get projection
 	if selectFunc != nil {
@@ -260,7 +508,7 @@ func (c *MemoryPersistence) GetPageByFilter(correlationId string, filterFunc fun
 	// W!
 	for i := 0; i < len(items); i++ {
 		//items[i] = CloneObject(items[i])
-		items[i] = CloneObjectForResult(items[i], c.Prototype)
+		items[i] = c.cloneResult(items[i])
 	}
 
 	page = cdata.NewDataPage(&total, items)
@@ -283,20 +531,51 @@ func (c *MemoryPersistence) GetPageByFilter(correlationId string, filterFunc fun
 // array of items and error
 func (c *MemoryPersistence) GetListByFilter(correlationId string, filterFunc func(interface{}) bool,
 	sortFunc func(a, b interface{}) bool, selectFunc func(in interface{}) (out interface{})) (results []interface{}, err error) {
-	c.Lock.RLock()
-	defer c.Lock.RUnlock()
+	return c.GetListByFilterWithContext(ContextWithCorrelationId(context.Background(), correlationId), filterFunc, sortFunc, selectFunc)
+}
+
+// Gets a list of data items retrieved by a given filter and sorted according to sort parameters.
+// The scan over items stops when the context is cancelled.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - filter func(interface{}) bool
+//   (optional) a filter function to filter items
+//   - sortFunc func(a, b interface{}) bool
+//   (optional) sorting compare function func Less (a, b interface{}) bool  see sort.Interface Less function
+//   - selectFunc func(in interface{}) (out interface{})
+//   (optional) projection parameters
+// Returns  []interface{},  error
+// array of items and error
+func (c *MemoryPersistence) GetListByFilterWithContext(ctx context.Context, filterFunc func(interface{}) bool,
+	sortFunc func(a, b interface{}) bool, selectFunc func(in interface{}) (out interface{})) (results []interface{}, err error) {
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "get_list_by_filter")
+	defer func() { timing.end(err) }()
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	if filterFunc, err = c.tenantFilter(ctx, filterFunc); err != nil {
+		return nil, err
+	}
+
+	stored, unlock := c.readItems()
+	defer unlock()
 
 	// Apply filter
 	if filterFunc != nil {
 		results = make([]interface{}, 0)
-		for _, v := range c.Items {
+		for i, v := range stored {
+			if err = checkContext(ctx, i); err != nil {
+				return nil, err
+			}
 			if filterFunc(v) {
 				results = append(results, v)
 			}
 		}
 	} else {
-		results = make([]interface{}, len(c.Items))
-		copy(results, c.Items)
+		results = make([]interface{}, len(stored))
+		copy(results, stored)
 	}
 
 	// Apply sorting
@@ -304,6 +583,7 @@ func (c *MemoryPersistence) GetListByFilter(correlationId string, filterFunc fun
 		localSort := sorter{items: results, compFunc: sortFunc}
 		sort.Sort(localSort)
 	}
+	timing.size(len(results))
 
 	// Get projection
 	if selectFunc != nil {
@@ -316,7 +596,7 @@ func (c *MemoryPersistence) GetListByFilter(correlationId string, filterFunc fun
 	//W!
 	for i := 0; i < len(results); i++ {
 		//results[i] = CloneObject(results[i])
-		results[i] = CloneObjectForResult(results[i], c.Prototype)
+		results[i] = c.cloneResult(results[i])
 	}
 	return results, nil
 }
@@ -332,20 +612,46 @@ func (c *MemoryPersistence) GetListByFilter(correlationId string, filterFunc fun
 // Returns: interface{}, error
 // random item or error.
 func (c *MemoryPersistence) GetOneRandom(correlationId string, filterFunc func(interface{}) bool) (result interface{}, err error) {
-	c.Lock.RLock()
-	defer c.Lock.RUnlock()
+	return c.GetOneRandomWithContext(ContextWithCorrelationId(context.Background(), correlationId), filterFunc)
+}
+
+// Gets a random item from items that match to a given filter.
+// The scan over items stops when the context is cancelled.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - filter   func(interface{}) bool
+//   (optional) a filter function to filter items.
+// Returns: interface{}, error
+// random item or error.
+func (c *MemoryPersistence) GetOneRandomWithContext(ctx context.Context, filterFunc func(interface{}) bool) (result interface{}, err error) {
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "get_one_random")
+	defer func() { timing.end(err) }()
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	if filterFunc, err = c.tenantFilter(ctx, filterFunc); err != nil {
+		return nil, err
+	}
+
+	stored, unlock := c.readItems()
+	defer unlock()
 
 	var items []interface{}
 
 	// Apply filter
 	if filterFunc != nil {
-		for _, v := range c.Items {
+		for i, v := range stored {
+			if err = checkContext(ctx, i); err != nil {
+				return nil, err
+			}
 			if filterFunc(v) {
 				items = append(items, v)
 			}
 		}
 	} else {
-		copy(items, c.Items)
+		copy(items, stored)
 	}
 	rand.Seed(time.Now().UnixNano())
 
@@ -360,7 +666,7 @@ func (c *MemoryPersistence) GetOneRandom(correlationId string, filterFunc func(i
 		c.Logger.Trace(correlationId, "Nothing to return as random item")
 	}
 	//result = CloneObject(item)
-	result = CloneObjectForResult(item, c.Prototype)
+	result = c.cloneResult(item)
 	return result, nil
 }
 
@@ -373,16 +679,44 @@ func (c *MemoryPersistence) GetOneRandom(correlationId string, filterFunc func(i
 // Returns:  interface{}, error
 // created item or error.
 func (c *MemoryPersistence) Create(correlationId string, item interface{}) (result interface{}, err error) {
-	c.Lock.Lock()
+	return c.CreateWithContext(ContextWithCorrelationId(context.Background(), correlationId), item)
+}
+
+// Creates a data item.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - item  string
+//   an item to be created.
+// Returns:  interface{}, error
+// created item or error.
+func (c *MemoryPersistence) CreateWithContext(ctx context.Context, item interface{}) (result interface{}, err error) {
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "create")
+	defer func() { timing.end(err) }()
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	var tenantId string
+	if tenantId, err = c.getTenantId(ctx); err != nil {
+		return nil, err
+	}
+
+	newItem := c.cloneItem(item)
+	c.stampTenant(&newItem, tenantId)
+	key := c.itemKey(newItem)
 
-	newItem := CloneObject(item, c.Prototype)
-	c.Items = append(c.Items, newItem)
+	p := c.partition(key)
+	p.lock.Lock()
+	c.appendItem(p.items, newItem)
+	p.lock.Unlock()
 
-	c.Lock.Unlock()
 	c.Logger.Trace(correlationId, "Created item")
+	evicted := c.evict(key, nil)
+	c.notifyEvicted(correlationId, evicted)
 
-	errsave := c.Save(correlationId)
-	result = CloneObjectForResult(newItem, c.Prototype)
+	errsave := c.SaveWithContext(ctx)
+	result = c.cloneResult(newItem)
 
 	return result, errsave
 }
@@ -398,31 +732,76 @@ func (c *MemoryPersistence) Create(correlationId string, item interface{}) (resu
 // Retruns: error
 // error or nil for success.
 func (c *MemoryPersistence) DeleteByFilter(correlationId string, filterFunc func(interface{}) bool) (err error) {
-	c.Lock.Lock()
+	return c.DeleteByFilterWithContext(ContextWithCorrelationId(context.Background(), correlationId), filterFunc)
+}
 
-	deleted := 0
-	for i := 0; i < len(c.Items); {
-		if filterFunc(c.Items[i]) {
-			if i == len(c.Items)-1 {
-				c.Items = c.Items[:i]
-			} else {
-				c.Items = append(c.Items[:i], c.Items[i+1:]...)
+// Deletes data items that match to a given filter.
+// The context is checked before items are deleted, so deletion is never interrupted halfway.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - filter  filter func(interface{}) bool
+//   (optional) a filter function to filter items.
+// Retruns: error
+// error or nil for success.
+func (c *MemoryPersistence) DeleteByFilterWithContext(ctx context.Context, filterFunc func(interface{}) bool) (err error) {
+	_, err = c.deleteByFilter(ctx, filterFunc, nil)
+	return err
+}
+
+// Deletes data items that match to a given filter and saves the rest.
+// The beforeDelete callback receives matched items under the write lock
+// before they are removed and cancels deletion by returning an error.
+// Returns deleted items or error.
+func (c *MemoryPersistence) deleteByFilter(ctx context.Context, filterFunc func(interface{}) bool,
+	beforeDelete func(items []interface{}) error) (deleted []interface{}, err error) {
+
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "delete_by_filter")
+	defer func() { timing.end(err) }()
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	if filterFunc, err = c.tenantFilter(ctx, filterFunc); err != nil {
+		return nil, err
+	}
+
+	unlock := c.lockAll()
+
+	partitions := c.partitions()
+	positions := make([][]int, len(partitions))
+	deleted = make([]interface{}, 0)
+	for k, p := range partitions {
+		for i, v := range *p.items {
+			if filterFunc(v) {
+				positions[k] = append(positions[k], i)
+				deleted = append(deleted, v)
 			}
-			deleted++
-		} else {
-			i++
 		}
 	}
-	c.Lock.Unlock()
+	if beforeDelete != nil && len(deleted) > 0 {
+		if err = beforeDelete(deleted); err != nil {
+			unlock()
+			return nil, err
+		}
+	}
+	// Items are removed from the end, so positions of the rest do not shift
+	for k, p := range partitions {
+		for i := len(positions[k]) - 1; i >= 0; i-- {
+			c.removeItem(p.items, positions[k][i])
+		}
+	}
 
-	if deleted == 0 {
-		return nil
+	unlock()
+
+	if len(deleted) == 0 {
+		return deleted, nil
 	}
 
-	c.Logger.Trace(correlationId, "Deleted %s items", deleted)
+	c.Logger.Trace(correlationId, "Deleted %d items", len(deleted))
 
-	errsave := c.Save(correlationId)
-	return errsave
+	errsave := c.SaveWithContext(ctx)
+	return deleted, errsave
 }
 
 // Gets a count of data items retrieved by a given filter.
@@ -436,12 +815,38 @@ func (c *MemoryPersistence) DeleteByFilter(correlationId string, filterFunc func
 // Return int, error
 // data count or error.
 func (c *MemoryPersistence) GetCountByFilter(correlationId string, filterFunc func(interface{}) bool) (count int64, err error) {
-	c.Lock.RLock()
-	defer c.Lock.RUnlock()
+	return c.GetCountByFilterWithContext(ContextWithCorrelationId(context.Background(), correlationId), filterFunc)
+}
+
+// Gets a count of data items retrieved by a given filter.
+// The scan over items stops when the context is cancelled.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//  - filter func(interface{}) bool
+//  (optional) a filter function to filter items
+// Return int, error
+// data count or error.
+func (c *MemoryPersistence) GetCountByFilterWithContext(ctx context.Context, filterFunc func(interface{}) bool) (count int64, err error) {
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "get_count_by_filter")
+	defer func() { timing.end(err) }()
+	if err = ctx.Err(); err != nil {
+		return 0, err
+	}
+	if filterFunc, err = c.tenantFilter(ctx, filterFunc); err != nil {
+		return 0, err
+	}
+
+	stored, unlock := c.readItems()
+	defer unlock()
 
 	// Apply filtering
 	if filterFunc != nil {
-		for _, v := range c.Items {
+		for i, v := range stored {
+			if err = checkContext(ctx, i); err != nil {
+				return 0, err
+			}
 			if filterFunc(v) {
 				count++
 			}
@@ -452,3 +857,195 @@ func (c *MemoryPersistence) GetCountByFilter(correlationId string, filterFunc fu
 	c.Logger.Trace(correlationId, "Find %d items", count)
 	return count, nil
 }
+
+// Evicts extra items when the number of items exceeds MaxItems.
+// Victims are chosen by the access tracker and removed under write lock
+// of their partitions one by one. Items that are not tracked, like items
+// without ids, are evicted from the beginning of partitions when the tracker
+// runs out of victims. Evictions are serialized, so concurrent writers
+// do not evict more items than needed.
+// The method shall be called after the lock of added items is released.
+// Parameters:
+//   - keep string
+//   (optional) key of just added item that shall not be evicted.
+//   - removed func(item interface{})
+//   (optional) a callback called for each evicted item under write lock of its partition.
+// Returns a list of evicted items.
+func (c *MemoryPersistence) evict(keep string, removed func(item interface{})) []interface{} {
+	if c.MaxItems <= 0 {
+		return nil
+	}
+
+	c.evictLock.Lock()
+	defer c.evictLock.Unlock()
+
+	count := int(atomic.LoadInt64(&c.size)) - c.MaxItems
+	if count <= 0 {
+		return nil
+	}
+
+	var evicted []interface{}
+	remove := func(p itemPartition, position int) {
+		item := c.removeItem(p.items, position)
+		if removed != nil {
+			removed(item)
+		}
+		evicted = append(evicted, item)
+	}
+
+	for _, key := range c.tracker.findVictims(count, c.EvictionPolicy, keep) {
+		p := c.partition(key)
+		p.lock.Lock()
+		for i, v := range *p.items {
+			if c.itemKey(v) == key {
+				remove(p, i)
+				break
+			}
+		}
+		p.lock.Unlock()
+	}
+
+	for _, p := range c.partitions() {
+		if len(evicted) >= count {
+			break
+		}
+		p.lock.Lock()
+		for i := 0; i < len(*p.items) && len(evicted) < count; {
+			key := c.itemKey((*p.items)[i])
+			if (keep == "" || key != keep) && !c.tracker.has(key) {
+				remove(p, i)
+			} else {
+				i++
+			}
+		}
+		p.lock.Unlock()
+	}
+	return evicted
+}
+
+// Reports evicted items to the logger and OnEvicted callback.
+// The method shall be called after the lock is released.
+func (c *MemoryPersistence) notifyEvicted(correlationId string, evicted []interface{}) {
+	for _, item := range evicted {
+		id := c.getId(item)
+		if id != nil {
+			c.Logger.Debug(correlationId, "Evicted item %v using %s policy", id, c.EvictionPolicy)
+		} else {
+			c.Logger.Debug(correlationId, "Evicted item using %s policy", c.EvictionPolicy)
+		}
+		if c.OnEvicted != nil {
+			c.OnEvicted(correlationId, c.cloneResult(item))
+		}
+	}
+}
+
+// Rebuilds state derived from stored items after they were replaced as a whole.
+// The method shall be called under write lock of all partitions.
+func (c *MemoryPersistence) rebuildState() {
+	c.tracker.clear()
+	c.rebuildIndexes()
+	items := c.storedItems()
+	for _, item := range items {
+		c.tracker.track(c.itemKey(item))
+		c.observeId(item)
+	}
+	atomic.StoreInt64(&c.size, int64(len(items)))
+	c.countItems()
+	c.markDirty()
+}
+
+// Marks items as changed after the last save.
+func (c *MemoryPersistence) markDirty() {
+	atomic.StoreInt32(&c.dirty, 1)
+}
+
+// Checks if items were changed after they were saved or loaded last time.
+// Returns true if there are unsaved changes.
+func (c *MemoryPersistence) IsDirty() bool {
+	return atomic.LoadInt32(&c.dirty) != 0
+}
+
+// Gets the number of stored items.
+// Returns int
+// number of items.
+func (c *MemoryPersistence) GetItemCount() int {
+	if c.copyOnWrite {
+		return len(c.publishedItems())
+	}
+
+	unlock := c.rlockAll()
+	defer unlock()
+
+	count := 0
+	for _, p := range c.partitions() {
+		count += len(*p.items)
+	}
+	return count
+}
+
+// Captures current state of the persistence.
+// The snapshot includes items of all tenants in tenant mode.
+// Parameters:
+//   - correlationId string
+//   (optional) transaction id to trace execution through call chain.
+// Returns *MemorySnapshot
+// an immutable deep copy of stored items.
+func (c *MemoryPersistence) Snapshot(correlationId string) *MemorySnapshot {
+	items, unlock := c.readItems()
+	defer unlock()
+
+	snapshot := newMemorySnapshot(items, c.Prototype)
+	c.Logger.Trace(correlationId, "Captured snapshot with %d items", snapshot.Len())
+	return snapshot
+}
+
+// Restores state of the persistence from a snapshot.
+// All stored items are replaced atomically and the result is saved
+// using configured saver component. Restore ignores tenant mode:
+// items of all tenants are replaced by items of the snapshot.
+// When the snapshot has more than MaxItems items, extra items are evicted
+// according to the eviction policy, starting from the first items of the snapshot.
+// Parameters:
+//   - correlationId string
+//   (optional) transaction id to trace execution through call chain.
+//   - snapshot *MemorySnapshot
+//   a snapshot previously captured by Snapshot or loaded by LoadMemorySnapshot.
+// Returns error or nil for success.
+func (c *MemoryPersistence) Restore(correlationId string, snapshot *MemorySnapshot) error {
+	return c.restore(correlationId, snapshot, nil, nil)
+}
+
+// Replaces stored items with items of a snapshot.
+// Parameters:
+//   - correlationId string
+//   (optional) transaction id to trace execution through call chain.
+//   - snapshot *MemorySnapshot
+//   a snapshot to restore.
+//   - replaced func(oldItems []interface{}, newItems []interface{})
+//   (optional) a callback called for replaced and restored items under write lock.
+//   - removed func(item interface{})
+//   (optional) a callback called for each evicted item, see evict.
+// Returns error or nil for success.
+func (c *MemoryPersistence) restore(correlationId string, snapshot *MemorySnapshot,
+	replaced func(oldItems []interface{}, newItems []interface{}), removed func(item interface{})) error {
+	if snapshot == nil {
+		return errors.NewBadRequestError(correlationId, "NO_SNAPSHOT", "Snapshot is not set")
+	}
+
+	unlock := c.lockAll()
+
+	oldItems := c.storedItems()
+	items := snapshot.Items()
+	c.placeItems(items)
+	c.rebuildState()
+	if replaced != nil {
+		replaced(oldItems, items)
+	}
+
+	unlock()
+	c.Logger.Trace(correlationId, "Restored %d items from snapshot", len(items))
+	evicted := c.evict("", removed)
+	c.notifyEvicted(correlationId, evicted)
+
+	return c.Save(correlationId)
+}
@@ -0,0 +1,166 @@
+package persistence
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+/*
+Shard of items stored by MemoryPersistence in sharded mode.
+Each shard has its own lock, so operations by id on different shards
+do not block each other.
+*/
+type itemShard struct {
+	lock  sync.RWMutex
+	items []interface{}
+}
+
+/*
+Items and lock of a storage partition of MemoryPersistence:
+Items and Lock of the persistence in default and copy-on-write modes
+or a shard in sharded mode.
+Partitions are computed on every call and never stored, so copies
+of the persistence struct do not point to fields of the original.
+*/
+type itemPartition struct {
+	lock  partitionLock
+	items *[]interface{}
+}
+
+// Checks if items are partitioned into shards.
+// Returns true in sharded mode.
+func (c *MemoryPersistence) isSharded() bool {
+	return len(c.shards) > 0
+}
+
+// Gets the number of shards.
+// Returns int
+// the number of shards or 1 in default mode.
+func (c *MemoryPersistence) ShardCount() int {
+	if len(c.shards) == 0 {
+		return 1
+	}
+	return len(c.shards)
+}
+
+// Gets the partition that stores the item with specified key, see tenantKey.
+func (c *MemoryPersistence) partition(key string) itemPartition {
+	if len(c.shards) == 0 {
+		return c.defaultPartition()
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	shard := c.shards[hash.Sum32()%uint32(len(c.shards))]
+	return itemPartition{lock: &shard.lock, items: &shard.items}
+}
+
+// Gets the partition of Items used in default and copy-on-write modes.
+func (c *MemoryPersistence) defaultPartition() itemPartition {
+	if c.copyOnWrite {
+		return itemPartition{lock: copyOnWriteLock{persistence: c}, items: &c.Items}
+	}
+	return itemPartition{lock: &c.Lock, items: &c.Items}
+}
+
+// Gets all partitions in the order they are locked.
+func (c *MemoryPersistence) partitions() []itemPartition {
+	if len(c.shards) == 0 {
+		return []itemPartition{c.defaultPartition()}
+	}
+	partitions := make([]itemPartition, len(c.shards))
+	for i, shard := range c.shards {
+		partitions[i] = itemPartition{lock: &shard.lock, items: &shard.items}
+	}
+	return partitions
+}
+
+// Locks all partitions for writing in order.
+// Returns a function that unlocks them.
+func (c *MemoryPersistence) lockAll() func() {
+	partitions := c.partitions()
+	for _, p := range partitions {
+		p.lock.Lock()
+	}
+	return func() {
+		for i := len(partitions) - 1; i >= 0; i-- {
+			partitions[i].lock.Unlock()
+		}
+	}
+}
+
+// Locks all partitions for reading in order.
+// Returns a function that unlocks them.
+func (c *MemoryPersistence) rlockAll() func() {
+	partitions := c.partitions()
+	for _, p := range partitions {
+		p.lock.RLock()
+	}
+	return func() {
+		for i := len(partitions) - 1; i >= 0; i-- {
+			partitions[i].lock.RUnlock()
+		}
+	}
+}
+
+// Gets all stored items. In default mode it returns Items,
+// in sharded mode items of all shards are copied into a new slice once.
+// The method shall be called under lock of all partitions.
+func (c *MemoryPersistence) storedItems() []interface{} {
+	if len(c.shards) == 0 {
+		return c.Items
+	}
+	count := 0
+	for _, shard := range c.shards {
+		count += len(shard.items)
+	}
+	items := make([]interface{}, 0, count)
+	for _, shard := range c.shards {
+		items = append(items, shard.items...)
+	}
+	return items
+}
+
+// Replaces stored items by placing each item into its partition.
+// The method shall be called under lock of all partitions.
+func (c *MemoryPersistence) placeItems(items []interface{}) {
+	if len(c.shards) == 0 {
+		c.Items = items
+		c.itemsShared = false
+		return
+	}
+	for _, shard := range c.shards {
+		shard.items = make([]interface{}, 0)
+	}
+	for _, item := range items {
+		p := c.partition(c.itemKey(item))
+		*p.items = append(*p.items, item)
+	}
+}
+
+// Switches between default and sharded modes and moves stored items to new partitions.
+// Parameters:
+//   - count int
+//   the number of shards, values less than 2 switch to default mode.
+func (c *MemoryPersistence) setShardCount(count int) {
+	if count < 2 {
+		count = 0
+	}
+	if count == len(c.shards) {
+		return
+	}
+
+	unlock := c.lockAll()
+	defer unlock()
+
+	items := c.storedItems()
+	if count == 0 {
+		c.shards = nil
+	} else {
+		c.shards = make([]*itemShard, count)
+		for i := range c.shards {
+			c.shards[i] = &itemShard{items: make([]interface{}, 0)}
+		}
+		c.Items = make([]interface{}, 0)
+	}
+	c.placeItems(items)
+}
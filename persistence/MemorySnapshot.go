@@ -0,0 +1,97 @@
+package persistence
+
+import (
+	"reflect"
+	"time"
+)
+
+/*
+Immutable copy of items stored in MemoryPersistence.
+
+Snapshots are captured by MemoryPersistence.Snapshot and restored by
+MemoryPersistence.Restore. They hold deep copies of the items, so later
+changes in the persistence do not affect captured state and vice versa.
+Snapshots can be written and read using any ISaver and ILoader components,
+for instance JsonFilePersister, in the same format as persisted data.
+
+Example
+
+  snapshot := persistence.Snapshot("123")
+  ...
+  err := snapshot.Save("123", NewJsonFilePersister(prototype, "./data/backup.json"))
+  ...
+  err = persistence.Restore("123", snapshot)
+*/
+type MemorySnapshot struct {
+	created   time.Time
+	items     []interface{}
+	prototype reflect.Type
+}
+
+func newMemorySnapshot(items []interface{}, prototype reflect.Type) *MemorySnapshot {
+	c := &MemorySnapshot{
+		created:   time.Now().UTC(),
+		items:     make([]interface{}, len(items)),
+		prototype: prototype,
+	}
+	for i, v := range items {
+		c.items[i] = CloneObject(v, prototype)
+	}
+	return c
+}
+
+// Loads a snapshot using a loader component.
+// Parameters:
+//   - correlationId string
+//   (optional) transaction id to trace execution through call chain.
+//   - prototype reflect.Type
+//   type of contained data
+//   - loader ILoader
+//   a loader to read snapshot items.
+// Returns *MemorySnapshot, error
+// loaded snapshot or error.
+func LoadMemorySnapshot(correlationId string, prototype reflect.Type, loader ILoader) (*MemorySnapshot, error) {
+	items, err := loader.Load(correlationId)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &MemorySnapshot{
+		created:   time.Now().UTC(),
+		items:     convertToPrototype(items, prototype),
+		prototype: prototype,
+	}
+	return c, nil
+}
+
+// Gets the time when the snapshot was created.
+func (c *MemorySnapshot) Created() time.Time {
+	return c.created
+}
+
+// Gets the number of items in the snapshot.
+func (c *MemorySnapshot) Len() int {
+	return len(c.items)
+}
+
+// Gets copies of the snapshot items.
+// Returns []interface{}
+// a list of items that can be safely modified by the caller.
+func (c *MemorySnapshot) Items() []interface{} {
+	result := make([]interface{}, len(c.items))
+	for i, v := range c.items {
+		result[i] = CloneObject(v, c.prototype)
+	}
+	return result
+}
+
+// Saves the snapshot items using a saver component.
+// Parameters:
+//   - correlationId string
+//   (optional) transaction id to trace execution through call chain.
+//   - saver ISaver
+//   a saver to write snapshot items.
+// Returns error or nil for success.
+func (c *MemorySnapshot) Save(correlationId string, saver ISaver) error {
+	return saver.Save(correlationId, c.Items())
+}
@@ -0,0 +1,124 @@
+package persistence
+
+import (
+	"context"
+)
+
+// Collects references to items that match to a given filter.
+// The persistence replaces stored items instead of changing them in place,
+// so references stay valid after the lock is released. With CloneNone strategy
+// stored items are values of the caller, and changes the caller makes to them
+// are seen through the references as well.
+func (c *MemoryPersistence) collectByFilter(ctx context.Context, filterFunc func(interface{}) bool) ([]interface{}, error) {
+	filterFunc, err := c.tenantFilter(ctx, filterFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, unlock := c.readItems()
+	defer unlock()
+
+	items := make([]interface{}, 0)
+	for i, v := range stored {
+		if err := checkContext(ctx, i); err != nil {
+			return nil, err
+		}
+		if filterFunc == nil || filterFunc(v) {
+			items = append(items, v)
+		}
+	}
+	return items, nil
+}
+
+// Iterates over data items that match to a given filter.
+// Unlike GetListByFilter it does not build a list of cloned items:
+// each item is cloned by the clone strategy right before it is passed to the callback.
+// The lock is held only while items are filtered, so the callback
+// may call other methods of the persistence.
+// Parameters:
+//   - correlationId string
+//   (optional) transaction id to trace execution through call chain.
+//   - filterFunc func(interface{}) bool
+//   (optional) a filter function to filter items
+//   - callback func(item interface{}) bool
+//   a function called for each item, it returns false to stop iteration.
+// Returns error
+// error or nil for success.
+func (c *MemoryPersistence) ForEachByFilter(correlationId string, filterFunc func(interface{}) bool,
+	callback func(item interface{}) bool) (err error) {
+	return c.ForEachByFilterWithContext(ContextWithCorrelationId(context.Background(), correlationId), filterFunc, callback)
+}
+
+// Iterates over data items that match to a given filter.
+// Iteration stops with the context error when the context is cancelled.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - filterFunc func(interface{}) bool
+//   (optional) a filter function to filter items
+//   - callback func(item interface{}) bool
+//   a function called for each item, it returns false to stop iteration.
+// Returns error
+// error or nil for success.
+func (c *MemoryPersistence) ForEachByFilterWithContext(ctx context.Context, filterFunc func(interface{}) bool,
+	callback func(item interface{}) bool) (err error) {
+
+	correlationId := CorrelationIdFromContext(ctx)
+	items, err := c.collectByFilter(ctx, filterFunc)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	for _, v := range items {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		count++
+		if !callback(c.cloneResult(v)) {
+			break
+		}
+	}
+
+	c.Logger.Trace(correlationId, "Iterated over %d of %d items", count, len(items))
+	return nil
+}
+
+// Streams data items that match to a given filter through a channel.
+// Items are cloned by the clone strategy one at a time as they are read from the channel.
+// The channel is closed after the last item or when the context is cancelled.
+// The streaming goroutine blocks until the next item is read, so consumers
+// that stop reading before the end of the stream must cancel the context.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - filterFunc func(interface{}) bool
+//   (optional) a filter function to filter items
+// Returns <-chan interface{}, error
+// a channel with items or error.
+func (c *MemoryPersistence) StreamByFilter(ctx context.Context, filterFunc func(interface{}) bool) (<-chan interface{}, error) {
+	correlationId := CorrelationIdFromContext(ctx)
+	items, err := c.collectByFilter(ctx, filterFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := make(chan interface{})
+	go func() {
+		defer close(stream)
+
+		count := 0
+		for _, v := range items {
+			select {
+			case <-ctx.Done():
+				c.Logger.Trace(correlationId, "Stream was cancelled after %d of %d items", count, len(items))
+				return
+			case stream <- c.cloneResult(v):
+				count++
+			}
+		}
+		c.Logger.Trace(correlationId, "Streamed %d items", count)
+	}()
+
+	return stream, nil
+}
@@ -0,0 +1,120 @@
+package persistence
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pip-services3-go/pip-services3-commons-go/convert"
+	"github.com/pip-services3-go/pip-services3-commons-go/errors"
+)
+
+// Gets the tenant id for an operation in tenant mode.
+// Returns empty string when tenant mode is disabled
+// and BadRequestError when the context carries no tenant id.
+func (c *MemoryPersistence) getTenantId(ctx context.Context) (string, error) {
+	if c.TenantField == "" {
+		return "", nil
+	}
+
+	tenantId := TenantIdFromContext(ctx)
+	if tenantId == "" {
+		return "", errors.NewBadRequestError(CorrelationIdFromContext(ctx), "NO_TENANT",
+			"Tenant id is not set in context").WithDetails("tenant_field", c.TenantField)
+	}
+	return tenantId, nil
+}
+
+// Gets the tenant id of an item or empty string when tenant mode is disabled
+func (c *MemoryPersistence) getItemTenantId(item interface{}) string {
+	if c.TenantField == "" {
+		return ""
+	}
+	return convert.StringConverter.ToString(c.getProperty(item, c.TenantField))
+}
+
+// Checks if an item belongs to a tenant. All items belong to the empty tenant.
+func (c *MemoryPersistence) belongsToTenant(item interface{}, tenantId string) bool {
+	if tenantId == "" {
+		return true
+	}
+	return c.getItemTenantId(item) == tenantId
+}
+
+// Gets the key of an item id within a tenant used by indexes, access tracking and history.
+// In tenant mode keys start with the quoted tenant id, so equal ids of different tenants do not collide.
+func (c *MemoryPersistence) tenantKey(tenantId string, id interface{}) string {
+	key := toIdKey(id)
+	if c.TenantField == "" || key == "" {
+		return key
+	}
+	return strconv.Quote(tenantId) + key
+}
+
+// Gets the key of a stored item, see tenantKey
+func (c *MemoryPersistence) itemKey(item interface{}) string {
+	return c.tenantKey(c.getItemTenantId(item), c.getId(item))
+}
+
+// Checks if a key returned by tenantKey belongs to a tenant. All keys belong to the empty tenant.
+func (c *MemoryPersistence) isTenantKey(key string, tenantId string) bool {
+	if c.TenantField == "" || tenantId == "" {
+		return true
+	}
+	return strings.HasPrefix(key, strconv.Quote(tenantId))
+}
+
+// Restricts a filter function to items of the tenant from the context.
+// The filter is returned unchanged when tenant mode is disabled.
+func (c *MemoryPersistence) tenantFilter(ctx context.Context,
+	filterFunc func(interface{}) bool) (func(interface{}) bool, error) {
+
+	tenantId, err := c.getTenantId(ctx)
+	if err != nil || tenantId == "" {
+		return filterFunc, err
+	}
+
+	return func(item interface{}) bool {
+		return c.belongsToTenant(item, tenantId) && (filterFunc == nil || filterFunc(item))
+	}, nil
+}
+
+// Sets the tenant id to a data item.
+// The item shall be already cloned, since structs are replaced in place.
+func (c *MemoryPersistence) stampTenant(item *interface{}, tenantId string) {
+	if tenantId == "" {
+		return
+	}
+
+	value := *item
+	if reflect.ValueOf(value).Kind() == reflect.Map {
+		c.setProperty(value, c.TenantField, tenantId)
+	} else {
+		typePointer := reflect.New(reflect.TypeOf(value))
+		typePointer.Elem().Set(reflect.ValueOf(value))
+		typeInterface := typePointer.Interface()
+		c.setProperty(typeInterface, c.TenantField, tenantId)
+		*item = reflect.ValueOf(typeInterface).Elem().Interface()
+	}
+}
+
+// Creates NotFoundError returned in tenant mode for items
+// that do not exist or belong to other tenants.
+func newTenantItemNotFoundError(correlationId string, id interface{}) error {
+	return errors.NewNotFoundError(correlationId, "ITEM_NOT_FOUND",
+		"Item "+toIdKey(id)+" was not found").WithDetails("id", id)
+}
+
+// Gets index of an item with the id that belongs to a tenant among items of a partition.
+// Items of other tenants with the same id are skipped.
+// The method shall be called under lock of the partition.
+func (c *IdentifiableMemoryPersistence) getTenantIndexById(items []interface{}, id interface{}, tenantId string) int {
+	id = c.toKey(id)
+	for i, v := range items {
+		if c.hasId(v, id) && c.belongsToTenant(v, tenantId) {
+			return i
+		}
+	}
+	return -1
+}
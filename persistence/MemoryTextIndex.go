@@ -0,0 +1,302 @@
+package persistence
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/pip-services3-go/pip-services3-commons-go/convert"
+	"github.com/pip-services3-go/pip-services3-commons-go/errors"
+)
+
+/*
+Inverted index over text properties of items used by MemoryPersistence.MatchText.
+Text is split into tokens on characters other than letters and digits
+and tokens are folded to lower case.
+*/
+type textIndex struct {
+	fields []string
+	// Struct tag with property names, see GetPropertyWithTag
+	tag string
+	// Term frequencies of tokens by item keys
+	postings map[string]map[string]int
+	// Indexed tokens in sorted order used to find tokens by prefix
+	terms []string
+	// Tokens of each item used to remove it from the index
+	tokens map[string][]string
+}
+
+func newTextIndex(fields []string, tag string) *textIndex {
+	c := &textIndex{fields: fields, tag: tag}
+	c.clear()
+	return c
+}
+
+func (c *textIndex) add(key string, item interface{}) {
+	tokens := make([]string, 0)
+	for _, field := range c.fields {
+		tokens = appendTextTokens(tokens, GetPropertyWithTag(item, field, c.tag))
+	}
+
+	c.tokens[key] = tokens
+	for _, token := range tokens {
+		keys, ok := c.postings[token]
+		if !ok {
+			keys = make(map[string]int)
+			c.postings[token] = keys
+			c.insertTerm(token)
+		}
+		keys[key]++
+	}
+}
+
+func (c *textIndex) remove(key string, item interface{}) {
+	for _, token := range c.tokens[key] {
+		keys := c.postings[token]
+		if keys == nil {
+			continue
+		}
+		if keys[key] > 1 {
+			keys[key]--
+		} else {
+			delete(keys, key)
+		}
+		if len(keys) == 0 {
+			delete(c.postings, token)
+			c.removeTerm(token)
+		}
+	}
+	delete(c.tokens, key)
+}
+
+func (c *textIndex) clear() {
+	c.postings = make(map[string]map[string]int)
+	c.terms = nil
+	c.tokens = make(map[string][]string)
+}
+
+func (c *textIndex) insertTerm(token string) {
+	i := sort.SearchStrings(c.terms, token)
+	c.terms = append(c.terms, "")
+	copy(c.terms[i+1:], c.terms[i:])
+	c.terms[i] = token
+}
+
+func (c *textIndex) removeTerm(token string) {
+	i := sort.SearchStrings(c.terms, token)
+	if i < len(c.terms) && c.terms[i] == token {
+		c.terms = append(c.terms[:i], c.terms[i+1:]...)
+	}
+}
+
+// Finds items that contain all tokens of the search string.
+// A token matches indexed tokens equal to it or starting with it.
+// Exact matches weigh twice as much as prefix matches.
+// Returns relevance scores by item keys.
+func (c *textIndex) search(search string) map[string]float64 {
+	var scores map[string]float64
+	for _, queryToken := range appendTextTokens(nil, search) {
+		tokenScores := make(map[string]float64)
+		// Tokens starting with the query token follow it in the sorted terms
+		for i := sort.SearchStrings(c.terms, queryToken); i < len(c.terms); i++ {
+			token := c.terms[i]
+			if !strings.HasPrefix(token, queryToken) {
+				break
+			}
+			weight := 1.0
+			if token == queryToken {
+				weight = 2
+			}
+			for key, count := range c.postings[token] {
+				tokenScores[key] += weight * float64(count)
+			}
+		}
+
+		if scores == nil {
+			scores = tokenScores
+			continue
+		}
+		for key, score := range scores {
+			if tokenScore, ok := tokenScores[key]; ok {
+				scores[key] = score + tokenScore
+			} else {
+				delete(scores, key)
+			}
+		}
+	}
+
+	if scores == nil {
+		scores = make(map[string]float64)
+	}
+	return scores
+}
+
+// Splits text values into lower case tokens and appends them to the list.
+// Slices and arrays are split element by element.
+func appendTextTokens(tokens []string, value interface{}) []string {
+	if value == nil {
+		return tokens
+	}
+
+	val := reflect.ValueOf(value)
+	if (val.Kind() == reflect.Slice || val.Kind() == reflect.Array) && val.Type().Elem().Kind() != reflect.Uint8 {
+		for i := 0; i < val.Len(); i++ {
+			tokens = appendTextTokens(tokens, val.Index(i).Interface())
+		}
+		return tokens
+	}
+
+	text := strings.ToLower(convert.StringConverter.ToString(value))
+	return append(tokens, strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})...)
+}
+
+/*
+Result of a full-text search returned by MemoryPersistence.MatchText.
+
+Filter and Less methods can be passed to GetPageByFilter or GetListByFilter
+as filter and sort functions to get matched items ordered by relevance.
+An empty search string matches all items.
+
+Example
+
+    match, err := persistence.MatchText(correlationId, "quick fox")
+    page, err := persistence.GetPageByFilter(correlationId, match.Filter, paging, match.Less, nil)
+*/
+type TextMatch struct {
+	scores map[string]float64
+	getKey func(item interface{}) string
+}
+
+// Checks if an item matches the search string.
+// Parameters:
+//   - item interface{}
+//   an item to check.
+// Returns bool
+// true if the item matches.
+func (c *TextMatch) Filter(item interface{}) bool {
+	if c.scores == nil {
+		return true
+	}
+	_, ok := c.scores[c.getKey(item)]
+	return ok
+}
+
+// Compares relevance of two items to sort more relevant items first.
+// Parameters:
+//   - a interface{}
+//   the first item.
+//   - b interface{}
+//   the second item.
+// Returns bool
+// true if the first item is more relevant than the second one.
+func (c *TextMatch) Less(a, b interface{}) bool {
+	return c.Score(a) > c.Score(b)
+}
+
+// Gets relevance score of an item.
+// Parameters:
+//   - item interface{}
+//   an item to score.
+// Returns float64
+// relevance score or 0 if the item does not match.
+func (c *TextMatch) Score(item interface{}) float64 {
+	if c.scores == nil {
+		return 0
+	}
+	return c.scores[c.getKey(item)]
+}
+
+// Gets the number of matched items.
+// Returns int
+// number of matched items or -1 when all items match.
+func (c *TextMatch) Len() int {
+	if c.scores == nil {
+		return -1
+	}
+	return len(c.scores)
+}
+
+// Enables the full-text index over text properties of items.
+// The index is maintained on writes and used by MatchText.
+// Properties are read using GetProperty, so nested and map items are supported.
+// Parameters:
+//   - fields ...string
+//   names of text properties to index.
+func (c *MemoryPersistence) EnableTextIndex(fields ...string) {
+	unlock := c.lockAll()
+	defer unlock()
+
+	if c.textIndex != nil {
+		c.removeIndex(c.textIndex)
+		c.textIndex = nil
+	}
+
+	if len(fields) > 0 {
+		c.textIndex = newTextIndex(fields, c.PropertyTag)
+		c.addIndex(c.textIndex)
+	}
+}
+
+// Performs full-text search over indexed properties.
+// Every token of the search string must match a token of the item
+// either exactly or as a prefix. Items are scored by the number of matches,
+// and exact matches weigh twice as much as prefix matches.
+// Parameters:
+//   - correlationId string
+//   (optional) transaction id to trace execution through call chain.
+//   - search string
+//   a search string.
+// Returns *TextMatch, error
+// search result to filter and sort items or error when text index is not enabled.
+func (c *MemoryPersistence) MatchText(correlationId string, search string) (result *TextMatch, err error) {
+	return c.MatchTextWithContext(ContextWithCorrelationId(context.Background(), correlationId), search)
+}
+
+// Performs full-text search over indexed properties.
+// In tenant mode only items of the tenant from the context are matched.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - search string
+//   a search string.
+// Returns *TextMatch, error
+// search result to filter and sort items or error when text index is not enabled.
+func (c *MemoryPersistence) MatchTextWithContext(ctx context.Context, search string) (result *TextMatch, err error) {
+	correlationId := CorrelationIdFromContext(ctx)
+	timing := c.beginOperation(correlationId, "match_text")
+	defer func() { timing.end(err) }()
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	var tenantId string
+	if tenantId, err = c.getTenantId(ctx); err != nil {
+		return nil, err
+	}
+
+	unlock := c.rlockAll()
+	defer unlock()
+
+	if c.textIndex == nil {
+		return nil, errors.NewBadRequestError(correlationId, "NO_TEXT_INDEX", "Full-text index is not enabled")
+	}
+
+	if len(appendTextTokens(nil, search)) == 0 {
+		return &TextMatch{}, nil
+	}
+
+	scores := c.textIndex.search(search)
+	for key := range scores {
+		if !c.isTenantKey(key, tenantId) {
+			delete(scores, key)
+		}
+	}
+
+	result = &TextMatch{scores: scores, getKey: c.itemKey}
+	timing.size(len(scores))
+	c.Logger.Trace(correlationId, "Matched %d items by text search", len(result.scores))
+	return result, nil
+}
@@ -0,0 +1,359 @@
+package persistence
+
+import (
+	"reflect"
+	"strings"
+	"unsafe"
+
+	"github.com/jinzhu/copier"
+)
+
+// Strategies to copy items stored in and returned by persistence components
+const (
+	// Items are copied with all nested maps, slices and pointers,
+	// so callers can never change stored data. This is the default strategy.
+	CloneDeep = "deep"
+	// Only the top level of items is copied, nested maps, slices and pointers
+	// are shared between stored items and callers.
+	CloneShallow = "shallow"
+	// Items are trusted and stored and returned without copying.
+	// Only values of pointer prototypes are wrapped into new pointers.
+	CloneNone = "none"
+)
+
+// ICloneable is the interface for items that create their own copies.
+// Deep copies of cloneable items and their nested values are made
+// by calling Clone instead of walking them with reflection.
+type ICloneable interface {
+	// Creates a deep copy of the object.
+	// Returns interface{}
+	// a copy of the same type as the object or a pointer to it.
+	Clone() interface{}
+}
+
+var cloneableType = reflect.TypeOf((*ICloneable)(nil)).Elem()
+
+// Converts a configured clone strategy to one of the supported values
+func toCloneStrategy(value string) string {
+	switch strings.ToLower(value) {
+	case CloneShallow:
+		return CloneShallow
+	case CloneNone:
+		return CloneNone
+	default:
+		return CloneDeep
+	}
+}
+
+// DeepCopy creates a deep copy of a value.
+// Maps, slices, arrays, pointers and struct fields, including unexported ones, are copied recursively,
+// values that implement ICloneable are copied by their Clone method.
+// Shared and cyclic references stay shared in the copy. Functions and channels are not copied.
+// Parameters:
+//   - value interface{}
+//   a value to copy
+// Returns interface{}
+// a copy of the value
+func DeepCopy(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	val := reflect.ValueOf(value)
+	if getTypeMetadata(val.Type()).flat {
+		return value
+	}
+	return deepCopyValue(val, make(map[visitedKey]reflect.Value)).Interface()
+}
+
+// Identifies a copied pointer, map or slice. Slices of different types
+// or lengths may start at the same address, so they are told apart.
+type visitedKey struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// Gets a field of an addressable struct that can be read and set
+// even when the field is unexported
+func accessibleField(value reflect.Value, index int) reflect.Value {
+	field := value.Field(index)
+	if field.CanSet() {
+		return field
+	}
+	return reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem()
+}
+
+// Copies a value using its Clone method if it has one
+func cloneByInterface(value reflect.Value) (reflect.Value, bool) {
+	var cloneable ICloneable
+	typ := value.Type()
+	if typ.Implements(cloneableType) {
+		if value.Kind() == reflect.Ptr && value.IsNil() {
+			return value, false
+		}
+		cloneable = value.Interface().(ICloneable)
+	} else if typ.Kind() == reflect.Struct && reflect.PtrTo(typ).Implements(cloneableType) {
+		ptr := reflect.New(typ)
+		ptr.Elem().Set(value)
+		cloneable = ptr.Interface().(ICloneable)
+	} else {
+		return value, false
+	}
+
+	result := reflect.ValueOf(cloneable.Clone())
+	if !result.IsValid() {
+		return value, false
+	}
+	if result.Type() == typ {
+		return result, true
+	}
+	if result.Kind() == reflect.Ptr && result.Type().Elem() == typ && !result.IsNil() {
+		return result.Elem(), true
+	}
+	return value, false
+}
+
+func deepCopyValue(value reflect.Value, visited map[visitedKey]reflect.Value) reflect.Value {
+	if !value.IsValid() {
+		return value
+	}
+
+	metadata := getTypeMetadata(value.Type())
+	if metadata.flat {
+		return value
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Struct:
+		if metadata.cloneable && value.CanInterface() {
+			if result, ok := cloneByInterface(value); ok {
+				return result
+			}
+		}
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			return value
+		}
+		// Keep shared and cyclic references shared in the copy
+		key := visitedKey{ptr: value.Pointer(), typ: value.Type()}
+		if result, ok := visited[key]; ok {
+			return result
+		}
+		result := reflect.New(value.Type().Elem())
+		visited[key] = result
+		result.Elem().Set(deepCopyValue(value.Elem(), visited))
+		return result
+
+	case reflect.Interface:
+		if value.IsNil() {
+			return value
+		}
+		result := reflect.New(value.Type()).Elem()
+		result.Set(deepCopyValue(value.Elem(), visited))
+		return result
+
+	case reflect.Map:
+		if value.IsNil() {
+			return value
+		}
+		key := visitedKey{ptr: value.Pointer(), typ: value.Type()}
+		if result, ok := visited[key]; ok {
+			return result
+		}
+		result := reflect.MakeMapWithSize(value.Type(), value.Len())
+		visited[key] = result
+		iter := value.MapRange()
+		for iter.Next() {
+			result.SetMapIndex(iter.Key(), deepCopyValue(iter.Value(), visited))
+		}
+		return result
+
+	case reflect.Slice:
+		if value.IsNil() {
+			return value
+		}
+		result := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			reflect.Copy(result, value)
+			return result
+		}
+		if value.Len() > 0 {
+			key := visitedKey{ptr: value.Pointer(), typ: value.Type(), len: value.Len()}
+			if copied, ok := visited[key]; ok {
+				return copied
+			}
+			visited[key] = result
+		}
+		for i := 0; i < value.Len(); i++ {
+			result.Index(i).Set(deepCopyValue(value.Index(i), visited))
+		}
+		return result
+
+	case reflect.Array:
+		result := reflect.New(value.Type()).Elem()
+		for i := 0; i < value.Len(); i++ {
+			result.Index(i).Set(deepCopyValue(value.Index(i), visited))
+		}
+		return result
+
+	case reflect.Struct:
+		// Fields of addressable values are read and set through their addresses
+		source := reflect.New(value.Type()).Elem()
+		source.Set(value)
+		result := reflect.New(value.Type()).Elem()
+		result.Set(value)
+		for i := 0; i < value.NumField(); i++ {
+			if getTypeMetadata(value.Type().Field(i).Type).flat {
+				continue
+			}
+			accessibleField(result, i).Set(deepCopyValue(accessibleField(source, i), visited))
+		}
+		return result
+
+	default:
+		return value
+	}
+}
+
+// Creates a shallow copy of a value: a new map with the same entries,
+// a new slice with the same elements or a copy of a struct
+func shallowCopy(value interface{}) interface{} {
+	val := reflect.ValueOf(value)
+	switch val.Kind() {
+	case reflect.Map:
+		if val.IsNil() {
+			return value
+		}
+		result := reflect.MakeMapWithSize(val.Type(), val.Len())
+		iter := val.MapRange()
+		for iter.Next() {
+			result.SetMapIndex(iter.Key(), iter.Value())
+		}
+		return result.Interface()
+	case reflect.Slice:
+		if val.IsNil() {
+			return value
+		}
+		result := reflect.MakeSlice(val.Type(), val.Len(), val.Len())
+		reflect.Copy(result, val)
+		return result.Interface()
+	case reflect.Ptr:
+		if val.IsNil() {
+			return value
+		}
+		result := reflect.New(val.Type().Elem())
+		result.Elem().Set(val.Elem())
+		return result.Interface()
+	default:
+		// Structs and scalars are already copied by value
+		return value
+	}
+}
+
+// Copies a value using a clone strategy
+func cloneValue(value interface{}, strategy string) interface{} {
+	switch strategy {
+	case CloneNone:
+		return value
+	case CloneShallow:
+		return shallowCopy(value)
+	default:
+		return DeepCopy(value)
+	}
+}
+
+// Copies a value into a new value of a different type using copier
+func convertObject(src interface{}, proto reflect.Type, strategy string) (reflect.Value, bool) {
+	destPtr := reflect.New(proto)
+	err := copier.CopyWithOption(destPtr.Interface(), src, copier.Option{DeepCopy: strategy == CloneDeep, IgnoreEmpty: false})
+	return destPtr.Elem(), err == nil
+}
+
+// Copies an item into a value of the prototype type
+func cloneToPrototype(item interface{}, proto reflect.Type, strategy string) (reflect.Value, bool) {
+	if proto.Kind() == reflect.Ptr {
+		proto = proto.Elem()
+	}
+
+	src := reflect.ValueOf(item)
+	if src.Kind() == reflect.Ptr {
+		if src.IsNil() {
+			return src, false
+		}
+		src = src.Elem()
+	}
+
+	if src.Type() != proto {
+		return convertObject(src.Interface(), proto, strategy)
+	}
+	return reflect.ValueOf(cloneValue(src.Interface(), strategy)), true
+}
+
+// CloneObjectWithStrategy clones an object using a clone strategy
+// Parameters:
+//   - item interface{}
+//   an object to clone
+//   - proto reflect.Type
+//   type of the copy, pointer items are copied into values of the pointed type
+//   - strategy string
+//   a clone strategy: CloneDeep, CloneShallow or CloneNone
+// Return interface{}
+// copy of input item or nil if it cannot be copied
+func CloneObjectWithStrategy(item interface{}, proto reflect.Type, strategy string) interface{} {
+	if item == nil {
+		return nil
+	}
+	if reflect.ValueOf(item).Kind() == reflect.Map {
+		return cloneValue(item, strategy)
+	}
+
+	dest, ok := cloneToPrototype(item, proto, strategy)
+	if !ok {
+		return nil
+	}
+	return dest.Interface()
+}
+
+// CloneObjectForResultWithStrategy clones an object for result using a clone strategy
+// Parameters:
+// 	  - item interface{}
+// 	  an object to clone
+//	  - proto reflect.Type
+//	  type of returned value, need for detect object or pointer returned type
+//   - strategy string
+//   a clone strategy: CloneDeep, CloneShallow or CloneNone
+// Return interface{}
+// copy of input item or nil if it cannot be copied
+func CloneObjectForResultWithStrategy(src interface{}, proto reflect.Type, strategy string) interface{} {
+	if src == nil {
+		return nil
+	}
+	if reflect.ValueOf(src).Kind() == reflect.Map {
+		return cloneValue(src, strategy)
+	}
+
+	dest, ok := cloneToPrototype(src, proto, strategy)
+	if !ok {
+		return nil
+	}
+	// make pointer on clone object, if proto is ptr
+	if proto.Kind() == reflect.Ptr {
+		destPtr := reflect.New(dest.Type())
+		destPtr.Elem().Set(dest)
+		return destPtr.Interface()
+	}
+	return dest.Interface()
+}
+
+// Copies an item before it is stored using the configured clone strategy
+func (c *MemoryPersistence) cloneItem(item interface{}) interface{} {
+	return CloneObjectWithStrategy(item, c.Prototype, c.CloneStrategy)
+}
+
+// Copies a stored item before it is returned using the configured clone strategy
+func (c *MemoryPersistence) cloneResult(item interface{}) interface{} {
+	return CloneObjectForResultWithStrategy(item, c.Prototype, c.CloneStrategy)
+}
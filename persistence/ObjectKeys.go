@@ -0,0 +1,204 @@
+package persistence
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/pip-services3-go/pip-services3-commons-go/convert"
+)
+
+// Name of the struct tag that marks id fields: `persist:"id"`.
+// When several fields are marked they form a composite key in order of their declaration.
+const IdTag = "persist"
+
+/*
+Key of items identified by several fields.
+Parts of the key go in the same order as the id fields.
+
+Composite keys are returned as ids of items with several id fields
+and can be passed to GetOneById, DeleteById and other operations by id.
+Those operations also accept key structs or maps with the id fields
+and slices of key parts.
+
+Example
+
+    type Membership struct {
+        TenantId string `persist:"id"`
+        Name     string `persist:"id"`
+        Role     string
+    }
+
+    item, err := persistence.GetOneById("123", NewCompositeKey("tenant1", "admin"))
+    item, err = persistence.GetOneById("123", Membership{TenantId: "tenant1", Name: "admin"})
+*/
+type CompositeKey []interface{}
+
+var compositeKeyType = reflect.TypeOf(CompositeKey{})
+
+// Creates a new composite key from its parts.
+// Parameters:
+//   - parts ...interface{}
+//   parts of the key in order of the id fields
+// Returns CompositeKey
+// a new composite key
+func NewCompositeKey(parts ...interface{}) CompositeKey {
+	return CompositeKey(parts)
+}
+
+// Converts the key into a string to use in lookup maps.
+// Returns string
+// the key parts encoded as a JSON array of strings
+func (c CompositeKey) String() string {
+	parts := make([]string, len(c))
+	for i, v := range c {
+		parts[i] = convert.StringConverter.ToString(v)
+	}
+	value, _ := json.Marshal(parts)
+	return string(value)
+}
+
+// Gets names of fields marked by the id tag in a struct type and its embedded structs
+func getTaggedIdFields(typ reflect.Type) []string {
+	if typ == nil {
+		return nil
+	}
+	return getTypeMetadata(typ).idFields
+}
+
+// Gets names of id fields of an item: configured fields, fields marked by the id tag,
+// or nil when the item is identified by the Id field
+func getIdFields(item interface{}, fields []string) []string {
+	if len(fields) > 0 {
+		return fields
+	}
+	return getTaggedIdFields(reflect.TypeOf(getValue(item)))
+}
+
+// GetObjectIdByFields gets object id value from id fields.
+// Parameters:
+//   - item interface{}
+//   an object to read id from.
+//   - fields []string
+//   (optional) names of id fields. When they are not set,
+//   fields marked with persist:"id" tag are used, or the Id field when there are no such fields.
+// Returns interface{}
+// the id value, CompositeKey when there are several id fields,
+// or nil if id fields don't exist or introspection failed.
+func GetObjectIdByFields(item interface{}, fields []string) interface{} {
+	return getObjectIdByFields(item, fields, "")
+}
+
+// Gets object id value from id fields matched by names from json tags and a struct tag
+func getObjectIdByFields(item interface{}, fields []string, tag string) interface{} {
+	fields = getIdFields(item, fields)
+	switch len(fields) {
+	case 0:
+		return GetPropertyWithTag(item, "Id", tag)
+	case 1:
+		return GetPropertyWithTag(item, fields[0], tag)
+	}
+
+	key := make(CompositeKey, len(fields))
+	empty := true
+	for i, field := range fields {
+		key[i] = GetPropertyWithTag(item, field, tag)
+		empty = empty && key[i] == nil
+	}
+	if empty {
+		return nil
+	}
+	return key
+}
+
+// SetObjectIdByFields sets object id value into id fields.
+// Parameters:
+//   - item *interface{}
+//   an pointer on object to set id.
+//   - fields []string
+//   (optional) names of id fields, see GetObjectIdByFields.
+//   - id interface{}
+//   id value for set, parts of composite keys are set in order of id fields.
+// Results saved in input object
+func SetObjectIdByFields(item *interface{}, fields []string, id interface{}) {
+	fields = getIdFields(*item, fields)
+	switch len(fields) {
+	case 0:
+		setObjectProperty(item, "Id", id, "")
+	case 1:
+		setObjectProperty(item, fields[0], id, "")
+	default:
+		key, ok := toObjectKey(id, fields, "").(CompositeKey)
+		if !ok {
+			return
+		}
+		for i, field := range fields {
+			if i < len(key) {
+				setObjectProperty(item, field, key[i], "")
+			}
+		}
+	}
+}
+
+// Converts ids passed by callers into keys in the form returned by GetObjectIdByFields.
+// For composite keys it accepts CompositeKey, slices and arrays of key parts,
+// and structs and maps with the id fields matched by names from json tags and a struct tag.
+func toObjectKey(id interface{}, fields []string, tag string) interface{} {
+	if len(fields) < 2 || id == nil {
+		return id
+	}
+	if key, ok := id.(CompositeKey); ok {
+		return key
+	}
+
+	value := reflect.ValueOf(id)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		key := make(CompositeKey, value.Len())
+		for i := range key {
+			key[i] = value.Index(i).Interface()
+		}
+		return key
+	case reflect.Struct, reflect.Map, reflect.Ptr:
+		key := make(CompositeKey, len(fields))
+		for i, field := range fields {
+			key[i] = GetPropertyWithTag(id, field, tag)
+		}
+		return key
+	default:
+		return id
+	}
+}
+
+// Splits a comma-separated list of field names
+func splitFieldNames(value string) []string {
+	fields := strings.Split(value, ",")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	return fields
+}
+
+// Gets names of configured id fields or fields marked by the id tag in the prototype
+func (c *MemoryPersistence) keyFields() []string {
+	if len(c.IdFields) > 0 {
+		return c.IdFields
+	}
+	return getTaggedIdFields(c.Prototype)
+}
+
+// Gets the id of an item from configured id fields
+func (c *MemoryPersistence) getId(item interface{}) interface{} {
+	return getObjectIdByFields(item, c.keyFields(), c.PropertyTag)
+}
+
+// Converts an id passed by caller into the key returned by getId
+func (c *MemoryPersistence) toKey(id interface{}) interface{} {
+	return toObjectKey(id, c.keyFields(), c.PropertyTag)
+}
+
+// Checks if an item has the id. Ids are matched by ValueComparer,
+// so an id passed as "1" finds an item with int64(1) id.
+func (c *MemoryPersistence) hasId(item interface{}, key interface{}) bool {
+	return ValueComparer.Equals(c.getId(item), key)
+}
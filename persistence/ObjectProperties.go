@@ -0,0 +1,341 @@
+package persistence
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pip-services3-go/pip-services3-commons-go/convert"
+)
+
+// Splits a property path like "address.lines[0]" or "address.lines.0" into segments
+func splitPropertyPath(name string) []string {
+	if !strings.ContainsAny(name, ".[") {
+		return []string{name}
+	}
+
+	segments := make([]string, 0, 4)
+	for _, part := range strings.Split(name, ".") {
+		for {
+			start := strings.IndexByte(part, '[')
+			end := strings.IndexByte(part, ']')
+			if start < 0 || end < start {
+				break
+			}
+			if start > 0 {
+				segments = append(segments, part[:start])
+			}
+			segments = append(segments, part[start+1:end])
+			part = part[end+1:]
+		}
+		if part != "" {
+			segments = append(segments, part)
+		}
+	}
+	return segments
+}
+
+// Finds a key of a map entry by its name, the exact key is preferred
+func findMapKey(value reflect.Value, name string) (reflect.Value, bool) {
+	keyType := value.Type().Key()
+	if keyType.Kind() == reflect.String {
+		key := reflect.ValueOf(name).Convert(keyType)
+		if value.MapIndex(key).IsValid() {
+			return key, true
+		}
+	}
+
+	iter := value.MapRange()
+	for iter.Next() {
+		if strings.EqualFold(convert.StringConverter.ToString(iter.Key().Interface()), name) {
+			return iter.Key(), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// Creates a key for a new map entry, names of new entries are lower case
+func newMapKey(keyType reflect.Type, name string) (reflect.Value, bool) {
+	name = strings.ToLower(name)
+	switch keyType.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(name).Convert(keyType), true
+	case reflect.Interface:
+		return reflect.ValueOf(name), true
+	default:
+		return reflect.Value{}, false
+	}
+}
+
+// Parses an index of slice or array element
+func parseElementIndex(value reflect.Value, name string) (int, bool) {
+	index, err := strconv.Atoi(name)
+	if err != nil || index < 0 || index >= value.Len() {
+		return 0, false
+	}
+	return index, true
+}
+
+// Gets a value by segments of a property path
+func getPropertyPath(value reflect.Value, segments []string, tag string) (reflect.Value, bool) {
+	for _, segment := range segments {
+		value = indirectValue(value)
+		if !value.IsValid() {
+			return value, false
+		}
+
+		switch value.Kind() {
+		case reflect.Map:
+			key, ok := findMapKey(value, segment)
+			if !ok {
+				return reflect.Value{}, false
+			}
+			value = value.MapIndex(key)
+		case reflect.Struct:
+			field, ok := getTypeMetadata(value.Type()).properties(tag).find(segment)
+			if !ok {
+				return reflect.Value{}, false
+			}
+			value = value.FieldByIndex(field.index)
+		case reflect.Slice, reflect.Array:
+			index, ok := parseElementIndex(value, segment)
+			if !ok {
+				return reflect.Value{}, false
+			}
+			value = value.Index(index)
+		default:
+			return reflect.Value{}, false
+		}
+	}
+	return value, true
+}
+
+// Sets a value by segments of a property path.
+// Missing pointers and maps on the path are created, struct values
+// stored in maps and interfaces are copied and stored back.
+func setPropertyPath(target reflect.Value, segments []string, value interface{}, tag string) bool {
+	switch target.Kind() {
+	case reflect.Ptr:
+		if target.IsNil() {
+			if !target.CanSet() {
+				return false
+			}
+			target.Set(reflect.New(target.Type().Elem()))
+		}
+		return setPropertyPath(target.Elem(), segments, value, tag)
+
+	case reflect.Interface:
+		if target.IsNil() {
+			return false
+		}
+		elem := target.Elem()
+		if elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Map {
+			return setPropertyPath(elem, segments, value, tag)
+		}
+		if !target.CanSet() {
+			return false
+		}
+		elemCopy := reflect.New(elem.Type()).Elem()
+		elemCopy.Set(elem)
+		if !setPropertyPath(elemCopy, segments, value, tag) {
+			return false
+		}
+		target.Set(elemCopy)
+		return true
+
+	case reflect.Map:
+		if target.IsNil() {
+			if !target.CanSet() {
+				return false
+			}
+			target.Set(reflect.MakeMap(target.Type()))
+		}
+		elemType := target.Type().Elem()
+		key, found := findMapKey(target, segments[0])
+		if !found {
+			var ok bool
+			if key, ok = newMapKey(target.Type().Key(), segments[0]); !ok {
+				return false
+			}
+		}
+
+		elem := reflect.New(elemType).Elem()
+		if len(segments) == 1 {
+			converted, ok := convertValue(value, elemType)
+			if !ok {
+				return false
+			}
+			elem.Set(converted)
+		} else {
+			if found {
+				elem.Set(target.MapIndex(key))
+			} else if elemType.Kind() == reflect.Interface {
+				elem.Set(reflect.ValueOf(map[string]interface{}{}))
+			}
+			if !setPropertyPath(elem, segments[1:], value, tag) {
+				return false
+			}
+		}
+		target.SetMapIndex(key, elem)
+		return true
+
+	case reflect.Struct:
+		field, ok := getTypeMetadata(target.Type()).properties(tag).find(segments[0])
+		if !ok {
+			return false
+		}
+		return setPropertyValue(target.FieldByIndex(field.index), segments[1:], value, tag)
+
+	case reflect.Slice, reflect.Array:
+		index, ok := parseElementIndex(target, segments[0])
+		if !ok {
+			return false
+		}
+		return setPropertyValue(target.Index(index), segments[1:], value, tag)
+
+	default:
+		return false
+	}
+}
+
+// Sets a field or an element, or a value nested in them when segments are left
+func setPropertyValue(target reflect.Value, segments []string, value interface{}, tag string) bool {
+	if len(segments) > 0 {
+		return setPropertyPath(target, segments, value, tag)
+	}
+	if !target.CanSet() {
+		return false
+	}
+	converted, ok := convertValue(value, target.Type())
+	if !ok {
+		return false
+	}
+	target.Set(converted)
+	return true
+}
+
+// Converts a value to the type of a field, map entry or element
+func convertValue(value interface{}, typ reflect.Type) (reflect.Value, bool) {
+	if value == nil {
+		return reflect.Zero(typ), true
+	}
+	val := reflect.ValueOf(value)
+	if val.Type().AssignableTo(typ) {
+		return val, true
+	}
+
+	switch {
+	case typ.Kind() == reflect.Ptr:
+		elem, ok := convertValue(value, typ.Elem())
+		if !ok {
+			return elem, false
+		}
+		result := reflect.New(typ.Elem())
+		result.Elem().Set(elem)
+		return result, true
+	case typ == timeType:
+		result := convert.DateTimeConverter.ToNullableDateTime(value)
+		if result == nil {
+			return val, false
+		}
+		return reflect.ValueOf(*result), true
+	case typ.Kind() == reflect.String || isNumericKind(typ.Kind()) || val.Type().ConvertibleTo(typ):
+		result, ok := convertId(value, typ)
+		if !ok {
+			return val, false
+		}
+		return reflect.ValueOf(result), true
+	default:
+		return val, false
+	}
+}
+
+// Sets values of properties from a map, property names can be paths
+func setProperties(obj interface{}, values map[string]interface{}, tag string) {
+	for name, value := range values {
+		SetPropertyWithTag(obj, name, value, tag)
+	}
+}
+
+// GetPropertyWithTag gets value of object property specified by its name or path
+// using names from a custom struct tag.
+// Parameters:
+//   - obj interface{}
+//   an object to read property from.
+//   - name string
+//   a name of the property or a path like "address.city" or "lines[0].text".
+//   - tag string
+//   (optional) name of a struct tag with property names, checked before json tags.
+// Returns interface{}
+// the property value or nil if property doesn't exist or introspection failed.
+func GetPropertyWithTag(obj interface{}, name string, tag string) interface{} {
+	if obj == nil || name == "" {
+		return nil
+	}
+
+	defer func() {
+		// Do nothing and return nil
+		recover()
+	}()
+
+	val := reflect.ValueOf(getValue(obj))
+	// Map keys with dots are matched before paths
+	if val.Kind() == reflect.Map && strings.ContainsAny(name, ".[") {
+		if key, ok := findMapKey(val, name); ok {
+			return val.MapIndex(key).Interface()
+		}
+	}
+
+	value, ok := getPropertyPath(val, splitPropertyPath(name), tag)
+	if !ok || !value.IsValid() {
+		return nil
+	}
+	return value.Interface()
+}
+
+// SetPropertyWithTag sets value of object property specified by its name or path
+// using names from a custom struct tag.
+// Values are converted to types of the properties when possible.
+// If the property does not exist or introspection fails this method doesn't do anything and doesn't any throw errors.
+// Parameters:
+//   - obj interface{}
+//   a map or a pointer to an object to write property to.
+//   - name string
+//   a name of the property or a path like "address.city" or "lines[0].text".
+//   - value interface{}
+//   a new value for the property to set.
+//   - tag string
+//   (optional) name of a struct tag with property names, checked before json tags.
+func SetPropertyWithTag(obj interface{}, name string, value interface{}, tag string) {
+	if obj == nil || name == "" {
+		return
+	}
+
+	defer func() {
+		// Do nothing
+		recover()
+	}()
+
+	val := reflect.ValueOf(getValue(obj))
+	if val.Kind() != reflect.Map && val.Kind() != reflect.Ptr {
+		return
+	}
+	// Map keys with dots are matched before paths
+	if val.Kind() == reflect.Map && strings.ContainsAny(name, ".[") {
+		if _, ok := findMapKey(val, name); ok {
+			setPropertyPath(val, []string{name}, value, tag)
+			return
+		}
+	}
+	setPropertyPath(val, splitPropertyPath(name), value, tag)
+}
+
+// Gets value of an item property using names from PropertyTag, see GetPropertyWithTag
+func (c *MemoryPersistence) getProperty(item interface{}, name string) interface{} {
+	return GetPropertyWithTag(item, name, c.PropertyTag)
+}
+
+// Sets value of an item property using names from PropertyTag, see SetPropertyWithTag
+func (c *MemoryPersistence) setProperty(obj interface{}, name string, value interface{}) {
+	SetPropertyWithTag(obj, name, value, c.PropertyTag)
+}
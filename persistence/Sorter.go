@@ -1,5 +1,11 @@
 package persistence
 
+import (
+	"sort"
+
+	cdata "github.com/pip-services3-go/pip-services3-commons-go/data"
+)
+
 /*
 Helper class for sorting data in MemoryPersistence
 implements sort.Interface
@@ -37,3 +43,46 @@ func (s sorter) Less(i, j int) bool {
 	}
 	return s.compFunc(s.items[i], s.items[j])
 }
+
+// Sorts items and extracts a page from them.
+// Items are sorted in place.
+// Parameters:
+//   - items []interface{}
+//   items to be paged.
+//   - paging *cdata.PagingParams
+//   (optional) paging parameters.
+//   - sortFunc func(a, b interface{}) bool
+//   (optional) sorting compare function.
+//   - maxPageSize int
+//   maximum number of items in a page.
+// Returns []interface{}, int64
+// items of the page and total number of items when it is requested by paging.
+func extractPage(items []interface{}, paging *cdata.PagingParams,
+	sortFunc func(a, b interface{}) bool, maxPageSize int) ([]interface{}, int64) {
+
+	if sortFunc != nil {
+		localSort := sorter{items: items, compFunc: sortFunc}
+		sort.Sort(localSort)
+	}
+
+	if paging == nil {
+		paging = cdata.NewEmptyPagingParams()
+	}
+	skip := paging.GetSkip(-1)
+	take := paging.GetTake((int64)(maxPageSize))
+	var total int64
+	if paging.Total {
+		total = (int64)(len(items))
+	}
+	if skip > 0 {
+		len := (int64)(len(items))
+		if skip >= len {
+			skip = len
+		}
+		items = items[skip:]
+	}
+	if (int64)(len(items)) >= take {
+		items = items[:take]
+	}
+	return items, total
+}
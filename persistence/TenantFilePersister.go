@@ -0,0 +1,310 @@
+package persistence
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pip-services3-go/pip-services3-commons-go/config"
+	"github.com/pip-services3-go/pip-services3-commons-go/convert"
+	"github.com/pip-services3-go/pip-services3-commons-go/errors"
+	"github.com/pip-services3-go/pip-services3-commons-go/refer"
+	"github.com/pip-services3-go/pip-services3-components-go/trace"
+)
+
+// Placeholder for tenant id in paths of TenantFilePersister
+const TenantPathPlaceholder = "{tenant}"
+
+/*
+Persistence component that loads and saves data of each tenant from/to its own flat file.
+
+It is used by IdentifiableFilePersistence in tenant mode when the path
+contains {tenant} placeholder, but can be useful on its own.
+Items are split between files by the tenant property. Files of tenants
+that have no items left are saved empty.
+
+ Configuration parameters
+
+  - path:                path template to the files with {tenant} placeholder
+  - options:
+      - tenant_field:    name of the property with tenant id
+      - property_tag:    name of an additional struct tag with property names checked before json tags
+
+ References
+
+  - *:tracer:*:*:1.0    (optional) ITracer components to record load and save traces
+
+ Example
+
+  persister := NewTenantFilePersister(reflect.TypeOf(MyData{}), "./data/{tenant}.json", "tenant_id")
+
+  err := persister.Save("123", []interface{}{
+      MyData{Id: "1", TenantId: "a"},
+      MyData{Id: "2", TenantId: "b"},
+  })
+  // Saves ./data/a.json and ./data/b.json
+*/
+// implements ILoader, ISaver, ILoaderWithContext, ISaverWithContext, IConfigurable, IReferenceable
+type TenantFilePersister struct {
+	path      string
+	Prototype reflect.Type
+	// Name of the property with tenant id
+	TenantField string
+	// Name of an additional struct tag with property names, like "bson", checked before json tags
+	PropertyTag string
+	// Tracer to record load and save traces
+	Tracer *trace.CompositeTracer
+
+	lock       sync.Mutex
+	persisters map[string]*JsonFilePersister
+}
+
+// Creates a new instance of the persister.
+// Parameters:
+//  - prototype reflect.Type
+//  type of contained data
+//  - path string
+//  (optional) a path template to the files with {tenant} placeholder.
+//  - tenantField string
+//  (optional) a name of the property with tenant id.
+func NewTenantFilePersister(prototype reflect.Type, path string, tenantField string) *TenantFilePersister {
+	c := &TenantFilePersister{
+		path:        path,
+		Prototype:   prototype,
+		TenantField: tenantField,
+		persisters:  make(map[string]*JsonFilePersister),
+	}
+	c.Tracer = trace.NewCompositeTracer(nil)
+	return c
+}
+
+// Gets the path template to the files where data is stored.
+// Returns the path template.
+func (c *TenantFilePersister) Path() string {
+	return c.path
+}
+
+// Gets the path to the file where data of a tenant is stored.
+// Parameters:
+//  - tenantId string
+//  an id of the tenant.
+// Returns string, error
+// the file path or BadRequestError when the tenant id cannot be a part of the path.
+func (c *TenantFilePersister) TenantPath(tenantId string) (string, error) {
+	if err := checkTenantPathId("", tenantId); err != nil {
+		return "", err
+	}
+	return c.tenantPath(tenantId), nil
+}
+
+func (c *TenantFilePersister) tenantPath(tenantId string) string {
+	return strings.Replace(c.path, TenantPathPlaceholder, tenantId, -1)
+}
+
+// Checks that a tenant id can be safely used as a part of file paths.
+// Empty ids and ids with path separators, ".." or NUL characters
+// could point outside of the data directory, so they are rejected.
+func checkTenantPathId(correlationId string, tenantId string) error {
+	if tenantId == "" || tenantId == "." || strings.Contains(tenantId, "..") ||
+		strings.ContainsAny(tenantId, "/\\\x00") {
+		return errors.NewBadRequestError(correlationId, "INVALID_TENANT",
+			"Tenant id cannot be used in data file path").WithDetails("tenant_id", tenantId)
+	}
+	return nil
+}
+
+// Configures component by passing configuration parameters.
+// Parameters:
+//  - config  config.ConfigParams
+//  parameters to be set.
+func (c *TenantFilePersister) Configure(config *config.ConfigParams) {
+	c.path = config.GetAsStringWithDefault("path", c.path)
+	c.TenantField = config.GetAsStringWithDefault("options.tenant_field", c.TenantField)
+	c.PropertyTag = config.GetAsStringWithDefault("options.property_tag", c.PropertyTag)
+}
+
+// Sets references to dependent components.
+// Parameters:
+//  - references refer.IReferences
+//  references to locate the component dependencies.
+func (c *TenantFilePersister) SetReferences(references refer.IReferences) {
+	c.Tracer.SetReferences(references)
+}
+
+// Gets a persister for the file of a tenant
+func (c *TenantFilePersister) getPersister(correlationId string, tenantId string) (*JsonFilePersister, error) {
+	if err := checkTenantPathId(correlationId, tenantId); err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	persister, ok := c.persisters[tenantId]
+	if !ok {
+		persister = NewJsonFilePersister(c.Prototype, c.tenantPath(tenantId))
+		persister.Tracer = c.Tracer
+		c.persisters[tenantId] = persister
+	}
+	return persister, nil
+}
+
+// Gets ids of tenants which files were loaded or saved
+func (c *TenantFilePersister) knownTenants() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	tenants := make([]string, 0, len(c.persisters))
+	for tenantId := range c.persisters {
+		tenants = append(tenants, tenantId)
+	}
+	return tenants
+}
+
+// Gets persisters of tenants which files were loaded or saved
+func (c *TenantFilePersister) tenantPersisters() map[string]*JsonFilePersister {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	persisters := make(map[string]*JsonFilePersister, len(c.persisters))
+	for tenantId, persister := range c.persisters {
+		persisters[tenantId] = persister
+	}
+	return persisters
+}
+
+// Checks that the path template is set and contains the tenant placeholder
+func (c *TenantFilePersister) checkPath(correlationId string) error {
+	if c.path == "" || !strings.Contains(c.path, TenantPathPlaceholder) {
+		return errors.NewConfigError(correlationId, "INVALID_PATH",
+			"Data file path must contain "+TenantPathPlaceholder+" placeholder").WithDetails("path", c.path)
+	}
+	return nil
+}
+
+// Finds ids of tenants that have files matching the path template
+func (c *TenantFilePersister) findTenants(correlationId string) ([]string, error) {
+	if err := c.checkPath(correlationId); err != nil {
+		return nil, err
+	}
+
+	pattern := regexp.QuoteMeta(filepath.ToSlash(c.path))
+	pattern = "^" + strings.Replace(pattern, regexp.QuoteMeta(TenantPathPlaceholder), "([^/]+)", -1) + "$"
+	matcher := regexp.MustCompile(pattern)
+
+	files, err := filepath.Glob(c.tenantPath("*"))
+	if err != nil {
+		return nil, errors.NewFileError(correlationId, "READ_FAILED", "Failed to list data files: "+c.path).WithCause(err)
+	}
+
+	tenants := make([]string, 0, len(files))
+	for _, file := range files {
+		if match := matcher.FindStringSubmatch(filepath.ToSlash(file)); match != nil {
+			tenants = append(tenants, match[1])
+		}
+	}
+	sort.Strings(tenants)
+	return tenants, nil
+}
+
+// Loads data items from files of all tenants.
+// Parameters:
+//  - correlationId  string
+//  transaction id to trace execution through call chain.
+// Returns []interface{}, error
+// loaded items or error.
+func (c *TenantFilePersister) Load(correlationId string) (data []interface{}, err error) {
+	return c.LoadWithContext(ContextWithCorrelationId(context.Background(), correlationId))
+}
+
+// Loads data items from files of all tenants.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+// Returns []interface{}, error
+// loaded items or error.
+func (c *TenantFilePersister) LoadWithContext(ctx context.Context) (data []interface{}, err error) {
+	tenants, err := c.findTenants(CorrelationIdFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	data = make([]interface{}, 0)
+	for _, tenantId := range tenants {
+		persister, err := c.getPersister(CorrelationIdFromContext(ctx), tenantId)
+		if err != nil {
+			return nil, err
+		}
+		items, err := persister.LoadWithContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, items...)
+	}
+	return data, nil
+}
+
+// Saves given data items to files of their tenants.
+// Parameters:
+//   - correlationId string
+//   transaction id to trace execution through call chain.
+//   - items []interface[]
+//   list of data items to save
+// Retruns error
+// error or nil for success.
+func (c *TenantFilePersister) Save(correlationId string, items []interface{}) error {
+	return c.SaveWithContext(ContextWithCorrelationId(context.Background(), correlationId), items)
+}
+
+// Saves given data items to files of their tenants.
+// Items without tenant id or with tenant ids that cannot be a part of the path
+// cannot be saved, so no files are written when they are found.
+// Parameters:
+//   - ctx context.Context
+//   a context with deadline, cancellation and correlation id.
+//   - items []interface[]
+//   list of data items to save
+// Retruns error
+// error or nil for success.
+func (c *TenantFilePersister) SaveWithContext(ctx context.Context, items []interface{}) (err error) {
+	correlationId := CorrelationIdFromContext(ctx)
+	if err = c.checkPath(correlationId); err != nil {
+		return err
+	}
+
+	groups := make(map[string][]interface{})
+	for _, tenantId := range c.knownTenants() {
+		groups[tenantId] = make([]interface{}, 0)
+	}
+	for _, item := range items {
+		tenantId := convert.StringConverter.ToString(GetPropertyWithTag(item, c.TenantField, c.PropertyTag))
+		if tenantId == "" {
+			return errors.NewBadRequestError(correlationId, "NO_TENANT",
+				"Item without tenant id cannot be saved").WithDetails("id", GetObjectId(item))
+		}
+		if err = checkTenantPathId(correlationId, tenantId); err != nil {
+			return err
+		}
+		groups[tenantId] = append(groups[tenantId], item)
+	}
+
+	for tenantId, group := range groups {
+		persister, err := c.getPersister(correlationId, tenantId)
+		if err != nil {
+			return err
+		}
+		path := persister.Path()
+		if err = os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			return errors.NewFileError(correlationId, "WRITE_FAILED", "Failed to create data directory: "+path).WithCause(err)
+		}
+		if err = persister.SaveWithContext(ctx, group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,445 @@
+package persistence
+
+import (
+	"encoding"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reflection metadata of a type. It is computed once per type and shared
+// by property access, id generation, cloning and loading of items.
+type typeMetadata struct {
+	typ reflect.Type
+	// Fields of a struct type by property names from json tags and field names
+	fields *structFields
+	// Fields by property names from custom tags, *structFields by tag names
+	taggedFields sync.Map
+	// Names of fields marked by the id tag
+	idFields []string
+	// The single id field or nil when there is no id field or the key is composite
+	idField *fieldMetadata
+	// True when copies of values made by assignment are deep copies:
+	// the type has no pointers, maps, slices, interfaces or cloneable values
+	flat bool
+	// True when the type or a pointer to it implements ICloneable
+	cloneable bool
+	// Fields decoded by json.Unmarshal, nil when values of the type cannot be loaded directly
+	jsonFields *structFields
+}
+
+// Metadata of a struct field
+type fieldMetadata struct {
+	// Index of the field, including indexes of embedded and nested structs on its path
+	index []int
+	typ   reflect.Type
+	// True when the field is encoded as a string: `json:",string"`
+	quoted bool
+}
+
+// Indexes of struct fields by property names
+type structFields struct {
+	// Exact property names
+	exact map[string]*fieldMetadata
+	// The same names in lower case for case insensitive lookups
+	folded map[string]*fieldMetadata
+}
+
+var typeMetadataCache sync.Map
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Gets metadata of a type from the cache. Fields of pointer types are fields of structs they point to.
+func getTypeMetadata(typ reflect.Type) *typeMetadata {
+	if metadata, ok := typeMetadataCache.Load(typ); ok {
+		return metadata.(*typeMetadata)
+	}
+
+	metadata := newTypeMetadata(typ)
+	actual, _ := typeMetadataCache.LoadOrStore(typ, metadata)
+	return actual.(*typeMetadata)
+}
+
+func newTypeMetadata(typ reflect.Type) *typeMetadata {
+	c := &typeMetadata{typ: typ}
+	c.cloneable = typ.Implements(cloneableType) ||
+		(typ.Kind() == reflect.Struct && reflect.PtrTo(typ).Implements(cloneableType))
+	c.flat = !c.cloneable && isFlatType(typ)
+
+	structType := typ
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return c
+	}
+
+	c.fields = newStructFields()
+	c.fields.collect(structType, nil, "")
+	c.idFields = collectIdFields(structType, nil)
+	switch len(c.idFields) {
+	case 0:
+		c.idField, _ = c.fields.find("Id")
+	case 1:
+		c.idField, _ = c.fields.find(c.idFields[0])
+	}
+
+	if typ.Kind() == reflect.Struct && !isJsonUnmarshaler(typ) {
+		c.jsonFields = newStructFields()
+		if !c.jsonFields.collectJson(typ, nil) {
+			c.jsonFields = nil
+		}
+	}
+	return c
+}
+
+// Checks if values of the type are deep copied by assignment
+func isFlatType(typ reflect.Type) bool {
+	// Times only refer to immutable locations
+	if typ == timeType {
+		return true
+	}
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		return false
+	case reflect.Array:
+		return getTypeMetadata(typ.Elem()).flat
+	case reflect.Struct:
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if !getTypeMetadata(field.Type).flat {
+				return false
+			}
+		}
+		return true
+	default:
+		return true
+	}
+}
+
+func isJsonUnmarshaler(typ reflect.Type) bool {
+	ptr := reflect.PtrTo(typ)
+	return typ.Implements(jsonUnmarshalerType) || ptr.Implements(jsonUnmarshalerType) ||
+		typ.Implements(textUnmarshalerType) || ptr.Implements(textUnmarshalerType)
+}
+
+// Gets fields of a struct type by property names from a custom tag,
+// json tags and field names. It returns nil for types that are not structs.
+func (c *typeMetadata) properties(tag string) *structFields {
+	if tag == "" || c.fields == nil {
+		return c.fields
+	}
+	if fields, ok := c.taggedFields.Load(tag); ok {
+		return fields.(*structFields)
+	}
+
+	structType := c.typ
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	fields := newStructFields()
+	fields.collect(structType, nil, tag)
+	actual, _ := c.taggedFields.LoadOrStore(tag, fields)
+	return actual.(*structFields)
+}
+
+func newStructFields() *structFields {
+	return &structFields{
+		exact:  make(map[string]*fieldMetadata),
+		folded: make(map[string]*fieldMetadata),
+	}
+}
+
+// Appends an index to a copy of the index path
+func appendIndex(prefix []int, index int) []int {
+	return append(append(make([]int, 0, len(prefix)+1), prefix...), index)
+}
+
+// Adds fields of a struct type. Fields declared in the struct hide fields of nested structs.
+func (c *structFields) collect(typ reflect.Type, prefix []int, tag string) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		metadata := &fieldMetadata{index: appendIndex(prefix, i), typ: field.Type}
+		if tag != "" {
+			c.add(tagName(field, tag), metadata)
+		}
+		c.add(tagName(field, "json"), metadata)
+		c.add(field.Name, metadata)
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Type.Kind() == reflect.Struct && (field.Anonymous || field.PkgPath == "") {
+			c.collect(field.Type, appendIndex(prefix, i), tag)
+		}
+	}
+}
+
+// Adds fields decoded by json.Unmarshal, embedded structs are flattened.
+// Returns false when some fields cannot be loaded directly from values decoded from JSON.
+func (c *structFields) collectJson(typ reflect.Type, prefix []int) bool {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := tagName(field, "json")
+		if field.Anonymous && name == "" {
+			if field.Type.Kind() == reflect.Struct {
+				if isJsonUnmarshaler(field.Type) || !c.collectJson(field.Type, appendIndex(prefix, i)) {
+					return false
+				}
+				continue
+			}
+			if field.PkgPath != "" {
+				continue
+			}
+			if field.Type.Kind() == reflect.Ptr {
+				return false
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		metadata := &fieldMetadata{index: appendIndex(prefix, i), typ: field.Type, quoted: hasTagOption(tag, "string")}
+		if !isLoadableField(metadata) {
+			return false
+		}
+		// Conflicting names are resolved by json.Unmarshal
+		if _, ok := c.exact[name]; ok {
+			return false
+		}
+		c.add(name, metadata)
+	}
+	return true
+}
+
+// Checks if values decoded from JSON can be set into the field directly
+func isLoadableField(field *fieldMetadata) bool {
+	if field.typ == timeType {
+		return !field.quoted
+	}
+	if isJsonUnmarshaler(field.typ) {
+		return false
+	}
+	kind := field.typ.Kind()
+	return kind == reflect.Bool || kind == reflect.String || (isNumericKind(kind) && kind != reflect.Uintptr)
+}
+
+func (c *structFields) add(name string, field *fieldMetadata) {
+	if name == "" {
+		return
+	}
+	if _, ok := c.exact[name]; !ok {
+		c.exact[name] = field
+	}
+	folded := strings.ToLower(name)
+	if _, ok := c.folded[folded]; !ok {
+		c.folded[folded] = field
+	}
+}
+
+// Finds a field by its name, the exact names are preferred
+func (c *structFields) find(name string) (*fieldMetadata, bool) {
+	if field, ok := c.exact[name]; ok {
+		return field, true
+	}
+	field, ok := c.folded[strings.ToLower(name)]
+	return field, ok
+}
+
+// Gets the property name of a field from a tag like `json:"name,omitempty"`
+func tagName(field reflect.StructField, tag string) string {
+	name := field.Tag.Get(tag)
+	if index := strings.IndexByte(name, ','); index >= 0 {
+		name = name[:index]
+	}
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// Checks if a tag like `json:"name,omitempty"` has an option
+func hasTagOption(tag string, option string) bool {
+	options := strings.Split(tag, ",")
+	for _, v := range options[1:] {
+		if v == option {
+			return true
+		}
+	}
+	return false
+}
+
+// Gets names of fields marked by the id tag in a struct type and its embedded structs
+func collectIdFields(typ reflect.Type, fields []string) []string {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := strings.Split(field.Tag.Get(IdTag), ",")
+		if strings.TrimSpace(tag[0]) == "id" {
+			fields = append(fields, field.Name)
+		} else if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			fields = collectIdFields(field.Type, fields)
+		}
+	}
+	return fields
+}
+
+// Creates a value of the struct type from a map decoded from JSON
+// the same way as json.Unmarshal does.
+// Returns false when some values cannot be set directly, then the map shall be decoded from JSON.
+func (c *typeMetadata) loadStruct(data map[string]interface{}) (interface{}, bool) {
+	if c.jsonFields == nil {
+		return nil, false
+	}
+
+	result := reflect.New(c.typ).Elem()
+	for key, value := range data {
+		field, ok := c.jsonFields.find(key)
+		// Nulls leave fields unchanged
+		if !ok || value == nil {
+			continue
+		}
+		if !loadFieldValue(value, field, result.FieldByIndex(field.index)) {
+			return nil, false
+		}
+	}
+	return result.Interface(), true
+}
+
+// Sets a value decoded from JSON into a field
+func loadFieldValue(value interface{}, field *fieldMetadata, target reflect.Value) bool {
+	if field.typ == timeType {
+		switch v := value.(type) {
+		case string:
+			result, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return false
+			}
+			target.Set(reflect.ValueOf(result))
+			return true
+		case time.Time:
+			target.Set(reflect.ValueOf(v))
+			return true
+		default:
+			return false
+		}
+	}
+
+	if field.quoted {
+		text, ok := value.(string)
+		return ok && parseFieldValue(text, target)
+	}
+
+	val := reflect.ValueOf(value)
+	kind := target.Kind()
+	switch {
+	case kind == reflect.String:
+		if val.Kind() != reflect.String {
+			return false
+		}
+		target.SetString(val.String())
+	case kind == reflect.Bool:
+		if val.Kind() != reflect.Bool {
+			return false
+		}
+		target.SetBool(val.Bool())
+	case !isNumericKind(val.Kind()):
+		return false
+	case kind == reflect.Float32 && val.Kind() == reflect.Float64:
+		// Rounded the same way as the JSON representation of the number
+		return parseFieldValue(strconv.FormatFloat(val.Float(), 'g', -1, 64), target)
+	case kind == reflect.Float32 || kind == reflect.Float64:
+		target.SetFloat(toFloat(val))
+	case val.Kind() == reflect.Float32 || val.Kind() == reflect.Float64:
+		number := val.Float()
+		if number != math.Trunc(number) || math.Abs(number) >= 1<<53 {
+			// Large numbers are parsed from their JSON representation
+			return parseFieldValue(strconv.FormatFloat(number, 'f', -1, 64), target)
+		}
+		return setInteger(target, int64(number), number < 0)
+	case isSignedKind(val.Kind()):
+		return setInteger(target, val.Int(), val.Int() < 0)
+	default:
+		if val.Uint() > math.MaxInt64 {
+			if isSignedKind(kind) || target.OverflowUint(val.Uint()) {
+				return false
+			}
+			target.SetUint(val.Uint())
+			return true
+		}
+		return setInteger(target, int64(val.Uint()), false)
+	}
+	return true
+}
+
+// Sets an integer into a signed or unsigned field when it fits into the field
+func setInteger(target reflect.Value, number int64, negative bool) bool {
+	if isSignedKind(target.Kind()) {
+		if target.OverflowInt(number) {
+			return false
+		}
+		target.SetInt(number)
+		return true
+	}
+	if negative || target.OverflowUint(uint64(number)) {
+		return false
+	}
+	target.SetUint(uint64(number))
+	return true
+}
+
+// Parses a field value from a string
+func parseFieldValue(text string, target reflect.Value) bool {
+	kind := target.Kind()
+	bits := target.Type().Bits
+	switch {
+	case kind == reflect.Bool:
+		if text != "true" && text != "false" {
+			return false
+		}
+		target.SetBool(text == "true")
+	case kind == reflect.String:
+		// Quoted strings contain JSON strings, the ones with escapes are decoded from JSON
+		if len(text) < 2 || text[0] != '"' || text[len(text)-1] != '"' || strings.IndexByte(text, '\\') >= 0 {
+			return false
+		}
+		target.SetString(text[1 : len(text)-1])
+	case isSignedKind(kind):
+		number, err := strconv.ParseInt(text, 10, bits())
+		if err != nil {
+			return false
+		}
+		target.SetInt(number)
+	case isUnsignedKind(kind):
+		number, err := strconv.ParseUint(text, 10, bits())
+		if err != nil {
+			return false
+		}
+		target.SetUint(number)
+	case kind == reflect.Float32 || kind == reflect.Float64:
+		number, err := strconv.ParseFloat(text, bits())
+		if err != nil {
+			return false
+		}
+		target.SetFloat(number)
+	default:
+		return false
+	}
+	return true
+}
@@ -1,41 +1,14 @@
 package persistence
 
 import (
-	"fmt"
+	"encoding/json"
 	"reflect"
-	"strings"
-	"unicode"
-	"unicode/utf8"
 
-	"github.com/jinzhu/copier"
 	"github.com/pip-services3-go/pip-services3-commons-go/convert"
-	cdata "github.com/pip-services3-go/pip-services3-commons-go/data"
+	"github.com/pip-services3-go/pip-services3-commons-go/errors"
 	refl "github.com/pip-services3-go/pip-services3-commons-go/reflect"
 )
 
-func toFieldType(obj interface{}) reflect.Type {
-	// Unwrap value
-	wrap, ok := obj.(refl.IValueWrapper)
-	if ok {
-		obj = wrap.InnerValue()
-	}
-
-	// Move from pointer to real struct
-	typ := reflect.TypeOf(obj)
-	if typ.Kind() == reflect.Ptr {
-		typ = typ.Elem()
-	}
-
-	return typ
-}
-
-func matchField(field reflect.StructField, name string) bool {
-	// Field must be public and match to name as case insensitive
-	r, _ := utf8.DecodeRuneInString(field.Name)
-	return unicode.IsUpper(r) &&
-		strings.ToLower(field.Name) == strings.ToLower(name)
-}
-
 func getValue(obj interface{}) interface{} {
 	wrap, ok := obj.(refl.IValueWrapper)
 	if ok {
@@ -46,6 +19,10 @@ func getValue(obj interface{}) interface{} {
 }
 
 // Gets value of object property specified by its name.
+// Struct fields are matched by names from json tags and by field names,
+// case insensitive matches are used when there are no exact ones.
+// Use GetPropertyWithTag to match names from a custom struct tag.
+// Nested properties are specified by paths like "address.city" or "lines[0].text".
 // Parameters:
 //   - obj interface{}
 //   an object to read property from.
@@ -54,61 +31,12 @@ func getValue(obj interface{}) interface{} {
 // Returns interface{}
 // the property value or null if property doesn't exist or introspection failed.
 func GetProperty(obj interface{}, name string) interface{} {
-	if obj == nil || name == "" {
-		return nil
-	}
-
-	obj = getValue(obj)
-	val := reflect.ValueOf(obj)
-
-	if val.Kind() == reflect.Map {
-		name = strings.ToLower(name)
-		for _, v := range val.MapKeys() {
-			key := convert.StringConverter.ToString(v.Interface())
-			key = strings.ToLower(key)
-			if name == key {
-				return val.MapIndex(v).Interface()
-			}
-		}
-		return nil
-	}
-
-	defer func() {
-		// Do nothing and return nil
-		recover()
-	}()
-
-	fieldType := toFieldType(obj)
-	if fieldType.Kind() == reflect.Struct {
-		return getPropertyRecursive(fieldType, obj, name)
-	}
-
-	return nil
-}
-
-func getPropertyRecursive(fieldType reflect.Type, obj interface{}, name string) interface{} {
-	for index := 0; index < fieldType.NumField(); index++ {
-		field := fieldType.Field(index)
-		val := reflect.ValueOf(obj)
-		if val.Kind() == reflect.Ptr {
-			val = val.Elem()
-		}
-		switch field.Type.Kind() {
-		default:
-			if matchField(field, name) {
-				return val.Field(index).Interface()
-			}
-		case reflect.Struct:
-			if item := getPropertyRecursive(field.Type, val.Field(index).Interface(), name); item != nil {
-				return item
-			}
-		}
-	}
-
-	return nil
+	return GetPropertyWithTag(obj, name, "")
 }
 
 // Sets value of object property specified by its name.
+// Properties are matched the same way as in GetProperty,
+// values are converted to types of the properties when possible.
 // If the property does not exist or introspection fails this method doesn't do anything and doesn't any throw errors.
 // Parameters:
 //   - obj interface{}
@@ -118,71 +46,22 @@ func getPropertyRecursive(fieldType reflect.Type, obj interface{}, name string)
 //   - value interface{}
 //   a new value for the property to set.
 func SetProperty(obj interface{}, name string, value interface{}) {
-	if obj == nil || name == "" {
-		return
-	}
-
-	obj = getValue(obj)
-	val := reflect.ValueOf(obj)
-
-	if val.Kind() == reflect.Map {
-		name = strings.ToLower(name)
-		for _, v := range val.MapKeys() {
-			key := convert.StringConverter.ToString(v.Interface())
-			key = strings.ToLower(key)
-			if name == key {
-				val.SetMapIndex(v, reflect.ValueOf(value))
-				return
-			}
-		}
-		val.SetMapIndex(reflect.ValueOf(name), reflect.ValueOf(value))
-		return
-	}
-
-	defer func() {
-		// Do nothing and return nil
-		if err := recover(); err != nil {
-			fmt.Println("Error while set property %v", err)
-		}
-	}()
-
-	fieldType := toFieldType(obj)
-	if fieldType.Kind() == reflect.Struct {
-		setPropertyRecursive(fieldType, obj, name, value)
-	}
-
-}
-
-func setPropertyRecursive(fieldType reflect.Type, obj interface{}, name string, value interface{}) {
-	for index := 0; index < fieldType.NumField(); index++ {
-		field := fieldType.Field(index)
-		val := reflect.ValueOf(obj)
-		if val.Kind() == reflect.Ptr {
-			val = val.Elem()
-		}
-		switch field.Type.Kind() {
-		default:
-			if matchField(field, name) {
-				val.Field(index).Set(reflect.ValueOf(value))
-				return
-			}
-		case reflect.Struct:
-			setPropertyRecursive(field.Type, val.Field(index).Addr().Interface(), name, value)
-		}
-	}
+	SetPropertyWithTag(obj, name, value, "")
 }
 
-// Get object Id value
+// Get object Id value.
+// Fields marked with persist:"id" tag are used as the id, see GetObjectIdByFields.
 // Parameters:
 //   - item interface{}
 //   an object to read property from.
 // Returns interface{}
 // the property value or nil if property doesn't exist or introspection failed.
 func GetObjectId(item interface{}) interface{} {
-	return GetProperty(item, "Id")
+	return GetObjectIdByFields(item, nil)
 }
 
-// SetObjectId is set object Id value
+// SetObjectId is set object Id value.
+// Fields marked with persist:"id" tag are used as the id, see SetObjectIdByFields.
 // Parameters:
 //   - item *interface{}
 //   an pointer on object to set id property
@@ -190,79 +69,178 @@ func GetObjectId(item interface{}) interface{} {
 //   id value for set
 // Results saved in input object
 func SetObjectId(item *interface{}, id interface{}) {
-	value := *item
-	if reflect.ValueOf(value).Kind() == reflect.Map {
-		//refl.ObjectWriter.SetProperty(value, "Id", id)
-		SetProperty(value, "Id", id)
+	SetObjectIdByFields(item, nil, id)
+}
+
+// Sets object property value using names from a struct tag, struct values are copied to set the property
+func setObjectProperty(item *interface{}, name string, value interface{}, tag string) {
+	obj := *item
+	if reflect.ValueOf(obj).Kind() == reflect.Map || reflect.ValueOf(obj).Kind() == reflect.Ptr {
+		SetPropertyWithTag(obj, name, value, tag)
 	} else {
-		typePointer := reflect.New(reflect.TypeOf(value))
-		typePointer.Elem().Set(reflect.ValueOf(value))
+		typePointer := reflect.New(reflect.TypeOf(obj))
+		typePointer.Elem().Set(reflect.ValueOf(obj))
 		typeInterface := typePointer.Interface()
-		//refl.ObjectWriter.SetProperty(typeInterface, "Id", id)
-		SetProperty(typeInterface, "Id", id)
+		SetPropertyWithTag(typeInterface, name, value, tag)
 		*item = reflect.ValueOf(typeInterface).Elem().Interface()
 	}
 }
 
-// GenerateObjectId is generates a new id value when it's empty
+// GenerateObjectId is generates a new id value when it's empty.
+// String ids are generated by LongIdGenerator. Integer ids cannot be generated without
+// knowing stored ids, use GenerateObjectIdWithGenerator with a SequenceIdGenerator for them.
 // Parameters:
 //   - item *interface{}
 //   an pointer on object to set id property
 // Results saved in input object
-func GenerateObjectId(item *interface{}) {
+// Returns error
+// ConfigError when the object has no id field, has an integer id or the id cannot be converted to its type.
+func GenerateObjectId(item *interface{}) error {
+	return GenerateObjectIdWithGenerator(item, nil)
+}
+
+// GenerateObjectIdWithGenerator is generates a new id value when it's empty using an id generator.
+// Generated ids are converted to the type of the id field, so integer generators
+// can fill string ids and sequences of strings with digits can fill integer ids.
+// Parameters:
+//   - item *interface{}
+//   an pointer on object to set id property
+//   - generator IIdGenerator
+//   (optional) a generator of ids, by default LongIdGenerator is used.
+//   It is required for integer ids.
+// Results saved in input object
+// Returns error
+// ConfigError when the object has no id field, the generator is missing for an integer id
+// or the id cannot be converted to its type.
+func GenerateObjectIdWithGenerator(item *interface{}, generator IIdGenerator) error {
+	return generateObjectIdByFields(item, nil, generator, "")
+}
+
+// Generates a new id value in id fields when it's empty.
+// Fields are matched by names from json tags and a struct tag.
+// Composite keys are never generated.
+func generateObjectIdByFields(item *interface{}, fields []string, generator IIdGenerator, tag string) error {
 	value := *item
-	idField := GetProperty(value, "Id")
-	if idField != nil {
-		if reflect.ValueOf(idField).IsZero() {
-			SetObjectId(item, cdata.IdGenerator.NextLong())
-		}
-	} else {
-		panic("'Id' or 'ID' field doesn't exist")
+	if value == nil {
+		return errors.NewConfigError("", "NO_ID_FIELD", "'Id' or 'ID' field doesn't exist")
 	}
 
-}
+	fields = getIdFields(value, fields)
+	if len(fields) > 1 {
+		return nil
+	}
+	name := "Id"
+	if len(fields) == 1 {
+		name = fields[0]
+	}
 
-// CloneObject is clones object function
-// Parameters:
-//   - item interface{}
-//   an object to clone
-// Return interface{}
-// copy of input item
-func CloneObject(item interface{}, proto reflect.Type) interface{} {
-	var dest interface{}
-	var src = item
+	val := reflect.ValueOf(value)
+	isMap := val.Kind() == reflect.Map
+	var field *fieldMetadata
+	var idField interface{}
+	if val.Kind() == reflect.Struct {
+		// Fields of struct values are read and set by their cached indexes
+		field, _ = getTypeMetadata(val.Type()).properties(tag).find(name)
+	} else {
+		idField = GetPropertyWithTag(value, name, tag)
+	}
+	if field == nil && idField == nil && !isMap {
+		return errors.NewConfigError("", "NO_ID_FIELD", "'"+name+"' field doesn't exist").
+			WithDetails("type", reflect.TypeOf(value).String())
+	}
 
-	if reflect.ValueOf(src).Kind() == reflect.Map {
-		itemType := reflect.TypeOf(src)
-		mapType := reflect.MapOf(itemType.Key(), itemType.Elem())
-		newMap := reflect.MakeMap(mapType)
-		dest = newMap.Interface()
-		err := copier.CopyWithOption(&dest, src, copier.Option{DeepCopy: false, IgnoreEmpty: false})
-		if err != nil {
+	var idType reflect.Type
+	if field != nil {
+		if !val.FieldByIndex(field.index).IsZero() {
 			return nil
 		}
-
+		idType = field.typ
 	} else {
-		var destPtr reflect.Value
-		if proto.Kind() == reflect.Ptr {
-			destPtr = reflect.New(proto.Elem())
-		} else {
-			destPtr = reflect.New(proto)
+		if idField != nil && !reflect.ValueOf(idField).IsZero() {
+			return nil
 		}
-		if reflect.TypeOf(src).Kind() == reflect.Ptr {
-			src = reflect.ValueOf(src).Elem().Interface()
+		idType = reflect.TypeOf(idField)
+	}
+	if generator == nil {
+		// Integer ids generated without stored ids collide with them
+		if idType != nil && !isMap && isNumericKind(idType.Kind()) {
+			return errors.NewConfigError("", "NO_ID_GENERATOR", "Integer ids require an id generator").
+				WithDetails("type", idType.String())
 		}
-		err := copier.CopyWithOption(destPtr.Interface(), src, copier.Option{DeepCopy: false, IgnoreEmpty: false})
-		if err != nil {
-			return nil
+		generator = NewLongIdGenerator()
+	}
+
+	id := generator.NextId()
+	// Maps keep ids in types they were generated
+	if idType != nil && !isMap {
+		var ok bool
+		if id, ok = convertId(id, idType); !ok {
+			return errors.NewConfigError("", "INVALID_ID_TYPE", "Generated id cannot be converted to the id field type").
+				WithDetails("type", idType.String())
 		}
+	}
+
+	if field != nil {
+		result := reflect.New(val.Type()).Elem()
+		result.Set(val)
+		result.FieldByIndex(field.index).Set(reflect.ValueOf(id))
+		*item = result.Interface()
+		return nil
+	}
+	setObjectProperty(item, name, id, tag)
+	return nil
+}
+
+// Converts a generated id to the type of the id field
+func convertId(id interface{}, idType reflect.Type) (interface{}, bool) {
+	value := reflect.ValueOf(id)
+	if !value.IsValid() {
+		return nil, false
+	}
+	if value.Type() == idType {
+		return id, true
+	}
 
-		dest = destPtr.Elem().Interface()
+	switch {
+	case idType.Kind() == reflect.String:
+		return reflect.ValueOf(convert.StringConverter.ToString(id)).Convert(idType).Interface(), true
+	case isNumericKind(idType.Kind()):
+		if value.Kind() == reflect.String {
+			number, ok := parseNumber(value)
+			if !ok {
+				return nil, false
+			}
+			value = number
+		}
+		if !isNumericKind(value.Kind()) {
+			return nil, false
+		}
+		result := value.Convert(idType)
+		// Reject ids that do not fit into the field
+		if compareNumbers(result, value) != 0 {
+			return nil, false
+		}
+		return result.Interface(), true
+	case value.Type().ConvertibleTo(idType):
+		return value.Convert(idType).Interface(), true
+	default:
+		return nil, false
 	}
-	return dest
 }
 
-// CloneObjectForResult is clones object for result function
+// CloneObject is clones object function.
+// The object is copied deeply, so the copy shares no maps, slices or pointers with it.
+// Parameters:
+//   - item interface{}
+//   an object to clone
+// Return interface{}
+// copy of input item
+func CloneObject(item interface{}, proto reflect.Type) interface{} {
+	return CloneObjectWithStrategy(item, proto, CloneDeep)
+}
+
+// CloneObjectForResult is clones object for result function.
+// The object is copied deeply, so the copy shares no maps, slices or pointers with it.
 // Parameters:
 // 	  - item interface{}
 // 	  an object to clone
@@ -271,39 +249,11 @@ func CloneObject(item interface{}, proto reflect.Type) interface{} {
 // Return interface{}
 // copy of input item
 func CloneObjectForResult(src interface{}, proto reflect.Type) interface{} {
-	var dest interface{}
-
-	if reflect.ValueOf(src).Kind() == reflect.Map {
-		itemType := reflect.TypeOf(src)
-		mapType := reflect.MapOf(itemType.Key(), itemType.Elem())
-		newMap := reflect.MakeMap(mapType)
-		dest = newMap.Interface()
-		err := copier.CopyWithOption(&dest, src, copier.Option{DeepCopy: false, IgnoreEmpty: false})
-		if err != nil {
-			return nil
-		}
-	} else {
-		var destPtr reflect.Value
-		if proto.Kind() == reflect.Ptr {
-			destPtr = reflect.New(proto.Elem())
-		} else {
-			destPtr = reflect.New(proto)
-		}
-		err := copier.CopyWithOption(destPtr.Interface(), src, copier.Option{DeepCopy: false, IgnoreEmpty: false})
-		if err != nil {
-			return nil
-		}
-		// make pointer on clone object, if proto is ptr
-		dest = destPtr.Elem().Interface()
-		if proto.Kind() == reflect.Ptr {
-			dest = destPtr.Interface()
-		}
-	}
-
-	return dest
+	return CloneObjectForResultWithStrategy(src, proto, CloneDeep)
 }
 
-// CompareValues are ompares two values
+// CompareValues are ompares two values using ValueComparer,
+// so numbers of different types like int64(1) and 1.0 are equal
 // Parameters:
 //   - value1 interface{}
 //   an object one for compare
@@ -312,8 +262,33 @@ func CloneObjectForResult(src interface{}, proto reflect.Type) interface{} {
 // Return bool
 // true if value1 equal value2 and false otherwise
 func CompareValues(value1 interface{}, value2 interface{}) bool {
-	// Todo: Implement proper comparison
-	return value1 == value2
+	return ValueComparer.Equals(value1, value2)
+}
+
+// Converts items received from a loader into values of the prototype type.
+// Loaded items are expected to be maps decoded from JSON.
+// Maps are set into structs with simple fields directly, other items are decoded from JSON.
+func convertToPrototype(items []interface{}, prototype reflect.Type) []interface{} {
+	metadata := getTypeMetadata(prototype)
+	result := make([]interface{}, len(items))
+	for i, v := range items {
+		if data, ok := v.(map[string]interface{}); ok {
+			if value, ok := metadata.loadStruct(data); ok {
+				result[i] = value
+				continue
+			}
+		}
+
+		item := convert.MapConverter.ToNullableMap(v)
+		jsonMarshalStr, errJson := json.Marshal(item)
+		if errJson != nil {
+			panic("MemoryPersistence.Load Error can't convert from Json to type")
+		}
+		value := reflect.New(prototype).Interface()
+		json.Unmarshal(jsonMarshalStr, value)
+		result[i] = reflect.ValueOf(value).Elem().Interface() // load value
+	}
+	return result
 }
 
 // Convert methods
@@ -0,0 +1,448 @@
+package persistence
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Helper class that compares values of different types used as ids, filter values and sort keys.
+
+Values are ranked by their types first and then compared by value within each rank.
+The ranks go in this order:
+
+- nil values, including nil pointers and interfaces
+- booleans, false is less than true
+- numbers of all types are compared by value, so int64(1) is equal to float64(1) and uint8(1)
+- strings and byte slices are compared by content
+- time.Time values are compared as instants
+- slices and arrays are compared element by element
+- maps are compared by size, then by keys and values in order of keys
+- other values are ordered by their type names and compared field by field
+
+Pointers are compared by the values they point to.
+Strings that contain numbers are compared with numbers by value, so "1" is equal
+to int64(1) and "10" is greater than 9, while strings are compared with each other
+by content. So values that mix numbers with such strings are not ordered transitively.
+Values of other different ranks are never equal.
+
+Example
+
+    ValueComparer.Equals("1", int64(1))         // Result: true
+    ValueComparer.Compare(2, 10.5)              // Result: -1
+    ValueComparer.Less(9, "10")                 // Result: true
+    ValueComparer.Less(10, "abc")               // Result: true
+*/
+type TValueComparer struct{}
+
+// Default instance of the value comparer
+var ValueComparer *TValueComparer = &TValueComparer{}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Checks if two values are equal.
+// Parameters:
+//   - value1 interface{}
+//   the first value to compare
+//   - value2 interface{}
+//   the second value to compare
+// Returns bool
+// true if the values are equal and false otherwise
+func (c *TValueComparer) Equals(value1 interface{}, value2 interface{}) bool {
+	return c.Compare(value1, value2) == 0
+}
+
+// Checks if the first value is less than the second one.
+// Parameters:
+//   - value1 interface{}
+//   the first value to compare
+//   - value2 interface{}
+//   the second value to compare
+// Returns bool
+// true if value1 < value2 and false otherwise
+func (c *TValueComparer) Less(value1 interface{}, value2 interface{}) bool {
+	return c.Compare(value1, value2) < 0
+}
+
+// Compares two values.
+// Parameters:
+//   - value1 interface{}
+//   the first value to compare
+//   - value2 interface{}
+//   the second value to compare
+// Returns int
+// negative value when value1 < value2, 0 when they are equal and positive value otherwise
+func (c *TValueComparer) Compare(value1 interface{}, value2 interface{}) int {
+	// Fast path for string ids and keys
+	if string1, ok := value1.(string); ok {
+		if string2, ok := value2.(string); ok {
+			return strings.Compare(string1, string2)
+		}
+	}
+	return compareReflectValues(reflect.ValueOf(value1), reflect.ValueOf(value2))
+}
+
+// Ranks of values compared by ValueComparer, values of lower ranks are less
+const (
+	rankNil = iota
+	rankBool
+	rankNumber
+	rankText
+	rankTime
+	rankList
+	rankMap
+	rankOther
+)
+
+// Gets the rank of a value without pointers and interfaces around it
+func valueRank(value reflect.Value) int {
+	if !value.IsValid() {
+		return rankNil
+	}
+	kind := value.Kind()
+	switch {
+	case kind == reflect.Bool:
+		return rankBool
+	case isNumericKind(kind):
+		return rankNumber
+	case kind == reflect.String || isBytes(value):
+		return rankText
+	case value.Type() == timeType:
+		return rankTime
+	case kind == reflect.Slice || kind == reflect.Array:
+		return rankList
+	case kind == reflect.Map:
+		return rankMap
+	default:
+		return rankOther
+	}
+}
+
+// Removes pointers and interfaces around a value
+func indirectValue(value reflect.Value) reflect.Value {
+	for value.IsValid() && (value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface) {
+		if value.IsNil() {
+			return reflect.Value{}
+		}
+		value = value.Elem()
+	}
+	return value
+}
+
+func isNumericKind(kind reflect.Kind) bool {
+	return isSignedKind(kind) || isUnsignedKind(kind) || kind == reflect.Float32 || kind == reflect.Float64
+}
+
+func isSignedKind(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Int64
+}
+
+func isUnsignedKind(kind reflect.Kind) bool {
+	return kind >= reflect.Uint && kind <= reflect.Uintptr
+}
+
+func isBytes(value reflect.Value) bool {
+	return value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Uint8
+}
+
+func compareInts(value1 int, value2 int) int {
+	switch {
+	case value1 < value2:
+		return -1
+	case value1 > value2:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareInt64s(value1 int64, value2 int64) int {
+	switch {
+	case value1 < value2:
+		return -1
+	case value1 > value2:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareUint64s(value1 uint64, value2 uint64) int {
+	switch {
+	case value1 < value2:
+		return -1
+	case value1 > value2:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareFloats(value1 float64, value2 float64) int {
+	switch {
+	case value1 < value2:
+		return -1
+	case value1 > value2:
+		return 1
+	// NaN values are equal to each other and less than numbers
+	case math.IsNaN(value1) && !math.IsNaN(value2):
+		return -1
+	case math.IsNaN(value2) && !math.IsNaN(value1):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Compares numbers of any kinds without losing precision of integers
+func compareNumbers(value1 reflect.Value, value2 reflect.Value) int {
+	kind1, kind2 := value1.Kind(), value2.Kind()
+	switch {
+	case isSignedKind(kind1) && isSignedKind(kind2):
+		return compareInt64s(value1.Int(), value2.Int())
+	case isUnsignedKind(kind1) && isUnsignedKind(kind2):
+		return compareUint64s(value1.Uint(), value2.Uint())
+	case isSignedKind(kind1) && isUnsignedKind(kind2):
+		if value1.Int() < 0 {
+			return -1
+		}
+		return compareUint64s(uint64(value1.Int()), value2.Uint())
+	case isSignedKind(kind1):
+		return compareIntFloat(value1.Int(), value2.Float())
+	case isUnsignedKind(kind1) && isSignedKind(kind2):
+		return -compareNumbers(value2, value1)
+	case isUnsignedKind(kind1):
+		return compareUintFloat(value1.Uint(), value2.Float())
+	case isSignedKind(kind2) || isUnsignedKind(kind2):
+		return -compareNumbers(value2, value1)
+	default:
+		return compareFloats(value1.Float(), value2.Float())
+	}
+}
+
+// Compares an integer with a float exactly, without converting the integer to float
+func compareIntFloat(value1 int64, value2 float64) int {
+	switch {
+	case math.IsNaN(value2):
+		return 1
+	case value2 >= math.MaxInt64:
+		return -1
+	case value2 < math.MinInt64:
+		return 1
+	}
+	whole := math.Floor(value2)
+	if result := compareInt64s(value1, int64(whole)); result != 0 {
+		return result
+	}
+	if value2 > whole {
+		return -1
+	}
+	return 0
+}
+
+// Compares an unsigned integer with a float exactly, without converting the integer to float
+func compareUintFloat(value1 uint64, value2 float64) int {
+	switch {
+	case math.IsNaN(value2) || value2 < 0:
+		return 1
+	case value2 >= math.MaxUint64:
+		return -1
+	}
+	whole := math.Floor(value2)
+	if result := compareUint64s(value1, uint64(whole)); result != 0 {
+		return result
+	}
+	if value2 > whole {
+		return -1
+	}
+	return 0
+}
+
+func boolToInt(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+func toFloat(value reflect.Value) float64 {
+	switch {
+	case isSignedKind(value.Kind()):
+		return float64(value.Int())
+	case isUnsignedKind(value.Kind()):
+		return float64(value.Uint())
+	default:
+		return value.Float()
+	}
+}
+
+// Parses a number from a string value.
+// Integers are parsed without converting them to float, so they keep their precision.
+func parseNumber(value reflect.Value) (reflect.Value, bool) {
+	text := strings.TrimSpace(value.String())
+	if number, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return reflect.ValueOf(number), true
+	}
+	if number, err := strconv.ParseUint(text, 10, 64); err == nil {
+		return reflect.ValueOf(number), true
+	}
+	number, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return value, false
+	}
+	return reflect.ValueOf(number), true
+}
+
+func compareReflectValues(value1 reflect.Value, value2 reflect.Value) int {
+	value1 = indirectValue(value1)
+	value2 = indirectValue(value2)
+
+	rank1, rank2 := valueRank(value1), valueRank(value2)
+	// Strings that contain numbers are compared with numbers by value
+	if rank1 == rankNumber && value2.Kind() == reflect.String {
+		if number2, ok := parseNumber(value2); ok {
+			return compareNumbers(value1, number2)
+		}
+	}
+	if value1.Kind() == reflect.String && rank2 == rankNumber {
+		if number1, ok := parseNumber(value1); ok {
+			return compareNumbers(number1, value2)
+		}
+	}
+	if rank1 != rank2 {
+		return compareInts(rank1, rank2)
+	}
+
+	switch rank1 {
+	case rankNil:
+		return 0
+	case rankBool:
+		return compareInts(boolToInt(value1.Bool()), boolToInt(value2.Bool()))
+	case rankNumber:
+		return compareNumbers(value1, value2)
+	case rankText:
+		return bytes.Compare(textBytes(value1), textBytes(value2))
+	case rankTime:
+		return compareTimes(value1, value2)
+	case rankList:
+		return compareLists(value1, value2)
+	case rankMap:
+		return compareMaps(value1, value2)
+	default:
+		return compareOthers(value1, value2)
+	}
+}
+
+// Gets content of a string or a byte slice
+func textBytes(value reflect.Value) []byte {
+	if value.Kind() == reflect.String {
+		return []byte(value.String())
+	}
+	return value.Bytes()
+}
+
+// Compares time values as instants
+func compareTimes(value1 reflect.Value, value2 reflect.Value) int {
+	time1, ok1 := timeValue(value1)
+	time2, ok2 := timeValue(value2)
+	if !ok1 || !ok2 {
+		// Times in unexported fields cannot be read, they are compared by their content
+		return compareStructs(value1, value2)
+	}
+	switch {
+	case time1.Before(time2):
+		return -1
+	case time1.After(time2):
+		return 1
+	default:
+		return 0
+	}
+}
+
+func timeValue(value reflect.Value) (time.Time, bool) {
+	if !value.CanInterface() {
+		return time.Time{}, false
+	}
+	result, ok := value.Interface().(time.Time)
+	return result, ok
+}
+
+// Compares values of other kinds: values of different types are ordered by type names,
+// structs are compared field by field and values that cannot be ordered,
+// like functions and channels, are compared by their addresses
+func compareOthers(value1 reflect.Value, value2 reflect.Value) int {
+	type1, type2 := value1.Type(), value2.Type()
+	if type1 != type2 {
+		if result := strings.Compare(type1.String(), type2.String()); result != 0 {
+			return result
+		}
+		return strings.Compare(type1.PkgPath(), type2.PkgPath())
+	}
+
+	switch value1.Kind() {
+	case reflect.Struct:
+		return compareStructs(value1, value2)
+	case reflect.Complex64, reflect.Complex128:
+		complex1, complex2 := value1.Complex(), value2.Complex()
+		if result := compareFloats(real(complex1), real(complex2)); result != 0 {
+			return result
+		}
+		return compareFloats(imag(complex1), imag(complex2))
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return compareUint64s(uint64(value1.Pointer()), uint64(value2.Pointer()))
+	default:
+		return 0
+	}
+}
+
+// Compares structs of the same type field by field
+func compareStructs(value1 reflect.Value, value2 reflect.Value) int {
+	for i := 0; i < value1.NumField(); i++ {
+		if result := compareReflectValues(value1.Field(i), value2.Field(i)); result != 0 {
+			return result
+		}
+	}
+	return 0
+}
+
+// Compares slices and arrays element by element
+func compareLists(value1 reflect.Value, value2 reflect.Value) int {
+	for i := 0; i < value1.Len() && i < value2.Len(); i++ {
+		if result := compareReflectValues(value1.Index(i), value2.Index(i)); result != 0 {
+			return result
+		}
+	}
+	return compareInts(value1.Len(), value2.Len())
+}
+
+// Compares maps by size, then by sorted keys and values
+func compareMaps(value1 reflect.Value, value2 reflect.Value) int {
+	if result := compareInts(value1.Len(), value2.Len()); result != 0 {
+		return result
+	}
+
+	keys1 := sortedMapKeys(value1)
+	keys2 := sortedMapKeys(value2)
+	for i := range keys1 {
+		if result := compareReflectValues(keys1[i], keys2[i]); result != 0 {
+			return result
+		}
+		if result := compareReflectValues(value1.MapIndex(keys1[i]), value2.MapIndex(keys2[i])); result != 0 {
+			return result
+		}
+	}
+	return 0
+}
+
+func sortedMapKeys(value reflect.Value) []reflect.Value {
+	keys := value.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return compareReflectValues(keys[i], keys[j]) < 0
+	})
+	return keys
+}
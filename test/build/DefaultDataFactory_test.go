@@ -0,0 +1,66 @@
+package test_build
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	cconf "github.com/pip-services3-go/pip-services3-commons-go/config"
+	"github.com/pip-services3-go/pip-services3-commons-go/refer"
+	"github.com/pip-services3-go/pip-services3-data-go/build"
+	cpersist "github.com/pip-services3-go/pip-services3-data-go/persistence"
+	"github.com/stretchr/testify/assert"
+)
+
+type Beacon struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestDefaultDataFactory(t *testing.T) {
+	factory := build.NewDefaultDataFactory()
+	factory.RegisterPrototype("beacon", reflect.TypeOf(Beacon{}))
+	assert.Equal(t, reflect.TypeOf(Beacon{}), factory.GetPrototype("beacon"))
+	assert.Nil(t, factory.GetPrototype("unknown"))
+
+	locator := refer.NewDescriptor("pip-services", "persistence", "memory", "beacon", "1.0")
+	assert.NotNil(t, factory.CanCreate(locator))
+	component, err := factory.Create(locator)
+	assert.Nil(t, err)
+	memory, ok := component.(*cpersist.IdentifiableMemoryPersistence)
+	assert.True(t, ok)
+	assert.Equal(t, reflect.TypeOf(Beacon{}), memory.Prototype)
+
+	item, err := memory.Create("", Beacon{Id: "1", Name: "Beacon 1"})
+	assert.Nil(t, err)
+	assert.Equal(t, "Beacon 1", item.(Beacon).Name)
+
+	// Unregistered names are not created
+	locator = refer.NewDescriptor("pip-services", "persistence", "file", "settings", "1.0")
+	assert.Nil(t, factory.CanCreate(locator))
+	component, err = factory.Create(locator)
+	assert.NotNil(t, err)
+	assert.Nil(t, component)
+
+	// The map name stores items as maps
+	locator = refer.NewDescriptor("pip-services", "persistence", "file", build.MapPrototypeName, "1.0")
+	assert.NotNil(t, factory.CanCreate(locator))
+	component, err = factory.Create(locator)
+	assert.Nil(t, err)
+	file, ok := component.(*cpersist.IdentifiableFilePersistence)
+	assert.True(t, ok)
+	assert.Equal(t, reflect.TypeOf(map[string]interface{}{}), file.Prototype)
+
+	filename := "../../data/settings_factory.json"
+	defer os.Remove(filename)
+	file.Configure(cconf.NewConfigParamsFromTuples("path", filename))
+	assert.Nil(t, file.Open(""))
+	_, err = file.Create("", map[string]interface{}{"id": "1", "value": 123})
+	assert.Nil(t, err)
+	assert.Nil(t, file.Close(""))
+	_, err = os.Stat(filename)
+	assert.Nil(t, err)
+
+	locator = refer.NewDescriptor("pip-services", "persistence", "mongodb", "beacon", "1.0")
+	assert.Nil(t, factory.CanCreate(locator))
+}
@@ -0,0 +1,111 @@
+package test_persistence
+
+import (
+	"os"
+	"testing"
+
+	cdata "github.com/pip-services3-go/pip-services3-commons-go/data"
+	"github.com/pip-services3-go/pip-services3-commons-go/errors"
+	cpersist "github.com/pip-services3-go/pip-services3-data-go/persistence"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDummyBatchWrites(t *testing.T) {
+	persistence := NewDummyMemoryPersistence()
+
+	results, err := persistence.CreateMany("", []interface{}{
+		Dummy{Id: "1", Key: "Key 1", Content: "Content 1"},
+		Dummy{Id: "2", Key: "Key 2", Content: "Content 2"},
+		Dummy{Id: "1", Key: "Key 3", Content: "Content 3"},
+		Dummy{Key: "Key 4", Content: "Content 4"},
+	})
+	assert.Nil(t, err)
+	assert.Len(t, results, 4)
+	assert.Nil(t, results[0].Err)
+	assert.Equal(t, "1", results[0].Item.(Dummy).Id)
+	assert.Nil(t, results[1].Err)
+	assert.NotNil(t, results[2].Err)
+	assert.Equal(t, errors.Conflict, results[2].Err.(*errors.ApplicationError).Category)
+	assert.Nil(t, results[2].Item)
+	assert.NotEmpty(t, results[3].Item.(Dummy).Id)
+	assert.Len(t, persistence.Items, 3)
+
+	results, err = persistence.UpdateMany("", []interface{}{
+		Dummy{Id: "1", Key: "Key 1", Content: "Updated 1"},
+		Dummy{Id: "5", Key: "Key 5", Content: "Content 5"},
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, results[0].Err)
+	assert.Equal(t, "Updated 1", results[0].Item.(Dummy).Content)
+	assert.Equal(t, errors.NotFound, results[1].Err.(*errors.ApplicationError).Category)
+
+	results, err = persistence.SetMany("", []interface{}{
+		Dummy{Id: "2", Key: "Key 2", Content: "Updated 2"},
+		Dummy{Id: "5", Key: "Key 5", Content: "Content 5"},
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, results[0].Err)
+	assert.Nil(t, results[1].Err)
+	assert.Len(t, persistence.Items, 4)
+
+	item, _ := persistence.GetOneById("", "2")
+	assert.Equal(t, "Updated 2", item.Content)
+
+	count, err := persistence.UpdatePartiallyByFilter("", func(item interface{}) bool {
+		return item.(Dummy).Key != "Key 5"
+	}, cdata.NewAnyValueMapFromTuples("content", "Bulk"))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, count)
+
+	item, _ = persistence.GetOneById("", "1")
+	assert.Equal(t, "Bulk", item.Content)
+	item, _ = persistence.GetOneById("", "5")
+	assert.Equal(t, "Content 5", item.Content)
+}
+
+func TestDummyBatchSavesOnce(t *testing.T) {
+	filename := "../../data/dummies_batch.json"
+	defer os.Remove(filename)
+
+	persistence := NewDummyFilePersistence(filename)
+	saver := &countingSaver{saver: persistence.Saver}
+	persistence.Saver = saver
+	persistence.Open("")
+	defer persistence.Close("")
+
+	items := make([]interface{}, 100)
+	for i := range items {
+		items[i] = Dummy{Key: "Key", Content: "Content"}
+	}
+	results, err := persistence.CreateMany("", items)
+	assert.Nil(t, err)
+	assert.Len(t, results, 100)
+	assert.Equal(t, 1, saver.saves)
+
+	for i := range items {
+		items[i] = results[i].Item
+	}
+	_, err = persistence.UpdateMany("", items)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, saver.saves)
+
+	_, err = persistence.SetMany("", items)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, saver.saves)
+
+	loaded := NewDummyFilePersistence(filename)
+	loaded.Open("")
+	count, _ := loaded.GetCountByFilter("", nil)
+	assert.Equal(t, int64(100), count)
+}
+
+// Counts calls to a wrapped saver
+type countingSaver struct {
+	saver cpersist.ISaver
+	saves int
+}
+
+func (c *countingSaver) Save(correlationId string, items []interface{}) error {
+	c.saves++
+	return c.saver.Save(correlationId, items)
+}
@@ -0,0 +1,32 @@
+package test_persistence
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pip-services3-go/pip-services3-commons-go/config"
+	cpersist "github.com/pip-services3-go/pip-services3-data-go/persistence"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDummyCloneStrategy(t *testing.T) {
+	persistence := cpersist.NewIdentifiableMemoryPersistence(reflect.TypeOf(map[string]interface{}{}))
+	assert.Equal(t, cpersist.CloneDeep, persistence.CloneStrategy)
+
+	// Nested values of returned items are not shared with stored items
+	item, err := persistence.Create("", map[string]interface{}{"id": "1", "tags": map[string]interface{}{"a": "1"}})
+	assert.Nil(t, err)
+	item.(map[string]interface{})["tags"].(map[string]interface{})["a"] = "2"
+	item, _ = persistence.GetOneById("", "1")
+	assert.Equal(t, "1", item.(map[string]interface{})["tags"].(map[string]interface{})["a"])
+
+	// Trusted items are returned as they are stored
+	persistence = cpersist.NewIdentifiableMemoryPersistence(reflect.TypeOf(map[string]interface{}{}))
+	persistence.Configure(config.NewConfigParamsFromTuples("options.clone_strategy", "none"))
+	assert.Equal(t, cpersist.CloneNone, persistence.CloneStrategy)
+	persistence.Create("", map[string]interface{}{"id": "1", "key": "Key 1"})
+	item, _ = persistence.GetOneById("", "1")
+	item.(map[string]interface{})["key"] = "Key 2"
+	item, _ = persistence.GetOneById("", "1")
+	assert.Equal(t, "Key 2", item.(map[string]interface{})["key"])
+}
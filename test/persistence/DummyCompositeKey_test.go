@@ -0,0 +1,136 @@
+package test_persistence
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pip-services3-go/pip-services3-commons-go/config"
+	cdata "github.com/pip-services3-go/pip-services3-commons-go/data"
+	cpersist "github.com/pip-services3-go/pip-services3-data-go/persistence"
+	"github.com/stretchr/testify/assert"
+)
+
+type Membership struct {
+	TenantId string `json:"tenant_id" persist:"id"`
+	Name     string `json:"name" persist:"id"`
+	Role     string `json:"role"`
+}
+
+type CodedDummy struct {
+	Code    string `json:"code"`
+	Content string `json:"content"`
+}
+
+func TestDummyCompositeKeys(t *testing.T) {
+	persistence := cpersist.NewIdentifiableMemoryPersistence(reflect.TypeOf(Membership{}))
+	persistence.Create("", Membership{TenantId: "t1", Name: "admin", Role: "owner"})
+	persistence.Create("", Membership{TenantId: "t1", Name: "user", Role: "reader"})
+	persistence.Create("", Membership{TenantId: "t2", Name: "admin", Role: "writer"})
+	assert.Equal(t, 3, persistence.GetItemCount())
+
+	// Items with the same key replace each other
+	persistence.Set("", Membership{TenantId: "t2", Name: "admin", Role: "owner"})
+	assert.Equal(t, 3, persistence.GetItemCount())
+
+	// Keys are accepted as composite keys, key structs and tuples
+	item, err := persistence.GetOneById("", cpersist.NewCompositeKey("t1", "user"))
+	assert.Nil(t, err)
+	assert.Equal(t, "reader", item.(Membership).Role)
+	item, _ = persistence.GetOneById("", Membership{TenantId: "t2", Name: "admin"})
+	assert.Equal(t, "owner", item.(Membership).Role)
+	item, _ = persistence.GetOneById("", []interface{}{"t1", "admin"})
+	assert.Equal(t, "owner", item.(Membership).Role)
+	item, _ = persistence.GetOneById("", cpersist.NewCompositeKey("t3", "admin"))
+	assert.Nil(t, item)
+
+	items, _ := persistence.GetListByIds("", []interface{}{
+		cpersist.NewCompositeKey("t1", "admin"),
+		[]string{"t2", "admin"},
+	})
+	assert.Len(t, items, 2)
+
+	item, _ = persistence.UpdatePartially("", cpersist.NewCompositeKey("t1", "user"),
+		cdata.NewAnyValueMapFromTuples("role", "writer"))
+	assert.Equal(t, "writer", item.(Membership).Role)
+
+	item, _ = persistence.DeleteById("", []interface{}{"t1", "admin"})
+	assert.Equal(t, "t1", item.(Membership).TenantId)
+	assert.Equal(t, 2, persistence.GetItemCount())
+
+	assert.Nil(t, persistence.DeleteByIds("", []interface{}{cpersist.NewCompositeKey("t1", "user")}))
+	assert.Equal(t, 1, persistence.GetItemCount())
+}
+
+func TestDummyIdFieldOption(t *testing.T) {
+	persistence := cpersist.NewIdentifiableMemoryPersistence(reflect.TypeOf(CodedDummy{}))
+	persistence.Configure(config.NewConfigParamsFromTuples("options.id_field", "Code"))
+
+	item, err := persistence.Create("", CodedDummy{Content: "Content 1"})
+	assert.Nil(t, err)
+	code := item.(CodedDummy).Code
+	assert.NotEqual(t, "", code)
+
+	persistence.Create("", CodedDummy{Code: "A", Content: "Content A"})
+	item, _ = persistence.GetOneById("", code)
+	assert.Equal(t, "Content 1", item.(CodedDummy).Content)
+
+	item, _ = persistence.Update("", CodedDummy{Code: "A", Content: "Content B"})
+	assert.Equal(t, "Content B", item.(CodedDummy).Content)
+	item, _ = persistence.DeleteById("", "A")
+	assert.Equal(t, "A", item.(CodedDummy).Code)
+	assert.Equal(t, 1, persistence.GetItemCount())
+
+	// Map items use the configured key as well
+	maps := cpersist.NewIdentifiableMemoryPersistence(reflect.TypeOf(map[string]interface{}{}))
+	maps.Configure(config.NewConfigParamsFromTuples("options.id_field", "code"))
+	maps.Create("", map[string]interface{}{"code": "X", "content": "Content X"})
+	item, _ = maps.GetOneById("", "X")
+	assert.Equal(t, "Content X", item.(map[string]interface{})["content"])
+}
+
+func TestShardedCompositeKeys(t *testing.T) {
+	persistence := cpersist.NewIdentifiableMemoryPersistence(reflect.TypeOf(Membership{}))
+	persistence.Configure(config.NewConfigParamsFromTuples("options.shards", 4))
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		persistence.Create("", Membership{TenantId: "t1", Name: name, Role: "role " + name})
+	}
+
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		item, _ := persistence.GetOneById("", Membership{TenantId: "t1", Name: name})
+		assert.Equal(t, "role "+name, item.(Membership).Role)
+	}
+	item, _ := persistence.DeleteById("", cpersist.NewCompositeKey("t1", "c"))
+	assert.Equal(t, "c", item.(Membership).Name)
+	item, _ = persistence.GetOneById("", []interface{}{"t1", "c"})
+	assert.Nil(t, item)
+}
+
+type TaggedDummy struct {
+	Code    string `json:"code" bson:"_id"`
+	Content string `json:"content" bson:"text"`
+}
+
+func TestDummyPropertyTagOption(t *testing.T) {
+	persistence := cpersist.NewIdentifiableMemoryPersistence(reflect.TypeOf(TaggedDummy{}))
+	persistence.Configure(config.NewConfigParamsFromTuples(
+		"options.property_tag", "bson",
+		"options.id_field", "_id",
+	))
+	persistence.Create("", TaggedDummy{Code: "A", Content: "Content A"})
+	persistence.Create("", TaggedDummy{Code: "B", Content: "Content B"})
+
+	item, err := persistence.GetOneById("", "B")
+	assert.Nil(t, err)
+	assert.Equal(t, "Content B", item.(TaggedDummy).Content)
+
+	groups, err := persistence.Aggregate("", nil, []string{"text"}, []cpersist.Aggregation{
+		cpersist.NewAggregation("count", cpersist.AggregateCount, ""),
+	})
+	assert.Nil(t, err)
+	assert.Len(t, groups, 2)
+	assert.Equal(t, "Content A", groups[0].Keys["text"])
+
+	// Property lookups outside of the persistence still use json tags only
+	assert.Nil(t, cpersist.GetProperty(item, "text"))
+	assert.Equal(t, "Content B", cpersist.GetProperty(item, "content"))
+}
@@ -0,0 +1,79 @@
+package test_persistence
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	cpersist "github.com/pip-services3-go/pip-services3-data-go/persistence"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDummyContext(t *testing.T) {
+	ctx := cpersist.ContextWithCorrelationId(context.Background(), "123")
+	assert.Equal(t, "123", cpersist.CorrelationIdFromContext(ctx))
+	assert.Equal(t, "", cpersist.CorrelationIdFromContext(context.Background()))
+
+	persistence := NewDummyMemoryPersistence()
+	var _ cpersist.IWriterWithContext = persistence
+	var _ cpersist.IGetterWithContext = persistence
+	var _ cpersist.ISetterWithContext = persistence
+	var _ cpersist.IPartialUpdaterWithContext = persistence
+
+	item, err := persistence.CreateWithContext(ctx, Dummy{Id: "1", Key: "Key 1", Content: "Content 1"})
+	assert.Nil(t, err)
+	assert.Equal(t, "1", item.(Dummy).Id)
+
+	item, err = persistence.GetOneByIdWithContext(ctx, "1")
+	assert.Nil(t, err)
+	assert.Equal(t, "Key 1", item.(Dummy).Key)
+
+	// Cancelled context stops operations
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	_, err = persistence.CreateWithContext(cancelled, Dummy{Id: "2", Key: "Key 2"})
+	assert.Equal(t, context.Canceled, err)
+	_, err = persistence.GetPageByFilterWithContext(cancelled, nil, nil, nil, nil)
+	assert.Equal(t, context.Canceled, err)
+	err = persistence.DeleteByIdsWithContext(cancelled, []interface{}{"1"})
+	assert.Equal(t, context.Canceled, err)
+	err = persistence.ForEachByFilterWithContext(cancelled, nil, func(item interface{}) bool { return true })
+	assert.Equal(t, context.Canceled, err)
+	_, err = persistence.CreateManyWithContext(cancelled, []interface{}{Dummy{Id: "3", Key: "Key 3"}})
+	assert.Equal(t, context.Canceled, err)
+	_, err = persistence.GetPageByKeysetWithContext(cancelled, nil, nil, "key", false, nil)
+	assert.Equal(t, context.Canceled, err)
+	_, err = persistence.AggregateWithContext(cancelled, nil, nil,
+		[]cpersist.Aggregation{cpersist.NewAggregation("count", cpersist.AggregateCount, "")})
+	assert.Equal(t, context.Canceled, err)
+	_, err = persistence.GetFacetsWithContext(cancelled, []string{"key"}, nil)
+	assert.Equal(t, context.Canceled, err)
+	_, err = persistence.GetHistoryByIdWithContext(cancelled, "1")
+	assert.Equal(t, context.Canceled, err)
+	_, err = persistence.GetOneByIdWithIncludesWithContext(cancelled, "1", nil)
+	assert.Equal(t, context.Canceled, err)
+
+	count, err := persistence.GetCountByFilterWithContext(ctx, func(item interface{}) bool { return true })
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// Cancelled context prevents saving to file
+	path := "../../data/dummies_context.json"
+	persister := cpersist.NewJsonFilePersister(reflect.TypeOf(Dummy{}), path)
+	var _ cpersist.ISaverWithContext = persister
+	var _ cpersist.ILoaderWithContext = persister
+
+	err = persister.SaveWithContext(cancelled, []interface{}{Dummy{Id: "1"}})
+	assert.Equal(t, context.Canceled, err)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	err = persister.SaveWithContext(ctx, []interface{}{Dummy{Id: "1"}})
+	assert.Nil(t, err)
+	items, err := persister.LoadWithContext(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, items, 1)
+	os.Remove(path)
+}
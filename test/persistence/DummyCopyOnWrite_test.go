@@ -0,0 +1,150 @@
+package test_persistence
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	cconf "github.com/pip-services3-go/pip-services3-commons-go/config"
+	cdata "github.com/pip-services3-go/pip-services3-commons-go/data"
+	cpersist "github.com/pip-services3-go/pip-services3-data-go/persistence"
+	"github.com/stretchr/testify/assert"
+)
+
+func newCopyOnWriteDummyPersistence() *cpersist.IdentifiableMemoryPersistence {
+	persistence := cpersist.NewIdentifiableMemoryPersistence(reflect.TypeOf(Dummy{}))
+	persistence.Configure(cconf.NewConfigParamsFromTuples("options.copy_on_write", true))
+	return persistence
+}
+
+func TestDummyCopyOnWriteMemoryPersistence(t *testing.T) {
+	persister := NewDummyMemoryPersistence()
+	persister.Configure(cconf.NewConfigParamsFromTuples("options.copy_on_write", true))
+
+	fixture := NewDummyPersistenceFixture(persister)
+
+	t.Run("DummyCopyOnWriteMemoryPersistence:CRUD", fixture.TestCrudOperations)
+	t.Run("DummyCopyOnWriteMemoryPersistence:Batch", fixture.TestBatchOperations)
+	t.Run("DummyCopyOnWriteMemoryPersistence:TestFiltersOperations", fixture.TestFiltersOperations)
+}
+
+func TestDummyCopyOnWrite(t *testing.T) {
+	persistence := newCopyOnWriteDummyPersistence()
+	assert.True(t, persistence.IsCopyOnWrite())
+
+	for i := 0; i < 10; i++ {
+		id := strconv.Itoa(i)
+		_, err := persistence.Create("", Dummy{Id: id, Key: "Key " + id, Content: "Content " + id})
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 10, persistence.GetItemCount())
+
+	// Reads do not wait for a writer that holds the lock
+	persistence.Lock.Lock()
+	item, err := persistence.GetOneById("", "3")
+	assert.Nil(t, err)
+	assert.Equal(t, "Key 3", item.(Dummy).Key)
+	page, err := persistence.GetPageByFilter("", nil, cdata.NewPagingParams(2, 3, true), sortDummiesByKey, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10), *page.Total)
+	assert.Equal(t, "2", page.Data[0].(Dummy).Id)
+	persistence.Lock.Unlock()
+
+	// Writes are seen by reads after the writer releases the lock
+	persistence.UpdatePartially("", "1", cdata.NewAnyValueMapFromTuples("content", "Updated"))
+	item, _ = persistence.GetOneById("", "1")
+	assert.Equal(t, "Updated", item.(Dummy).Content)
+	persistence.DeleteByIds("", []interface{}{"1", "2"})
+	count, _ := persistence.GetCountByFilter("", func(item interface{}) bool { return true })
+	assert.Equal(t, int64(8), count)
+
+	// Shards are ignored in copy-on-write mode
+	persistence.Configure(cconf.NewConfigParamsFromTuples(
+		"options.copy_on_write", true,
+		"options.shards", 4,
+	))
+	assert.Equal(t, 1, persistence.ShardCount())
+
+	// Switching to default mode keeps items
+	persistence.Configure(cconf.NewConfigParamsFromTuples("options.copy_on_write", false))
+	assert.False(t, persistence.IsCopyOnWrite())
+	assert.Len(t, persistence.Items, 8)
+	persistence.Clear("")
+	assert.Equal(t, 0, persistence.GetItemCount())
+}
+
+func TestDummyCopyOnWriteEviction(t *testing.T) {
+	persistence := cpersist.NewIdentifiableMemoryPersistence(reflect.TypeOf(Dummy{}))
+	persistence.Configure(cconf.NewConfigParamsFromTuples(
+		"options.copy_on_write", true,
+		"options.max_items", 5,
+	))
+
+	for i := 0; i < 8; i++ {
+		persistence.Create("", Dummy{Id: strconv.Itoa(i), Key: "Key " + strconv.Itoa(i)})
+	}
+	assert.Equal(t, 5, persistence.GetItemCount())
+	item, _ := persistence.GetOneById("", "2")
+	assert.Nil(t, item)
+	item, _ = persistence.GetOneById("", "7")
+	assert.NotNil(t, item)
+}
+
+func TestDummyCopyOnWriteConcurrent(t *testing.T) {
+	persistence := newCopyOnWriteDummyPersistence()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				id := strconv.Itoa(w*100 + i)
+				persistence.Create("", Dummy{Id: id, Key: "Key " + id})
+				persistence.GetOneById("", id)
+				persistence.GetCountByFilter("", func(item interface{}) bool { return true })
+				if i%5 == 0 {
+					persistence.DeleteById("", id)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 320, persistence.GetItemCount())
+}
+
+// Runs a read-heavy concurrent workload with 1 update per 32 full scans
+func benchmarkReadHeavyLoad(b *testing.B, persistence *cpersist.IdentifiableMemoryPersistence) {
+	for i := 0; i < 1000; i++ {
+		persistence.Create("", Dummy{Id: strconv.Itoa(i), Key: "Key " + strconv.Itoa(i)})
+	}
+	var counter int64 = 1000
+	filter := func(item interface{}) bool { return item.(Dummy).Key == "Key 1" }
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for n := 0; pb.Next(); n++ {
+			if n%32 == 0 {
+				id := strconv.FormatInt(atomic.AddInt64(&counter, 1), 10)
+				persistence.Update("", Dummy{Id: strconv.Itoa(n % 1000), Key: "Key " + id})
+			} else {
+				persistence.GetPageByFilter("", filter, nil, nil, nil)
+			}
+		}
+	})
+}
+
+func BenchmarkMemoryPersistenceReadHeavy(b *testing.B) {
+	benchmarkReadHeavyLoad(b, newShardedDummyPersistence(0))
+}
+
+func BenchmarkCopyOnWriteMemoryPersistenceReadHeavy(b *testing.B) {
+	benchmarkReadHeavyLoad(b, newCopyOnWriteDummyPersistence())
+}
+
+func BenchmarkCopyOnWriteMemoryPersistenceConcurrent(b *testing.B) {
+	benchmarkConcurrentLoad(b, newCopyOnWriteDummyPersistence())
+}
@@ -0,0 +1,77 @@
+package test_persistence
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	cdata "github.com/pip-services3-go/pip-services3-commons-go/data"
+	"github.com/pip-services3-go/pip-services3-commons-go/refer"
+	"github.com/pip-services3-go/pip-services3-components-go/count"
+	"github.com/stretchr/testify/assert"
+)
+
+type testCounters struct {
+	lock    sync.Mutex
+	values  map[string]float32
+	timings map[string]int
+}
+
+func newTestCounters() *testCounters {
+	return &testCounters{values: map[string]float32{}, timings: map[string]int{}}
+}
+
+func (c *testCounters) BeginTiming(name string) *count.CounterTiming {
+	return count.NewCounterTiming(name, c)
+}
+
+func (c *testCounters) EndTiming(name string, elapsed float32) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.timings[name]++
+}
+
+func (c *testCounters) Stats(name string, value float32) {}
+
+func (c *testCounters) Last(name string, value float32) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.values[name] = value
+}
+
+func (c *testCounters) TimestampNow(name string) {}
+
+func (c *testCounters) Timestamp(name string, value time.Time) {}
+
+func (c *testCounters) IncrementOne(name string) {
+	c.Increment(name, 1)
+}
+
+func (c *testCounters) Increment(name string, value int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.values[name] += float32(value)
+}
+
+func TestDummyCounters(t *testing.T) {
+	counters := newTestCounters()
+	persistence := NewDummyMemoryPersistence()
+	persistence.SetReferences(refer.NewReferencesFromTuples(
+		refer.NewDescriptor("pip-services", "counters", "test", "default", "1.0"), counters,
+	))
+	assert.Equal(t, "persistence.dummy", persistence.ComponentName)
+
+	persistence.Create("", Dummy{Id: "1", Key: "Key 1", Content: "Content 1"})
+	persistence.Create("", Dummy{Id: "2", Key: "Key 2", Content: "Content 2"})
+	persistence.GetOneById("", "1")
+	persistence.GetPageByFilter("", cdata.NewEmptyFilterParams(), cdata.NewEmptyPagingParams())
+	persistence.DeleteById("", "2")
+
+	assert.Equal(t, float32(2), counters.values["persistence.dummy.create.calls"])
+	assert.Equal(t, 2, counters.timings["persistence.dummy.create.exec_time"])
+	assert.Equal(t, float32(1), counters.values["persistence.dummy.get_one_by_id.calls"])
+	assert.Equal(t, float32(1), counters.values["persistence.dummy.delete_by_id.calls"])
+	assert.Equal(t, float32(2), counters.values["persistence.dummy.get_page_by_filter.result_size"])
+	assert.Equal(t, float32(1), counters.values["persistence.dummy.items"])
+	assert.Equal(t, float32(0), counters.values["persistence.dummy.create.errors"])
+}
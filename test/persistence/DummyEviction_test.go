@@ -0,0 +1,97 @@
+package test_persistence
+
+import (
+	"testing"
+
+	cconf "github.com/pip-services3-go/pip-services3-commons-go/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func newEvictingDummyPersistence(policy string) (*DummyMemoryPersistence, *[]Dummy) {
+	persistence := NewDummyMemoryPersistence()
+	persistence.Configure(cconf.NewConfigParamsFromTuples(
+		"options.max_items", 2,
+		"options.eviction_policy", policy,
+	))
+
+	evicted := make([]Dummy, 0)
+	persistence.OnEvicted = func(correlationId string, item interface{}) {
+		evicted = append(evicted, item.(Dummy))
+	}
+	return persistence, &evicted
+}
+
+func TestDummyEvictionFifo(t *testing.T) {
+	persistence, evicted := newEvictingDummyPersistence("fifo")
+
+	persistence.Create("", Dummy{Id: "1", Key: "Key 1"})
+	persistence.Create("", Dummy{Id: "2", Key: "Key 2"})
+	persistence.GetOneById("", "1")
+	persistence.Create("", Dummy{Id: "3", Key: "Key 3"})
+
+	assert.Len(t, persistence.Items, 2)
+	assert.Len(t, *evicted, 1)
+	assert.Equal(t, "1", (*evicted)[0].Id)
+}
+
+func TestDummyEvictionLru(t *testing.T) {
+	persistence, evicted := newEvictingDummyPersistence("lru")
+
+	persistence.Create("", Dummy{Id: "1", Key: "Key 1"})
+	persistence.Create("", Dummy{Id: "2", Key: "Key 2"})
+	persistence.GetOneById("", "1")
+	persistence.Create("", Dummy{Id: "3", Key: "Key 3"})
+
+	assert.Len(t, persistence.Items, 2)
+	assert.Len(t, *evicted, 1)
+	assert.Equal(t, "2", (*evicted)[0].Id)
+
+	item, err := persistence.GetOneById("", "1")
+	assert.Nil(t, err)
+	assert.Equal(t, "1", item.Id)
+}
+
+func TestDummyEvictionLfu(t *testing.T) {
+	persistence, evicted := newEvictingDummyPersistence("lfu")
+
+	persistence.Create("", Dummy{Id: "1", Key: "Key 1"})
+	persistence.Create("", Dummy{Id: "2", Key: "Key 2"})
+	persistence.GetOneById("", "1")
+	persistence.GetOneById("", "1")
+	persistence.GetOneById("", "2")
+	persistence.Create("", Dummy{Id: "3", Key: "Key 3"})
+
+	assert.Len(t, *evicted, 1)
+	assert.Equal(t, "2", (*evicted)[0].Id)
+
+	// The new item has the lowest number of hits
+	persistence.Create("", Dummy{Id: "4", Key: "Key 4"})
+
+	assert.Len(t, *evicted, 2)
+	assert.Equal(t, "3", (*evicted)[1].Id)
+}
+
+func TestDummyEvictionLfuMany(t *testing.T) {
+	persistence, evicted := newEvictingDummyPersistence("lfu")
+
+	persistence.Create("", Dummy{Id: "1", Key: "Key 1"})
+	persistence.Create("", Dummy{Id: "2", Key: "Key 2"})
+	persistence.GetOneById("", "2")
+	persistence.GetOneById("", "2")
+
+	_, err := persistence.IdentifiableMemoryPersistence.CreateMany("", []interface{}{
+		Dummy{Id: "3", Key: "Key 3"},
+		Dummy{Id: "4", Key: "Key 4"},
+	})
+	assert.Nil(t, err)
+
+	// Items with equal hits are evicted from the least recently accessed
+	assert.Len(t, persistence.Items, 2)
+	assert.Len(t, *evicted, 2)
+	assert.Equal(t, "1", (*evicted)[0].Id)
+	assert.Equal(t, "3", (*evicted)[1].Id)
+
+	item, err := persistence.GetOneById("", "2")
+	assert.Nil(t, err)
+	assert.Equal(t, "2", item.Id)
+}
@@ -0,0 +1,42 @@
+package test_persistence
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDummyFacets(t *testing.T) {
+	persistence := NewDummyMemoryPersistence()
+	persistence.Create("", Dummy{Id: "1", Key: "B", Content: "Red"})
+	persistence.Create("", Dummy{Id: "2", Key: "A", Content: "Blue"})
+	persistence.Create("", Dummy{Id: "3", Key: "B", Content: "Red"})
+	persistence.Create("", Dummy{Id: "4", Key: "C", Content: "Blue"})
+	persistence.Create("", Dummy{Id: "5", Key: "B", Content: "Green"})
+
+	values, err := persistence.GetDistinctValues("", "key", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"A", "B", "C"}, values)
+
+	values, err = persistence.GetDistinctValues("", "content", func(item interface{}) bool {
+		return item.(Dummy).Key == "B"
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"Green", "Red"}, values)
+
+	facets, err := persistence.GetFacets("", []string{"Key", "Content"}, nil)
+	assert.Nil(t, err)
+	assert.Len(t, facets, 2)
+
+	assert.Len(t, facets["Key"], 3)
+	assert.Equal(t, "B", facets["Key"][0].Value)
+	assert.Equal(t, int64(3), facets["Key"][0].Count)
+	assert.Equal(t, "A", facets["Key"][1].Value)
+	assert.Equal(t, int64(1), facets["Key"][1].Count)
+
+	assert.Len(t, facets["Content"], 3)
+	assert.Equal(t, "Blue", facets["Content"][0].Value)
+	assert.Equal(t, int64(2), facets["Content"][0].Count)
+	assert.Equal(t, "Red", facets["Content"][1].Value)
+	assert.Equal(t, "Green", facets["Content"][2].Value)
+}
@@ -0,0 +1,145 @@
+package test_persistence
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	cconf "github.com/pip-services3-go/pip-services3-commons-go/config"
+	cpersist "github.com/pip-services3-go/pip-services3-data-go/persistence"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDummyFileStatus(t *testing.T) {
+	filename := "../../data/dummies_status.json"
+	os.Remove(filename)
+	defer os.Remove(filename)
+
+	persistence := cpersist.NewIdentifiableFilePersistence(reflect.TypeOf(Dummy{}), nil)
+	persistence.Configure(cconf.NewConfigParamsFromTuples("path", filename))
+
+	status := persistence.GetStatus("")
+	assert.False(t, status.Opened)
+	assert.NotNil(t, persistence.CheckHealth(""))
+
+	err := persistence.Open("")
+	assert.Nil(t, err)
+
+	status = persistence.GetStatus("")
+	assert.True(t, status.Opened)
+	assert.Equal(t, filename, status.Path)
+	assert.False(t, status.Exists)
+	assert.True(t, status.Writable)
+	assert.False(t, status.LastLoadTime.IsZero())
+	assert.True(t, status.LastSaveTime.IsZero())
+	assert.Nil(t, persistence.CheckHealth(""))
+
+	persistence.Create("", Dummy{Id: "1", Key: "Key 1", Content: "Content 1"})
+	persistence.Create("", Dummy{Id: "2", Key: "Key 2", Content: "Content 2"})
+
+	status = persistence.GetStatus("")
+	assert.True(t, status.Exists)
+	assert.True(t, status.Readable)
+	assert.True(t, status.Writable)
+	assert.True(t, status.FileSize > 0)
+	assert.Equal(t, 2, status.ItemCount)
+	assert.False(t, status.Dirty)
+	assert.False(t, status.LastSaveTime.IsZero())
+	assert.Equal(t, "", status.LastError)
+
+	// Failed save leaves the persistence dirty and unhealthy
+	persistence.Persister.SetPath("../../data/missing/dummies_status.json")
+	_, err = persistence.Create("", Dummy{Id: "3", Key: "Key 3", Content: "Content 3"})
+	assert.NotNil(t, err)
+
+	status = persistence.GetStatus("")
+	assert.True(t, status.Dirty)
+	assert.False(t, status.Writable)
+	assert.NotEqual(t, "", status.LastError)
+	assert.NotNil(t, persistence.CheckHealth(""))
+
+	persistence.Persister.SetPath(filename)
+	assert.Nil(t, persistence.Save(""))
+	assert.False(t, persistence.IsDirty())
+	assert.Nil(t, persistence.CheckHealth(""))
+	persistence.Close("")
+}
+
+func TestDummyTenantFileStatus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tenants")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	persistence := cpersist.NewIdentifiableFilePersistence(reflect.TypeOf(map[string]interface{}{}), nil)
+	persistence.Configure(cconf.NewConfigParamsFromTuples(
+		"path", filepath.Join(dir, "{tenant}", "dummies.json"),
+		"options.tenant_field", "tenant_id",
+	))
+	assert.Nil(t, persistence.Open(""))
+	assert.Nil(t, persistence.CheckHealth(""))
+
+	ctxA := cpersist.ContextWithTenantId(context.Background(), "a")
+	ctxB := cpersist.ContextWithTenantId(context.Background(), "b")
+	persistence.CreateWithContext(ctxA, map[string]interface{}{"id": "1", "key": "Key 1"})
+	persistence.CreateWithContext(ctxB, map[string]interface{}{"id": "2", "key": "Key 2"})
+	persistence.CreateWithContext(ctxB, map[string]interface{}{"id": "3", "key": "Key 3"})
+
+	// Status reports files of tenants instead of the path template
+	status := persistence.GetStatus("")
+	assert.Equal(t, filepath.Join(dir, "{tenant}", "dummies.json"), status.Path)
+	assert.True(t, status.Exists)
+	assert.True(t, status.Readable)
+	assert.True(t, status.Writable)
+	assert.Len(t, status.Tenants, 2)
+	assert.Equal(t, filepath.Join(dir, "b", "dummies.json"), status.Tenants["b"].Path)
+	assert.True(t, status.Tenants["b"].Exists)
+	assert.Equal(t, 1, status.Tenants["a"].ItemCount)
+	assert.Equal(t, 2, status.Tenants["b"].ItemCount)
+	assert.Equal(t, status.Tenants["a"].FileSize+status.Tenants["b"].FileSize, status.FileSize)
+	assert.False(t, status.LastSaveTime.IsZero())
+	assert.Nil(t, persistence.CheckHealth(""))
+
+	// A tenant file that cannot be written makes the persistence unhealthy
+	assert.Nil(t, os.Remove(filepath.Join(dir, "b", "dummies.json")))
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, "b", "dummies.json"), 0777))
+	status = persistence.GetStatus("")
+	assert.False(t, status.Tenants["b"].Writable)
+	assert.False(t, status.Writable)
+	err = persistence.CheckHealth("")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), filepath.Join(dir, "b", "dummies.json"))
+	persistence.Close("")
+}
+
+func TestDummyShardedTenantFileStatus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tenants")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	persistence := cpersist.NewIdentifiableFilePersistence(reflect.TypeOf(map[string]interface{}{}), nil)
+	persistence.Configure(cconf.NewConfigParamsFromTuples(
+		"path", filepath.Join(dir, "{tenant}", "dummies.json"),
+		"options.tenant_field", "tenant_id",
+		"options.shards", 4,
+	))
+	assert.Nil(t, persistence.Open(""))
+
+	ctxA := cpersist.ContextWithTenantId(context.Background(), "a")
+	ctxB := cpersist.ContextWithTenantId(context.Background(), "b")
+	for i := 0; i < 10; i++ {
+		id := strconv.Itoa(i)
+		persistence.CreateWithContext(ctxA, map[string]interface{}{"id": id, "key": "Key " + id})
+	}
+	persistence.CreateWithContext(ctxB, map[string]interface{}{"id": "1", "key": "Key 1"})
+
+	// Items of tenants are counted in all shards
+	status := persistence.GetStatus("")
+	assert.Equal(t, 11, status.ItemCount)
+	assert.Equal(t, 10, status.Tenants["a"].ItemCount)
+	assert.Equal(t, 1, status.Tenants["b"].ItemCount)
+	persistence.Close("")
+}
@@ -0,0 +1,71 @@
+package test_persistence
+
+import (
+	"testing"
+
+	cconf "github.com/pip-services3-go/pip-services3-commons-go/config"
+	cpersist "github.com/pip-services3-go/pip-services3-data-go/persistence"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDummyGeoSearch(t *testing.T) {
+	persistence := NewDummyMapMemoryPersistence()
+
+	_, err := persistence.GetListWithinRadius("", 0, 0, 1000, nil)
+	assert.NotNil(t, err)
+
+	persistence.Configure(cconf.NewConfigParamsFromTuples(
+		"options.latitude_field", "lat",
+		"options.longitude_field", "lon",
+	))
+
+	location := func(id string, lat float64, lon float64) map[string]interface{} {
+		return map[string]interface{}{"id": id, "lat": lat, "lon": lon}
+	}
+	persistence.Create("", location("paris", 48.8566, 2.3522))
+	persistence.Create("", location("versailles", 48.8049, 2.1204))
+	persistence.Create("", location("london", 51.5074, -0.1278))
+	persistence.Create("", location("fiji", -17.7134, 178.0650))
+	persistence.Create("", location("samoa", -13.7590, -172.1046))
+	persistence.Create("", map[string]interface{}{"id": "nowhere"})
+
+	ids := func(result []cpersist.GeoResult) []interface{} {
+		values := make([]interface{}, len(result))
+		for i, v := range result {
+			values[i] = cpersist.GetObjectId(v.Item)
+		}
+		return values
+	}
+
+	result, err := persistence.GetListWithinRadius("", 48.8566, 2.3522, 50000, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"paris", "versailles"}, ids(result))
+	assert.InDelta(t, 0, result[0].Distance, 1)
+	assert.InDelta(t, 17900, result[1].Distance, 100)
+
+	result, _ = persistence.GetListWithinRadius("", 50.0, 1.0, 400000, nil)
+	assert.Equal(t, []interface{}{"versailles", "paris", "london"}, ids(result))
+
+	result, _ = persistence.GetListWithinRadius("", 50.0, 1.0, 400000, func(item interface{}) bool {
+		return cpersist.GetObjectId(item) != "paris"
+	})
+	assert.Equal(t, []interface{}{"versailles", "london"}, ids(result))
+
+	result, err = persistence.GetListWithinBox("", 48, -1, 52, 3, nil)
+	assert.Nil(t, err)
+	assert.Len(t, result, 3)
+
+	// Box crossing the antimeridian
+	result, err = persistence.GetListWithinBox("", -20, 170, -10, -170, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"fiji", "samoa"}, ids(result))
+
+	// Index is updated on writes
+	persistence.DeleteById("", "paris")
+	persistence.Update("", location("london", 48.9, 2.4))
+	result, _ = persistence.GetListWithinRadius("", 48.8566, 2.3522, 50000, nil)
+	assert.Equal(t, []interface{}{"london", "versailles"}, ids(result))
+
+	_, err = persistence.GetListWithinBox("", 10, 0, -10, 0, nil)
+	assert.NotNil(t, err)
+}
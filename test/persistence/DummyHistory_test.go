@@ -0,0 +1,215 @@
+package test_persistence
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	cconf "github.com/pip-services3-go/pip-services3-commons-go/config"
+	cdata "github.com/pip-services3-go/pip-services3-commons-go/data"
+	cpersist "github.com/pip-services3-go/pip-services3-data-go/persistence"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDummyHistory(t *testing.T) {
+	persistence := NewDummyMemoryPersistence()
+	persistence.Configure(cconf.NewConfigParamsFromTuples(
+		"history.enabled", true,
+	))
+
+	persistence.Create("123", Dummy{Id: "1", Key: "Key 1", Content: "Content 1"})
+	created := time.Now().UTC()
+	time.Sleep(time.Millisecond)
+
+	persistence.Update("456", Dummy{Id: "1", Key: "Key 1", Content: "Content 2"})
+	persistence.UpdatePartially("789", "1", cdata.NewAnyValueMapFromTuples("content", "Content 3"))
+	updated := time.Now().UTC()
+	time.Sleep(time.Millisecond)
+
+	persistence.DeleteById("", "1")
+
+	history, err := persistence.GetHistoryById("", "1")
+	assert.Nil(t, err)
+	assert.Len(t, history, 4)
+	assert.Equal(t, cpersist.HistoryCreated, history[0].Operation)
+	assert.Equal(t, "123", history[0].CorrelationId)
+	assert.Equal(t, "Content 1", history[0].Item.(Dummy).Content)
+	assert.Equal(t, cpersist.HistoryUpdated, history[1].Operation)
+	assert.Equal(t, "456", history[1].CorrelationId)
+	assert.Equal(t, cpersist.HistoryDeleted, history[3].Operation)
+
+	item, err := persistence.GetAsOf("", "1", created)
+	assert.Nil(t, err)
+	assert.Equal(t, "Content 1", item.(Dummy).Content)
+
+	item, err = persistence.GetAsOf("", "1", updated)
+	assert.Nil(t, err)
+	assert.Equal(t, "Content 3", item.(Dummy).Content)
+
+	item, err = persistence.GetAsOf("", "1", time.Now().UTC())
+	assert.Nil(t, err)
+	assert.Nil(t, item)
+
+	item, err = persistence.GetAsOf("", "1", created.Add(-time.Hour))
+	assert.Nil(t, err)
+	assert.Nil(t, item)
+}
+
+func TestDummyHistoryMaxVersions(t *testing.T) {
+	persistence := NewDummyMemoryPersistence()
+	persistence.Configure(cconf.NewConfigParamsFromTuples(
+		"history.enabled", true,
+		"history.max_versions", 2,
+	))
+
+	persistence.Create("", Dummy{Id: "1", Key: "Key 1", Content: "Content 1"})
+	persistence.Update("", Dummy{Id: "1", Key: "Key 1", Content: "Content 2"})
+	persistence.Update("", Dummy{Id: "1", Key: "Key 1", Content: "Content 3"})
+
+	history, _ := persistence.GetHistoryById("", "1")
+	assert.Len(t, history, 2)
+	assert.Equal(t, "Content 2", history[0].Item.(Dummy).Content)
+}
+
+func TestDummyHistoryEvicted(t *testing.T) {
+	persistence := NewDummyMemoryPersistence()
+	persistence.Configure(cconf.NewConfigParamsFromTuples(
+		"history.enabled", true,
+		"options.max_items", 1,
+	))
+
+	persistence.Create("", Dummy{Id: "1", Key: "Key 1", Content: "Content 1"})
+	persistence.Create("", Dummy{Id: "2", Key: "Key 2", Content: "Content 2"})
+
+	history, err := persistence.GetHistoryById("", "1")
+	assert.Nil(t, err)
+	assert.Len(t, history, 2)
+	assert.Equal(t, cpersist.HistoryEvicted, history[1].Operation)
+	assert.Equal(t, "Content 1", history[1].Item.(Dummy).Content)
+
+	item, err := persistence.GetAsOf("", "1", time.Now().UTC())
+	assert.Nil(t, err)
+	assert.Nil(t, item)
+}
+
+func TestDummyHistoryClearRestore(t *testing.T) {
+	persistence := NewDummyMemoryPersistence()
+	persistence.Configure(cconf.NewConfigParamsFromTuples(
+		"history.enabled", true,
+	))
+
+	persistence.Create("", Dummy{Id: "1", Key: "Key 1", Content: "Content 1"})
+	persistence.Create("", Dummy{Id: "2", Key: "Key 2", Content: "Content 2"})
+	snapshot := persistence.Snapshot("")
+	persistence.Update("", Dummy{Id: "2", Key: "Key 2", Content: "Updated"})
+	persistence.Create("", Dummy{Id: "3", Key: "Key 3", Content: "Content 3"})
+
+	// Restore records changed, created and deleted items, unchanged items are skipped
+	assert.Nil(t, persistence.Restore("", snapshot))
+	history, _ := persistence.GetHistoryById("", "1")
+	assert.Len(t, history, 1)
+	history, _ = persistence.GetHistoryById("", "2")
+	assert.Len(t, history, 3)
+	assert.Equal(t, cpersist.HistoryUpdated, history[2].Operation)
+	assert.Equal(t, "Content 2", history[2].Item.(Dummy).Content)
+	history, _ = persistence.GetHistoryById("", "3")
+	assert.Len(t, history, 2)
+	assert.Equal(t, cpersist.HistoryDeleted, history[1].Operation)
+	item, _ := persistence.GetAsOf("", "3", time.Now().UTC())
+	assert.Nil(t, item)
+
+	// Clear records all items as deleted
+	assert.Nil(t, persistence.Clear(""))
+	history, _ = persistence.GetHistoryById("", "1")
+	assert.Len(t, history, 2)
+	assert.Equal(t, cpersist.HistoryDeleted, history[1].Operation)
+	item, _ = persistence.GetAsOf("", "2", time.Now().UTC())
+	assert.Nil(t, item)
+}
+
+type historyAppender struct {
+	appended [][]interface{}
+	fail     bool
+}
+
+func (c *historyAppender) Save(correlationId string, items []interface{}) error {
+	return nil
+}
+
+func (c *historyAppender) Append(correlationId string, items []interface{}) error {
+	if c.fail {
+		return errors.New("append failed")
+	}
+	c.appended = append(c.appended, items)
+	return nil
+}
+
+func TestDummyHistoryAppend(t *testing.T) {
+	persistence := NewDummyMemoryPersistence()
+	persistence.Configure(cconf.NewConfigParamsFromTuples(
+		"history.enabled", true,
+	))
+	appender := &historyAppender{}
+	persistence.HistorySaver = appender
+
+	// Only new versions are appended
+	persistence.Create("", Dummy{Id: "1", Key: "Key 1", Content: "Content 1"})
+	persistence.Create("", Dummy{Id: "2", Key: "Key 2", Content: "Content 2"})
+	assert.Len(t, appender.appended, 2)
+	assert.Len(t, appender.appended[1], 1)
+
+	// Versions that failed to append are appended with the next change
+	appender.fail = true
+	_, err := persistence.Update("", Dummy{Id: "1", Key: "Key 1", Content: "Content 11"})
+	assert.NotNil(t, err)
+	appender.fail = false
+	_, err = persistence.Update("", Dummy{Id: "2", Key: "Key 2", Content: "Content 22"})
+	assert.Nil(t, err)
+	assert.Len(t, appender.appended, 3)
+	assert.Len(t, appender.appended[2], 2)
+	assert.Equal(t, "Content 11", appender.appended[2][0].(*cpersist.HistoryEntry).Item.(Dummy).Content)
+}
+
+func TestDummyHistoryFile(t *testing.T) {
+	filename := "../../data/dummies_history_items.json"
+	historyFilename := "../../data/dummies_history.json"
+	defer os.Remove(filename)
+	defer os.Remove(historyFilename)
+
+	config := cconf.NewConfigParamsFromTuples(
+		"path", filename,
+		"history.enabled", true,
+		"history.path", historyFilename,
+	)
+
+	persistence := cpersist.NewIdentifiableFilePersistence(reflect.TypeOf(Dummy{}), nil)
+	persistence.Configure(config)
+	persistence.Open("")
+	persistence.Create("", Dummy{Id: "1", Key: "Key 1", Content: "Content 1"})
+	persistence.DeleteByIds("", []interface{}{"1"})
+	persistence.Close("")
+
+	persistence = cpersist.NewIdentifiableFilePersistence(reflect.TypeOf(Dummy{}), nil)
+	persistence.Configure(config)
+	err := persistence.Open("")
+	assert.Nil(t, err)
+	defer persistence.Close("")
+
+	history, err := persistence.GetHistoryById("", "1")
+	assert.Nil(t, err)
+	assert.Len(t, history, 2)
+	assert.Equal(t, cpersist.HistoryCreated, history[0].Operation)
+	assert.Equal(t, "Content 1", history[0].Item.(Dummy).Content)
+	assert.Equal(t, cpersist.HistoryDeleted, history[1].Operation)
+
+	// Versions are appended to the file as JSON lines
+	persistence.Create("", Dummy{Id: "2", Key: "Key 2", Content: "Content 2"})
+	persistence.Update("", Dummy{Id: "2", Key: "Key 2", Content: "Content 3"})
+	data, err := ioutil.ReadFile(historyFilename)
+	assert.Nil(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(data)), "\n"), 4)
+}
@@ -0,0 +1,76 @@
+package test_persistence
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pip-services3-go/pip-services3-commons-go/config"
+	"github.com/pip-services3-go/pip-services3-commons-go/errors"
+	cpersist "github.com/pip-services3-go/pip-services3-data-go/persistence"
+	"github.com/stretchr/testify/assert"
+)
+
+type NumberedDummy struct {
+	Id  int64  `json:"id"`
+	Key string `json:"key"`
+}
+
+func TestDummySequenceIds(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sequence")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	prototype := reflect.TypeOf(NumberedDummy{})
+	persister := cpersist.NewJsonFilePersister(prototype, filepath.Join(dir, "dummies.json"))
+	persistence := cpersist.NewIdentifiableMemoryPersistence(prototype)
+	persistence.Loader = persister
+	persistence.Saver = persister
+	assert.Nil(t, persistence.Open(""))
+
+	item, err := persistence.Create("", NumberedDummy{Key: "Key 1"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), item.(NumberedDummy).Id)
+	persistence.Create("", NumberedDummy{Id: 10, Key: "Key 10"})
+	item, _ = persistence.Create("", NumberedDummy{Key: "Key 11"})
+	assert.Equal(t, int64(11), item.(NumberedDummy).Id)
+	assert.Nil(t, persistence.Close(""))
+
+	// The sequence continues from stored ids
+	persistence = cpersist.NewIdentifiableMemoryPersistence(prototype)
+	persistence.Loader = persister
+	persistence.Saver = persister
+	assert.Nil(t, persistence.Open(""))
+	item, _ = persistence.Create("", NumberedDummy{Key: "Key 12"})
+	assert.Equal(t, int64(12), item.(NumberedDummy).Id)
+
+	// Found by string ids as well
+	item, _ = persistence.GetOneById("", "12")
+	assert.Equal(t, "Key 12", item.(NumberedDummy).Key)
+	items, _ := persistence.GetListByIds("", []interface{}{"1", "10", "13"})
+	assert.Len(t, items, 2)
+	items, _ = persistence.GetListByFilter("", func(item interface{}) bool {
+		return cpersist.ValueComparer.Less("9", item.(NumberedDummy).Id)
+	}, nil, nil)
+	assert.Len(t, items, 3)
+}
+
+func TestDummyIdGeneratorOptions(t *testing.T) {
+	persistence := cpersist.NewIdentifiableMemoryPersistence(reflect.TypeOf(Dummy{}))
+	persistence.Configure(config.NewConfigParamsFromTuples("options.id_generator", "uuid7"))
+	item1, _ := persistence.Create("", Dummy{Key: "Key 1"})
+	item2, _ := persistence.Create("", Dummy{Key: "Key 2"})
+	assert.Len(t, item1.(Dummy).Id, 36)
+	assert.True(t, item1.(Dummy).Id < item2.(Dummy).Id)
+
+	persistence.IdGenerator = cpersist.IdGeneratorFunc(func() interface{} { return "custom" })
+	item, _ := persistence.Create("", Dummy{Key: "Key 3"})
+	assert.Equal(t, "custom", item.(Dummy).Id)
+
+	// Items without ids are rejected
+	noIds := cpersist.NewIdentifiableMemoryPersistence(reflect.TypeOf(struct{ Key string }{}))
+	_, err := noIds.Create("", struct{ Key string }{Key: "Key 1"})
+	assert.Equal(t, "NO_ID_FIELD", err.(*errors.ApplicationError).Code)
+}
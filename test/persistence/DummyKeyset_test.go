@@ -0,0 +1,100 @@
+package test_persistence
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	cpersist "github.com/pip-services3-go/pip-services3-data-go/persistence"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDummyKeysetPaging(t *testing.T) {
+	persistence := NewDummyMemoryPersistence()
+	for i := 1; i <= 5; i++ {
+		persistence.Create("", Dummy{Id: strconv.Itoa(i), Key: "Key " + strconv.Itoa(6-i), Content: "Content"})
+	}
+
+	page, err := persistence.GetPageByKeyset("", nil, cpersist.NewKeysetPagingParams("", 2), "key", false, nil)
+	assert.Nil(t, err)
+	assert.Len(t, page.Data, 2)
+	assert.Equal(t, "5", page.Data[0].(Dummy).Id)
+	assert.Equal(t, "4", page.Data[1].(Dummy).Id)
+	assert.NotEmpty(t, page.Token)
+
+	// Items inserted before the position shall not shift the next page
+	persistence.Create("", Dummy{Id: "0", Key: "Key 0", Content: "Content"})
+
+	page, err = persistence.GetPageByKeyset("", nil, cpersist.NewKeysetPagingParams(page.Token, 2), "key", false, nil)
+	assert.Nil(t, err)
+	assert.Len(t, page.Data, 2)
+	assert.Equal(t, "3", page.Data[0].(Dummy).Id)
+	assert.Equal(t, "2", page.Data[1].(Dummy).Id)
+
+	// Removed items shall not shift the next page either
+	persistence.DeleteById("", "3")
+
+	page, err = persistence.GetPageByKeyset("", nil, cpersist.NewKeysetPagingParams(page.Token, 2), "key", false, nil)
+	assert.Nil(t, err)
+	assert.Len(t, page.Data, 1)
+	assert.Equal(t, "1", page.Data[0].(Dummy).Id)
+	assert.Empty(t, page.Token)
+
+	page, err = persistence.GetPageByKeyset("", nil, cpersist.NewKeysetPagingParams("", 3), "", true, nil)
+	assert.Nil(t, err)
+	assert.Len(t, page.Data, 3)
+	assert.Equal(t, "5", page.Data[0].(Dummy).Id)
+
+	_, err = persistence.GetPageByKeyset("", nil, cpersist.NewKeysetPagingParams("bad token", 3), "", false, nil)
+	assert.NotNil(t, err)
+
+	// Tokens taken in a different sort order are rejected
+	page, _ = persistence.GetPageByKeyset("", nil, cpersist.NewKeysetPagingParams("", 2), "key", false, nil)
+	_, err = persistence.GetPageByKeyset("", nil, cpersist.NewKeysetPagingParams(page.Token, 2), "content", false, nil)
+	assert.NotNil(t, err)
+	_, err = persistence.GetPageByKeyset("", nil, cpersist.NewKeysetPagingParams(page.Token, 2), "key", true, nil)
+	assert.NotNil(t, err)
+}
+
+type Reading struct {
+	Id   int64     `json:"id"`
+	Time time.Time `json:"time"`
+}
+
+func TestDummyKeysetTypedValues(t *testing.T) {
+	// Large integer ids and times keep their values in tokens
+	persistence := cpersist.NewIdentifiableMemoryPersistence(reflect.TypeOf(Reading{}))
+	start := time.Date(2020, 1, 1, 0, 0, 0, 1, time.UTC)
+	for i := int64(0); i < 4; i++ {
+		persistence.Create("", Reading{Id: int64(1)<<60 + i, Time: start})
+	}
+
+	ids := make([]int64, 0)
+	token := ""
+	for {
+		page, err := persistence.GetPageByKeyset("", nil, cpersist.NewKeysetPagingParams(token, 1), "time", false, nil)
+		assert.Nil(t, err)
+		for _, item := range page.Data {
+			ids = append(ids, item.(Reading).Id)
+		}
+		if token = page.Token; token == "" {
+			break
+		}
+	}
+	assert.Equal(t, []int64{1<<60 + 0, 1<<60 + 1, 1<<60 + 2, 1<<60 + 3}, ids)
+
+	// Composite keys are restored from tokens
+	memberships := cpersist.NewIdentifiableMemoryPersistence(reflect.TypeOf(Membership{}))
+	memberships.Create("", Membership{TenantId: "t1", Name: "admin"})
+	memberships.Create("", Membership{TenantId: "t1", Name: "user"})
+	memberships.Create("", Membership{TenantId: "t2", Name: "admin"})
+
+	page, err := memberships.GetPageByKeyset("", nil, cpersist.NewKeysetPagingParams("", 2), "", false, nil)
+	assert.Nil(t, err)
+	assert.Len(t, page.Data, 2)
+	page, err = memberships.GetPageByKeyset("", nil, cpersist.NewKeysetPagingParams(page.Token, 2), "", false, nil)
+	assert.Nil(t, err)
+	assert.Len(t, page.Data, 1)
+	assert.Equal(t, "t2", page.Data[0].(Membership).TenantId)
+}
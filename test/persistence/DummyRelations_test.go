@@ -0,0 +1,146 @@
+package test_persistence
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pip-services3-go/pip-services3-commons-go/config"
+	cdata "github.com/pip-services3-go/pip-services3-commons-go/data"
+	"github.com/pip-services3-go/pip-services3-commons-go/errors"
+	cpersist "github.com/pip-services3-go/pip-services3-data-go/persistence"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRelatedPersistences() (*DummyMemoryPersistence, *DummyMapMemoryPersistence) {
+	owners := NewDummyMemoryPersistence()
+	owners.Create("", Dummy{Id: "1", Key: "Key 1", Content: "Owner 1"})
+	owners.Create("", Dummy{Id: "2", Key: "Key 2", Content: "Owner 2"})
+
+	children := NewDummyMapMemoryPersistence()
+	children.Create("", map[string]interface{}{"Id": "11", "Key": "Key 11", "owner_id": "1"})
+	children.Create("", map[string]interface{}{"Id": "12", "Key": "Key 12", "owner_id": "1"})
+	children.Create("", map[string]interface{}{"Id": "21", "Key": "Key 21", "owner_id": "2"})
+	children.Create("", map[string]interface{}{"Id": "31", "Key": "Key 31", "owner_id": "3"})
+
+	return owners, children
+}
+
+func TestDummyRelationIncludes(t *testing.T) {
+	owners, children := newRelatedPersistences()
+
+	err := children.AddRelation(&cpersist.Relation{
+		Name: "owner", Kind: cpersist.RelationOne, Target: &owners.IdentifiableMemoryPersistence,
+		LocalField: "owner_id",
+	})
+	assert.Nil(t, err)
+	err = owners.AddRelation(&cpersist.Relation{
+		Name: "children", Kind: cpersist.RelationMany, Target: &children.IdentifiableMemoryPersistence,
+		ForeignField: "owner_id",
+	})
+	assert.Nil(t, err)
+
+	// Invalid relations are rejected
+	err = owners.AddRelation(&cpersist.Relation{
+		Name: "children", Kind: cpersist.RelationMany, Target: &children.IdentifiableMemoryPersistence,
+		ForeignField: "owner_id",
+	})
+	assert.NotNil(t, err)
+	err = owners.AddRelation(&cpersist.Relation{
+		Name: "other", Kind: cpersist.RelationOne, Target: &children.IdentifiableMemoryPersistence,
+	})
+	assert.NotNil(t, err)
+
+	owner, err := owners.GetOneByIdWithIncludes("", "1", []string{"children"})
+	assert.Nil(t, err)
+	assert.Equal(t, "1", owner.Item.(Dummy).Id)
+	assert.Len(t, owner.Includes["children"], 2)
+
+	owner, err = owners.GetOneByIdWithIncludes("", "5", []string{"children"})
+	assert.Nil(t, err)
+	assert.Nil(t, owner)
+
+	sortById := func(a, b interface{}) bool {
+		return a.(map[string]interface{})["Id"].(string) < b.(map[string]interface{})["Id"].(string)
+	}
+	page, err := children.GetPageByFilterWithIncludes("", nil, cdata.NewPagingParams(0, 10, true),
+		sortById, []string{"owner"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), *page.Total)
+	assert.Len(t, page.Data, 4)
+	assert.Equal(t, "1", page.Data[0].Includes["owner"].(Dummy).Id)
+	assert.Equal(t, "2", page.Data[2].Includes["owner"].(Dummy).Id)
+	assert.Nil(t, page.Data[3].Includes["owner"])
+
+	_, err = children.GetPageByFilterWithIncludes("", nil, nil, nil, []string{"unknown"})
+	assert.NotNil(t, err)
+	assert.Equal(t, "UNKNOWN_RELATION", err.(*errors.ApplicationError).Code)
+}
+
+func TestDummyRelationCascadeDelete(t *testing.T) {
+	owners, children := newRelatedPersistences()
+	owners.AddRelation(&cpersist.Relation{
+		Name: "children", Kind: cpersist.RelationMany, Target: &children.IdentifiableMemoryPersistence,
+		ForeignField: "owner_id", OnDelete: cpersist.OnDeleteCascade,
+	})
+
+	_, err := owners.DeleteById("", "1")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, children.GetItemCount())
+
+	err = owners.DeleteByIds("", []string{"2"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, children.GetItemCount())
+}
+
+func TestDummyRelationRestrictDelete(t *testing.T) {
+	owners, children := newRelatedPersistences()
+	owners.AddRelation(&cpersist.Relation{
+		Name: "children", Kind: cpersist.RelationMany, Target: &children.IdentifiableMemoryPersistence,
+		ForeignField: "owner_id", OnDelete: cpersist.OnDeleteRestrict,
+	})
+
+	_, err := owners.DeleteById("", "1")
+	assert.NotNil(t, err)
+	assert.Equal(t, "RELATED_ITEMS_EXIST", err.(*errors.ApplicationError).Code)
+	item, _ := owners.GetOneById("", "1")
+	assert.Equal(t, "1", item.Id)
+
+	children.DeleteByIds("", []string{"11", "12"})
+	_, err = owners.DeleteById("", "1")
+	assert.Nil(t, err)
+
+	err = owners.DeleteByIds("", []string{"2"})
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, owners.GetItemCount())
+}
+
+func TestDummyRelationRestrictSelf(t *testing.T) {
+	// Items of a tree cannot be deleted while they have children
+	nodes := NewDummyMapMemoryPersistence()
+	nodes.Configure(config.NewConfigParamsFromTuples("options.tenant_field", "tenant_id"))
+	nodes.AddRelation(&cpersist.Relation{
+		Name: "children", Kind: cpersist.RelationMany, Target: &nodes.IdentifiableMemoryPersistence,
+		ForeignField: "parent_id", OnDelete: cpersist.OnDeleteRestrict,
+	})
+	ctxA := cpersist.ContextWithTenantId(context.Background(), "a")
+	ctxB := cpersist.ContextWithTenantId(context.Background(), "b")
+	nodes.CreateWithContext(ctxA, map[string]interface{}{"Id": "1"})
+	nodes.CreateWithContext(ctxA, map[string]interface{}{"Id": "2", "parent_id": "1"})
+	nodes.CreateWithContext(ctxB, map[string]interface{}{"Id": "1"})
+	nodes.CreateWithContext(ctxB, map[string]interface{}{"Id": "3", "parent_id": "2"})
+
+	_, err := nodes.DeleteByIdWithContext(ctxA, "1")
+	assert.Equal(t, "RELATED_ITEMS_EXIST", err.(*errors.ApplicationError).Code)
+
+	// Children of other tenants do not restrict deletion
+	_, err = nodes.DeleteByIdWithContext(ctxB, "1")
+	assert.Nil(t, err)
+	_, err = nodes.DeleteByIdWithContext(ctxA, "2")
+	assert.Nil(t, err)
+
+	// Children deleted together with their parents do not restrict deletion
+	nodes.CreateWithContext(ctxA, map[string]interface{}{"Id": "2", "parent_id": "1"})
+	err = nodes.DeleteByFilterWithContext(ctxA, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, nodes.GetItemCount())
+}
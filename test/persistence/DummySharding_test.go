@@ -0,0 +1,196 @@
+package test_persistence
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	cconf "github.com/pip-services3-go/pip-services3-commons-go/config"
+	cdata "github.com/pip-services3-go/pip-services3-commons-go/data"
+	cpersist "github.com/pip-services3-go/pip-services3-data-go/persistence"
+	"github.com/stretchr/testify/assert"
+)
+
+func sortDummiesByKey(a, b interface{}) bool {
+	return a.(Dummy).Key < b.(Dummy).Key
+}
+
+func newShardedDummyPersistence(shards int) *cpersist.IdentifiableMemoryPersistence {
+	persistence := cpersist.NewIdentifiableMemoryPersistence(reflect.TypeOf(Dummy{}))
+	persistence.Configure(cconf.NewConfigParamsFromTuples("options.shards", shards))
+	return persistence
+}
+
+func TestDummyShardedMemoryPersistence(t *testing.T) {
+	persister := NewDummyMemoryPersistence()
+	persister.Configure(cconf.NewConfigParamsFromTuples("options.shards", 4))
+
+	fixture := NewDummyPersistenceFixture(persister)
+
+	t.Run("DummyShardedMemoryPersistence:CRUD", fixture.TestCrudOperations)
+	t.Run("DummyShardedMemoryPersistence:Batch", fixture.TestBatchOperations)
+}
+
+func TestDummySharding(t *testing.T) {
+	persistence := newShardedDummyPersistence(4)
+	assert.Equal(t, 4, persistence.ShardCount())
+
+	for i := 0; i < 20; i++ {
+		id := strconv.Itoa(i)
+		_, err := persistence.Create("", Dummy{Id: id, Key: "Key " + strconv.Itoa(100+i), Content: "Content " + id})
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 20, persistence.GetItemCount())
+	// Items are kept in shards
+	assert.Len(t, persistence.Items, 0)
+
+	// Pages are collected from all shards
+	page, err := persistence.GetPageByFilter("", func(item interface{}) bool { return true },
+		cdata.NewPagingParams(5, 3, true), sortDummiesByKey, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(20), *page.Total)
+	assert.Len(t, page.Data, 3)
+	assert.Equal(t, "5", page.Data[0].(Dummy).Id)
+	assert.Equal(t, "7", page.Data[2].(Dummy).Id)
+
+	count, err := persistence.GetCountByFilter("", func(item interface{}) bool {
+		return item.(Dummy).Key >= "Key 110"
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10), count)
+
+	items, err := persistence.GetListByIds("", []interface{}{"3", "13", "30"})
+	assert.Nil(t, err)
+	assert.Len(t, items, 2)
+
+	// Operations by id are routed to shards
+	item, err := persistence.Update("", Dummy{Id: "3", Key: "Key 3", Content: "Updated"})
+	assert.Nil(t, err)
+	assert.Equal(t, "Updated", item.(Dummy).Content)
+	item, err = persistence.GetOneById("", "3")
+	assert.Nil(t, err)
+	assert.Equal(t, "Updated", item.(Dummy).Content)
+
+	item, err = persistence.DeleteById("", "3")
+	assert.Nil(t, err)
+	assert.NotNil(t, item)
+	err = persistence.DeleteByIds("", []interface{}{"4", "5"})
+	assert.Nil(t, err)
+	err = persistence.DeleteByFilter("", func(item interface{}) bool {
+		return item.(Dummy).Key >= "Key 110"
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 7, persistence.GetItemCount())
+
+	// Switching back to default mode moves items into Items
+	persistence.Configure(cconf.NewConfigParamsFromTuples("options.shards", 1))
+	assert.Equal(t, 1, persistence.ShardCount())
+	assert.Len(t, persistence.Items, 7)
+	item, err = persistence.GetOneById("", "6")
+	assert.Nil(t, err)
+	assert.NotNil(t, item)
+}
+
+func TestDummyShardingLoadSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sharded")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	persister := cpersist.NewJsonFilePersister(reflect.TypeOf(Dummy{}), filepath.Join(dir, "dummies.json"))
+	persistence := newShardedDummyPersistence(3)
+	persistence.Loader = persister
+	persistence.Saver = persister
+	assert.Nil(t, persistence.Open(""))
+	for i := 0; i < 10; i++ {
+		persistence.Create("", Dummy{Id: strconv.Itoa(i), Key: "Key " + strconv.Itoa(i)})
+	}
+	assert.Nil(t, persistence.Close(""))
+
+	persistence = newShardedDummyPersistence(5)
+	persistence.Loader = persister
+	assert.Nil(t, persistence.Open(""))
+	assert.Equal(t, 10, persistence.GetItemCount())
+	item, err := persistence.GetOneById("", "7")
+	assert.Nil(t, err)
+	assert.Equal(t, "Key 7", item.(Dummy).Key)
+}
+
+func TestDummyShardingEviction(t *testing.T) {
+	persistence := cpersist.NewIdentifiableMemoryPersistence(reflect.TypeOf(Dummy{}))
+	persistence.Configure(cconf.NewConfigParamsFromTuples(
+		"options.shards", 4,
+		"options.max_items", 5,
+		"options.eviction_policy", "lru",
+	))
+
+	for i := 0; i < 5; i++ {
+		persistence.Create("", Dummy{Id: strconv.Itoa(i), Key: "Key " + strconv.Itoa(i)})
+	}
+	persistence.GetOneById("", "0")
+	persistence.Create("", Dummy{Id: "5", Key: "Key 5"})
+
+	// The limit applies to all shards together
+	assert.Equal(t, 5, persistence.GetItemCount())
+	item, _ := persistence.GetOneById("", "1")
+	assert.Nil(t, item)
+	item, _ = persistence.GetOneById("", "0")
+	assert.NotNil(t, item)
+}
+
+func TestDummyShardingConcurrent(t *testing.T) {
+	persistence := newShardedDummyPersistence(4)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				id := strconv.Itoa(w*100 + i)
+				persistence.Create("", Dummy{Id: id, Key: "Key " + id})
+				persistence.GetOneById("", id)
+				persistence.GetCountByFilter("", func(item interface{}) bool { return true })
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 400, persistence.GetItemCount())
+}
+
+// Runs a concurrent workload with 1 write per 4 operations and a page scan per 16 operations
+func benchmarkConcurrentLoad(b *testing.B, persistence *cpersist.IdentifiableMemoryPersistence) {
+	for i := 0; i < 1000; i++ {
+		persistence.Create("", Dummy{Id: strconv.Itoa(i), Key: "Key " + strconv.Itoa(i)})
+	}
+	var counter int64 = 1000
+	filter := func(item interface{}) bool { return item.(Dummy).Key == "Key 1" }
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for n := 0; pb.Next(); n++ {
+			switch {
+			case n%16 == 0:
+				persistence.GetPageByFilter("", filter, nil, nil, nil)
+			case n%4 == 0:
+				id := strconv.FormatInt(atomic.AddInt64(&counter, 1), 10)
+				persistence.Create("", Dummy{Id: id, Key: "Key " + id})
+			default:
+				persistence.GetOneById("", strconv.Itoa(n%1000))
+			}
+		}
+	})
+}
+
+func BenchmarkMemoryPersistenceConcurrent(b *testing.B) {
+	benchmarkConcurrentLoad(b, newShardedDummyPersistence(0))
+}
+
+func BenchmarkShardedMemoryPersistenceConcurrent(b *testing.B) {
+	benchmarkConcurrentLoad(b, newShardedDummyPersistence(16))
+}
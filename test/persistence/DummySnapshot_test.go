@@ -0,0 +1,88 @@
+package test_persistence
+
+import (
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+
+	cconf "github.com/pip-services3-go/pip-services3-commons-go/config"
+	cpersist "github.com/pip-services3-go/pip-services3-data-go/persistence"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDummySnapshotRestore(t *testing.T) {
+	persistence := NewDummyMemoryPersistence()
+
+	persistence.Create("", Dummy{Id: "1", Key: "Key 1", Content: "Content 1"})
+	persistence.Create("", Dummy{Id: "2", Key: "Key 2", Content: "Content 2"})
+
+	snapshot := persistence.Snapshot("")
+	assert.Equal(t, 2, snapshot.Len())
+
+	persistence.DeleteById("", "1")
+	persistence.Update("", Dummy{Id: "2", Key: "Key 2", Content: "Updated"})
+	persistence.Create("", Dummy{Id: "3", Key: "Key 3", Content: "Content 3"})
+	assert.Equal(t, 2, snapshot.Len())
+
+	err := persistence.Restore("", snapshot)
+	assert.Nil(t, err)
+
+	count, _ := persistence.GetCountByFilter("", nil)
+	assert.Equal(t, int64(2), count)
+	item, _ := persistence.GetOneById("", "2")
+	assert.Equal(t, "Content 2", item.Content)
+	item, _ = persistence.GetOneById("", "3")
+	assert.Equal(t, "", item.Id)
+
+	err = persistence.Restore("", nil)
+	assert.NotNil(t, err)
+}
+
+func TestDummySnapshotSaveLoad(t *testing.T) {
+	filename := "../../data/dummies_snapshot.json"
+	defer os.Remove(filename)
+
+	persistence := NewDummyMemoryPersistence()
+	persistence.Create("", Dummy{Id: "1", Key: "Key 1", Content: "Content 1"})
+
+	saver := cpersist.NewJsonFilePersister(persistence.Prototype, filename)
+	err := persistence.Snapshot("").Save("", saver)
+	assert.Nil(t, err)
+
+	snapshot, err := cpersist.LoadMemorySnapshot("", reflect.TypeOf(Dummy{}), saver)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, snapshot.Len())
+
+	restored := NewDummyMemoryPersistence()
+	err = restored.Restore("", snapshot)
+	assert.Nil(t, err)
+
+	item, _ := restored.GetOneById("", "1")
+	assert.Equal(t, "Key 1", item.Key)
+	assert.Equal(t, "Content 1", item.Content)
+}
+
+func TestDummySnapshotRestoreMaxItems(t *testing.T) {
+	persistence := NewDummyMemoryPersistence()
+	for i := 1; i <= 5; i++ {
+		id := strconv.Itoa(i)
+		persistence.Create("", Dummy{Id: id, Key: "Key " + id})
+	}
+	snapshot := persistence.Snapshot("")
+
+	restored := NewDummyMemoryPersistence()
+	restored.Configure(cconf.NewConfigParamsFromTuples("options.max_items", 3))
+	evicted := 0
+	restored.OnEvicted = func(correlationId string, item interface{}) { evicted++ }
+
+	// Extra items of the snapshot are evicted from the first ones
+	err := restored.Restore("", snapshot)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, restored.GetItemCount())
+	assert.Equal(t, 2, evicted)
+	item, _ := restored.GetOneById("", "2")
+	assert.Equal(t, "", item.Id)
+	item, _ = restored.GetOneById("", "5")
+	assert.Equal(t, "Key 5", item.Key)
+}
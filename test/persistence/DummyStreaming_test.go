@@ -0,0 +1,62 @@
+package test_persistence
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDummyStreaming(t *testing.T) {
+	persistence := NewDummyMemoryPersistence()
+	for _, id := range []string{"1", "2", "3", "4", "5"} {
+		persistence.Create("", Dummy{Id: id, Key: "Key " + id, Content: "Content " + id})
+	}
+	odd := func(item interface{}) bool {
+		id := item.(Dummy).Id
+		return id == "1" || id == "3" || id == "5"
+	}
+
+	ids := make([]string, 0)
+	err := persistence.ForEachByFilter("", odd, func(item interface{}) bool {
+		ids = append(ids, item.(Dummy).Id)
+		return true
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "3", "5"}, ids)
+
+	// Early termination, callbacks may write to the persistence
+	ids = make([]string, 0)
+	persistence.ForEachByFilter("", nil, func(item interface{}) bool {
+		ids = append(ids, item.(Dummy).Id)
+		persistence.DeleteById("", item.(Dummy).Id)
+		return len(ids) < 2
+	})
+	assert.Equal(t, []string{"1", "2"}, ids)
+
+	ids = make([]string, 0)
+	stream, err := persistence.StreamByFilter(context.Background(), nil)
+	assert.Nil(t, err)
+	for item := range stream {
+		ids = append(ids, item.(Dummy).Id)
+	}
+	assert.Equal(t, []string{"3", "4", "5"}, ids)
+
+	// Cancelled stream is closed
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err = persistence.StreamByFilter(ctx, nil)
+	assert.Nil(t, err)
+	item := <-stream
+	assert.Equal(t, "3", item.(Dummy).Id)
+	cancel()
+	count := 0
+	for range stream {
+		count++
+	}
+	assert.True(t, count <= 1)
+
+	// Errors are returned instead of an empty stream
+	stream, err = persistence.StreamByFilter(ctx, nil)
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, stream)
+}
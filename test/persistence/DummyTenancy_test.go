@@ -0,0 +1,196 @@
+package test_persistence
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pip-services3-go/pip-services3-commons-go/config"
+	"github.com/pip-services3-go/pip-services3-commons-go/errors"
+	cpersist "github.com/pip-services3-go/pip-services3-data-go/persistence"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDummyTenancy(t *testing.T) {
+	persistence := cpersist.NewIdentifiableMemoryPersistence(reflect.TypeOf(map[string]interface{}{}))
+	persistence.Configure(config.NewConfigParamsFromTuples("options.tenant_field", "tenant_id"))
+	ctxA := cpersist.ContextWithTenantId(context.Background(), "a")
+	ctxB := cpersist.ContextWithTenantId(context.Background(), "b")
+
+	// Creates are stamped with the tenant
+	item, err := persistence.CreateWithContext(ctxA, map[string]interface{}{"id": "1", "key": "Key 1"})
+	assert.Nil(t, err)
+	assert.Equal(t, "a", item.(map[string]interface{})["tenant_id"])
+	persistence.CreateWithContext(ctxA, map[string]interface{}{"id": "2", "key": "Key 2"})
+	persistence.CreateWithContext(ctxB, map[string]interface{}{"id": "3", "key": "Key 3", "tenant_id": "a"})
+
+	// Reads see items of their tenant only
+	items, err := persistence.GetListByFilterWithContext(ctxA, nil, nil, nil)
+	assert.Nil(t, err)
+	assert.Len(t, items, 2)
+	count, err := persistence.GetCountByFilterWithContext(ctxB, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+	item, err = persistence.GetOneByIdWithContext(ctxA, "3")
+	assert.Nil(t, err)
+	assert.Nil(t, item)
+
+	// Cross-tenant changes are not found
+	_, err = persistence.UpdateWithContext(ctxA, map[string]interface{}{"id": "3", "key": "Key 33"})
+	assert.Equal(t, "ITEM_NOT_FOUND", err.(*errors.ApplicationError).Code)
+	_, err = persistence.DeleteByIdWithContext(ctxB, "1")
+	assert.Equal(t, "ITEM_NOT_FOUND", err.(*errors.ApplicationError).Code)
+	assert.Equal(t, 3, persistence.GetItemCount())
+
+	// Items cannot be moved to other tenants
+	item, err = persistence.UpdateWithContext(ctxA, map[string]interface{}{"id": "1", "key": "Key 11", "tenant_id": "b"})
+	assert.Nil(t, err)
+	assert.Equal(t, "a", item.(map[string]interface{})["tenant_id"])
+
+	// Operations without tenant are rejected
+	_, err = persistence.GetOneById("", "1")
+	assert.Equal(t, "NO_TENANT", err.(*errors.ApplicationError).Code)
+	_, err = persistence.GetFacets("", []string{"key"}, nil)
+	assert.Equal(t, "NO_TENANT", err.(*errors.ApplicationError).Code)
+
+	err = persistence.DeleteByFilterWithContext(ctxA, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, persistence.GetItemCount())
+}
+
+func TestDummyTenantScopes(t *testing.T) {
+	persistence := cpersist.NewIdentifiableMemoryPersistence(reflect.TypeOf(map[string]interface{}{}))
+	persistence.Configure(config.NewConfigParamsFromTuples(
+		"options.tenant_field", "tenant_id",
+		"options.text_fields", "key",
+		"history.enabled", true,
+	))
+	ctxA := cpersist.ContextWithTenantId(context.Background(), "a")
+	ctxB := cpersist.ContextWithTenantId(context.Background(), "b")
+
+	// Ids are unique within a tenant only
+	_, err := persistence.CreateWithContext(ctxA, map[string]interface{}{"id": "1", "key": "Key A"})
+	assert.Nil(t, err)
+	_, err = persistence.CreateWithContext(ctxB, map[string]interface{}{"id": "1", "key": "Key B"})
+	assert.Nil(t, err)
+	_, err = persistence.CreateWithContext(ctxA, map[string]interface{}{"id": "1", "key": "Key AA"})
+	assert.Equal(t, "ITEM_EXISTS", err.(*errors.ApplicationError).Code)
+
+	item, err := persistence.SetWithContext(ctxB, map[string]interface{}{"id": "1", "key": "Key BB"})
+	assert.Nil(t, err)
+	assert.Equal(t, "b", item.(map[string]interface{})["tenant_id"])
+	item, _ = persistence.GetOneByIdWithContext(ctxA, "1")
+	assert.Equal(t, "Key A", item.(map[string]interface{})["key"])
+
+	results, err := persistence.UpdateManyWithContext(ctxA, []interface{}{map[string]interface{}{"id": "1", "key": "Key AAA"}})
+	assert.Nil(t, err)
+	assert.Nil(t, results[0].Err)
+	item, _ = persistence.GetOneByIdWithContext(ctxB, "1")
+	assert.Equal(t, "Key BB", item.(map[string]interface{})["key"])
+
+	// History and text search are kept per tenant
+	versions, err := persistence.GetHistoryByIdWithContext(ctxB, "1")
+	assert.Nil(t, err)
+	assert.Len(t, versions, 2)
+	assert.Equal(t, "b", versions[0].TenantId)
+	match, err := persistence.MatchTextWithContext(ctxA, "key")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, match.Len())
+	_, err = persistence.MatchText("", "key")
+	assert.Equal(t, "NO_TENANT", err.(*errors.ApplicationError).Code)
+
+	groups, err := persistence.AggregateWithContext(ctxA, nil, nil,
+		[]cpersist.Aggregation{cpersist.NewAggregation("count", cpersist.AggregateCount, "")})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), groups[0].Values["count"])
+	page, err := persistence.GetPageByKeysetWithContext(ctxB, nil, nil, "key", false, nil)
+	assert.Nil(t, err)
+	assert.Len(t, page.Data, 1)
+
+	// Clear removes items of the tenant only
+	err = persistence.Clear("")
+	assert.Equal(t, "NO_TENANT", err.(*errors.ApplicationError).Code)
+	err = persistence.ClearWithContext(ctxA)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, persistence.GetItemCount())
+	item, _ = persistence.GetOneByIdWithContext(ctxB, "1")
+	assert.NotNil(t, item)
+}
+
+func TestDummyDuplicateIdsWithoutTenants(t *testing.T) {
+	// Ids are checked for duplicates only in tenant mode
+	persistence := cpersist.NewIdentifiableMemoryPersistence(reflect.TypeOf(map[string]interface{}{}))
+	_, err := persistence.Create("", map[string]interface{}{"id": "1", "key": "Key 1"})
+	assert.Nil(t, err)
+	_, err = persistence.Create("", map[string]interface{}{"id": "1", "key": "Key 2"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, persistence.GetItemCount())
+}
+
+func TestDummyTenantFilePaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tenants")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	prototype := reflect.TypeOf(map[string]interface{}{})
+	persister := cpersist.NewTenantFilePersister(prototype, filepath.Join(dir, "{tenant}", "dummies.json"), "tenant_id")
+
+	path, err := persister.TenantPath("a")
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dir, "a", "dummies.json"), path)
+
+	// Tenant ids that could point outside of the data directory are rejected
+	for _, tenantId := range []string{"", ".", "..", "../a", "a/b", "a\\b", "a\x00b"} {
+		_, err = persister.TenantPath(tenantId)
+		assert.Equal(t, "INVALID_TENANT", err.(*errors.ApplicationError).Code, tenantId)
+	}
+
+	err = persister.Save("", []interface{}{
+		map[string]interface{}{"id": "1", "tenant_id": "a"},
+		map[string]interface{}{"id": "2", "tenant_id": "../b"},
+	})
+	assert.Equal(t, "INVALID_TENANT", err.(*errors.ApplicationError).Code)
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	assert.Len(t, files, 0)
+}
+
+func TestDummyTenantFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tenants")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	prototype := reflect.TypeOf(map[string]interface{}{})
+	params := config.NewConfigParamsFromTuples(
+		"path", filepath.Join(dir, "{tenant}", "dummies.json"),
+		"options.tenant_field", "tenant_id",
+	)
+	persistence := cpersist.NewIdentifiableFilePersistence(prototype, nil)
+	persistence.Configure(params)
+	assert.Nil(t, persistence.Open(""))
+
+	ctxA := cpersist.ContextWithTenantId(context.Background(), "a")
+	ctxB := cpersist.ContextWithTenantId(context.Background(), "b")
+	persistence.CreateWithContext(ctxA, map[string]interface{}{"id": "1", "key": "Key 1"})
+	persistence.CreateWithContext(ctxB, map[string]interface{}{"id": "2", "key": "Key 2"})
+	persistence.CreateWithContext(ctxB, map[string]interface{}{"id": "3", "key": "Key 3"})
+	persistence.Close("")
+
+	assert.FileExists(t, filepath.Join(dir, "a", "dummies.json"))
+	assert.FileExists(t, filepath.Join(dir, "b", "dummies.json"))
+
+	persistence = cpersist.NewIdentifiableFilePersistence(prototype, nil)
+	persistence.Configure(params)
+	assert.Nil(t, persistence.Open(""))
+	assert.Equal(t, 3, persistence.GetItemCount())
+	count, _ := persistence.GetCountByFilterWithContext(ctxB, nil)
+	assert.Equal(t, int64(2), count)
+
+	// File of a tenant without items is saved empty
+	persistence.DeleteByIdWithContext(ctxA, "1")
+	data, err := ioutil.ReadFile(filepath.Join(dir, "a", "dummies.json"))
+	assert.Nil(t, err)
+	assert.Equal(t, "[]", string(data))
+}
@@ -0,0 +1,52 @@
+package test_persistence
+
+import (
+	"testing"
+
+	cconf "github.com/pip-services3-go/pip-services3-commons-go/config"
+	cdata "github.com/pip-services3-go/pip-services3-commons-go/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDummyTextSearch(t *testing.T) {
+	persistence := NewDummyMemoryPersistence()
+
+	_, err := persistence.MatchText("", "fox")
+	assert.NotNil(t, err)
+
+	persistence.Configure(cconf.NewConfigParamsFromTuples(
+		"options.text_fields", "key, content",
+	))
+	persistence.Create("", Dummy{Id: "1", Key: "Animals", Content: "The quick brown fox"})
+	persistence.Create("", Dummy{Id: "2", Key: "Foxes", Content: "Fox and fox cubs"})
+	persistence.Create("", Dummy{Id: "3", Key: "Dogs", Content: "A lazy dog"})
+
+	match, err := persistence.MatchText("", "FOX")
+	assert.Nil(t, err)
+	page, err := persistence.IdentifiableMemoryPersistence.GetPageByFilter("", match.Filter, cdata.NewEmptyPagingParams(), match.Less, nil)
+	assert.Nil(t, err)
+	assert.Len(t, page.Data, 2)
+	assert.Equal(t, "2", page.Data[0].(Dummy).Id)
+	assert.Equal(t, "1", page.Data[1].(Dummy).Id)
+
+	// All tokens shall match, by prefix as well
+	match, _ = persistence.MatchText("", "qui fox")
+	assert.Equal(t, 1, match.Len())
+	assert.True(t, match.Filter(Dummy{Id: "1"}))
+
+	// Index is updated on writes
+	persistence.Update("", Dummy{Id: "3", Key: "Dogs", Content: "A lazy dog chasing a fox"})
+	persistence.DeleteById("", "2")
+	match, _ = persistence.MatchText("", "fox")
+	assert.Equal(t, 2, match.Len())
+	assert.False(t, match.Filter(Dummy{Id: "2"}))
+	assert.True(t, match.Filter(Dummy{Id: "3"}))
+
+	match, _ = persistence.MatchText("", "cat")
+	assert.Equal(t, 0, match.Len())
+
+	// Empty search matches all items
+	match, _ = persistence.MatchText("", " ")
+	count, _ := persistence.IdentifiableMemoryPersistence.GetCountByFilter("", match.Filter)
+	assert.Equal(t, int64(2), count)
+}
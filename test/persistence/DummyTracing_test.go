@@ -0,0 +1,64 @@
+package test_persistence
+
+import (
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/pip-services3-go/pip-services3-commons-go/refer"
+	"github.com/pip-services3-go/pip-services3-components-go/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+type testTrace struct {
+	correlationId string
+	component     string
+	operation     string
+	err           error
+}
+
+type testTracer struct {
+	lock   sync.Mutex
+	traces []testTrace
+}
+
+func (c *testTracer) Trace(correlationId string, component string, operation string, duration int64) {
+	c.Failure(correlationId, component, operation, nil, duration)
+}
+
+func (c *testTracer) Failure(correlationId string, component string, operation string, err error, duration int64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.traces = append(c.traces, testTrace{correlationId, component, operation, err})
+}
+
+func (c *testTracer) BeginTrace(correlationId string, component string, operation string) *trace.TraceTiming {
+	return trace.NewTraceTiming(correlationId, component, operation, c)
+}
+
+func TestDummyTracing(t *testing.T) {
+	path := "../../data/dummies_tracing.json"
+	defer os.Remove(path)
+
+	tracer := &testTracer{}
+	persistence := NewDummyFilePersistence(path)
+	persistence.SetReferences(refer.NewReferencesFromTuples(
+		refer.NewDescriptor("pip-services", "tracer", "test", "default", "1.0"), tracer,
+	))
+
+	persistence.Create("123", Dummy{Id: "1", Key: "Key 1", Content: "Content 1"})
+	persistence.Update("123", Dummy{Id: "2", Key: "Key 2", Content: "Content 2"})
+
+	operations := make([]string, 0)
+	for _, v := range tracer.traces {
+		assert.Equal(t, "123", v.correlationId)
+		assert.Nil(t, v.err)
+		operations = append(operations, v.component+"."+v.operation)
+	}
+	assert.Equal(t, []string{
+		"persister.json.save",
+		"persistence.dummy.save",
+		"persistence.dummy.create",
+		"persistence.dummy.update",
+	}, operations)
+}
@@ -0,0 +1,53 @@
+package test_persistence
+
+import (
+	"testing"
+
+	cpersist "github.com/pip-services3-go/pip-services3-data-go/persistence"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestItemAggregation(t *testing.T) {
+	persistence := NewItemMemoryPersistence()
+	persistence.Create("", Item{Id: "1", UpdatedBy: "bob", FailingToUpdateThisField1: 10, FailingToUpdateThisField2: 1})
+	persistence.Create("", Item{Id: "2", UpdatedBy: "alice", FailingToUpdateThisField1: 20, FailingToUpdateThisField2: 1})
+	persistence.Create("", Item{Id: "3", UpdatedBy: "bob", FailingToUpdateThisField1: 30, FailingToUpdateThisField2: 2})
+	persistence.Create("", Item{Id: "4", UpdatedBy: "bob", FailingToUpdateThisField1: 5, FailingToUpdateThisField2: 2})
+
+	groups, err := persistence.Aggregate("", nil, []string{"UpdatedBy"}, []cpersist.Aggregation{
+		cpersist.NewAggregation("count", cpersist.AggregateCount, ""),
+		cpersist.NewAggregation("sum", cpersist.AggregateSum, "FailingToUpdateThisField1"),
+		cpersist.NewAggregation("min", cpersist.AggregateMin, "FailingToUpdateThisField1"),
+		cpersist.NewAggregation("max", cpersist.AggregateMax, "FailingToUpdateThisField1"),
+		cpersist.NewAggregation("avg", cpersist.AggregateAvg, "FailingToUpdateThisField1"),
+		cpersist.NewAggregation("distinct", cpersist.AggregateDistinct, "FailingToUpdateThisField2"),
+	})
+	assert.Nil(t, err)
+	assert.Len(t, groups, 2)
+
+	assert.Equal(t, "alice", groups[0].Keys["UpdatedBy"])
+	assert.Equal(t, int64(1), groups[0].Values["count"])
+	assert.Equal(t, float64(20), groups[0].Values["sum"])
+
+	assert.Equal(t, "bob", groups[1].Keys["UpdatedBy"])
+	assert.Equal(t, int64(3), groups[1].Values["count"])
+	assert.Equal(t, float64(45), groups[1].Values["sum"])
+	assert.Equal(t, int64(5), groups[1].Values["min"])
+	assert.Equal(t, int64(30), groups[1].Values["max"])
+	assert.Equal(t, float64(15), groups[1].Values["avg"])
+	assert.Equal(t, int64(2), groups[1].Values["distinct"])
+
+	groups, err = persistence.Aggregate("", func(item interface{}) bool {
+		return item.(Item).FailingToUpdateThisField2 == 2
+	}, nil, []cpersist.Aggregation{
+		cpersist.NewAggregation("total", cpersist.AggregateSum, "FailingToUpdateThisField1"),
+	})
+	assert.Nil(t, err)
+	assert.Len(t, groups, 1)
+	assert.Equal(t, float64(35), groups[0].Values["total"])
+
+	_, err = persistence.Aggregate("", nil, nil, []cpersist.Aggregation{
+		cpersist.NewAggregation("median", "median", "FailingToUpdateThisField1"),
+	})
+	assert.NotNil(t, err)
+}
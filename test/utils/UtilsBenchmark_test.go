@@ -0,0 +1,107 @@
+package test_utils
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	persist "github.com/pip-services3-go/pip-services3-data-go/persistence"
+)
+
+type benchmarkLoader struct {
+	items []interface{}
+}
+
+func (c *benchmarkLoader) Load(correlationId string) ([]interface{}, error) {
+	return c.items, nil
+}
+
+func newNestedOwnerGroup() NestedOwnerGroup {
+	return NestedOwnerGroup{
+		OwnerGrouping: OwnerGrouping{
+			Owner:    Owner{ID: "1", Asset: 123, Job: 456, Site: 987},
+			Version:  1,
+			Modified: 67890,
+		},
+		NestedField: "nested",
+		Testing:     1,
+	}
+}
+
+func BenchmarkGetPropertyUtils(b *testing.B) {
+	item := newNestedOwnerGroup()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		persist.GetProperty(item, "site")
+		persist.GetProperty(item, "nested_field")
+	}
+}
+
+func BenchmarkSetPropertyUtils(b *testing.B) {
+	item := newNestedOwnerGroup()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		persist.SetProperty(&item, "site", uint64(i))
+		persist.SetProperty(&item, "Version", int32(i))
+	}
+}
+
+func BenchmarkGenerateObjectIdUtils(b *testing.B) {
+	generator := persist.IdGeneratorFunc(func() interface{} { return "1" })
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var item interface{} = NestedOwnerGroup{NestedField: "nested"}
+		persist.GenerateObjectIdWithGenerator(&item, generator)
+	}
+}
+
+func BenchmarkCloneFlatObjectUtils(b *testing.B) {
+	item := newNestedOwnerGroup()
+	prototype := reflect.TypeOf(item)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		persist.CloneObject(item, prototype)
+	}
+}
+
+func BenchmarkCloneNestedObjectUtils(b *testing.B) {
+	item := AttributeV1{
+		Id:          1,
+		DisplayName: "Attribute",
+		TagMap: map[uint64]*TagV1{
+			1: {Id: 1, ValidFrom: time.Now(), UoM: 2},
+			2: {Id: 2, ValidFrom: time.Now(), UoM: 3},
+		},
+		Properties: map[string]interface{}{"key": "value"},
+	}
+	prototype := reflect.TypeOf(item)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		persist.CloneObject(item, prototype)
+	}
+}
+
+func BenchmarkLoadItemsUtils(b *testing.B) {
+	loader := &benchmarkLoader{}
+	for i := 0; i < 1000; i++ {
+		loader.items = append(loader.items, map[string]interface{}{
+			"id":           strconv.Itoa(i),
+			"asset":        "123",
+			"job":          "456",
+			"site":         "987",
+			"version":      float64(i),
+			"Modified":     "67890",
+			"Deleted":      false,
+			"nested_field": "\"nested\"",
+			"testing":      "1",
+		})
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		persistence := persist.NewMemoryPersistence(reflect.TypeOf(NestedOwnerGroup{}))
+		persistence.Loader = loader
+		persistence.Open("")
+	}
+}
@@ -1,6 +1,7 @@
 package test_utils
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
 	"time"
@@ -98,10 +99,238 @@ func TestCloneObjectUtils(t *testing.T) {
 
 	assert.NotNil(t, copyAttribute.Properties)
 
-	// atribute.TagMap[456].Id += 1
-	// assert.Equal(t, atribute.TagMap[456].Id, (uint64)(124))
-	// assert.Equal(t, tag.Id, (uint64)(123))
+	atribute.TagMap[456].Id += 1
+	assert.Equal(t, atribute.TagMap[456].Id, (uint64)(124))
+	assert.Equal(t, tag.Id, (uint64)(123))
+
+}
 
+type CloneableTag struct {
+	Id     uint64
+	Labels []string
+	cloned bool
+}
+
+func (c *CloneableTag) Clone() interface{} {
+	return &CloneableTag{Id: c.Id, Labels: append([]string{}, c.Labels...), cloned: true}
+}
+
+func TestCloneObjectStrategiesUtils(t *testing.T) {
+	atribute := AttributeV1{
+		Id:         1,
+		TagMap:     map[uint64]*TagV1{1: {Id: 1}},
+		Properties: map[string]interface{}{"list": []interface{}{"a"}},
+	}
+	prototype := reflect.TypeOf(atribute)
+
+	deep := persist.CloneObjectWithStrategy(atribute, prototype, persist.CloneDeep).(AttributeV1)
+	deep.TagMap[1].Id = 2
+	deep.Properties["list"].([]interface{})[0] = "b"
+	assert.Equal(t, (uint64)(1), atribute.TagMap[1].Id)
+	assert.Equal(t, "a", atribute.Properties["list"].([]interface{})[0])
+
+	shallow := persist.CloneObjectWithStrategy(atribute, prototype, persist.CloneShallow).(AttributeV1)
+	shallow.TagMap[1].Id = 3
+	assert.Equal(t, (uint64)(3), atribute.TagMap[1].Id)
+
+	// Pointer prototypes receive new pointers even without copying
+	result := persist.CloneObjectForResultWithStrategy(atribute, reflect.TypeOf(&atribute), persist.CloneNone)
+	assert.Equal(t, atribute.Id, result.(*AttributeV1).Id)
+
+	// Cloneable values copy themselves
+	tags := map[string]*CloneableTag{"a": {Id: 1, Labels: []string{"x"}}}
+	copyTags := persist.DeepCopy(tags).(map[string]*CloneableTag)
+	assert.True(t, copyTags["a"].cloned)
+	copyTags["a"].Labels[0] = "y"
+	assert.Equal(t, "x", tags["a"].Labels[0])
+}
+
+type PrivateState struct {
+	Name    string
+	labels  map[string]string
+	history []int
+	parent  *PrivateState
+}
+
+func TestDeepCopyUnexportedUtils(t *testing.T) {
+	// Unexported maps, slices and pointers are copied too
+	parent := &PrivateState{Name: "parent"}
+	state := PrivateState{Name: "child", labels: map[string]string{"a": "x"}, history: []int{1}, parent: parent}
+	copyState := persist.DeepCopy(state).(PrivateState)
+	copyState.labels["a"] = "y"
+	copyState.history[0] = 2
+	copyState.parent.Name = "changed"
+	assert.Equal(t, "x", state.labels["a"])
+	assert.Equal(t, 1, state.history[0])
+	assert.Equal(t, "parent", parent.Name)
+
+	// Self-containing maps and slices keep their cycles
+	cyclicMap := map[string]interface{}{}
+	cyclicMap["self"] = cyclicMap
+	copyMap := persist.DeepCopy(cyclicMap).(map[string]interface{})
+	copyMap["name"] = "copy"
+	assert.Equal(t, "copy", copyMap["self"].(map[string]interface{})["name"])
+	assert.Nil(t, cyclicMap["name"])
+
+	cyclicList := make([]interface{}, 1)
+	cyclicList[0] = cyclicList
+	copyList := persist.DeepCopy(cyclicList).([]interface{})
+	copyList[0].([]interface{})[0] = "copy"
+	assert.Equal(t, "copy", copyList[0])
+	assert.IsType(t, []interface{}{}, cyclicList[0])
+}
+
+func TestValueComparerUtils(t *testing.T) {
+	comparer := persist.ValueComparer
+
+	// Numbers are compared by value, other types are ranked before their values
+	assert.True(t, comparer.Equals("1", int64(1)))
+	assert.True(t, comparer.Equals(uint8(1), 1.0))
+	assert.True(t, comparer.Less(-1, uint64(1)))
+	assert.False(t, comparer.Less(uint64(1<<63), int64(1)<<62))
+	assert.True(t, comparer.Less(int64(1)<<60, float64(int64(1)<<60)+512))
+	assert.False(t, comparer.Equals(int64(1)<<53+1, float64(int64(1)<<53)))
+	// Strings with numbers are compared with numbers by value and with strings by content
+	assert.True(t, comparer.Less("2", 10))
+	assert.True(t, comparer.Less(9, "10"))
+	assert.True(t, comparer.Equals(" 1.5", float32(1.5)))
+	assert.True(t, comparer.Equals("9223372036854775807", int64(9223372036854775807)))
+	assert.False(t, comparer.Equals("9223372036854775806", int64(9223372036854775807)))
+	assert.True(t, comparer.Less(10, "abc"))
+	assert.True(t, comparer.Less("10", "2"))
+
+	// Nil values are less than others
+	assert.True(t, comparer.Equals(nil, nil))
+	assert.True(t, comparer.Less(nil, 0))
+	var ptr *int
+	assert.True(t, comparer.Equals(ptr, nil))
+
+	// Times are compared as instants and never equal to strings
+	now := time.Now().UTC()
+	assert.True(t, comparer.Equals(now, now.In(time.FixedZone("X", 3600))))
+	assert.False(t, comparer.Equals(now, now.Format(time.RFC3339Nano)))
+	assert.False(t, comparer.Equals(time.Time{}, "not a time"))
+	assert.True(t, comparer.Less(now, now.Add(time.Second)))
+
+	// Byte slices, slices and maps are compared by content
+	assert.True(t, comparer.Equals([]byte("abc"), "abc"))
+	assert.False(t, comparer.Equals(Counter{Id: 1}, Counter{Id: 2}))
+	assert.False(t, comparer.Equals(Counter{Id: 1}, NoId{}))
+	assert.True(t, comparer.Less([]byte("abc"), []byte("abd")))
+	assert.True(t, comparer.Equals([]interface{}{1, "a"}, []interface{}{int64(1), "a"}))
+	assert.True(t, comparer.Less([]int{1, 2}, []int{1, 2, 0}))
+	assert.True(t, comparer.Equals(
+		map[string]interface{}{"a": 1, "b": []string{"x"}},
+		map[string]interface{}{"b": []string{"x"}, "a": 1.0}))
+	assert.False(t, comparer.Equals(map[string]int{"a": 1}, map[string]int{"a": 2}))
+
+	// Uncomparable values do not panic
+	assert.False(t, persist.CompareValues(map[string]int{"a": 1}, []int{1}))
+	assert.True(t, persist.CompareValues(int64(1), "1"))
+}
+
+func TestValueComparerTransitivityUtils(t *testing.T) {
+	comparer := persist.ValueComparer
+	var ptr *int
+	one := 1
+	// Strings with numbers are left out: they are compared with numbers by value
+	// and with other strings by content, so they are not ordered transitively
+	values := []interface{}{
+		nil, ptr, &one, false, true,
+		-1, 0, 1, int64(1) << 62, uint64(1) << 63, 1.5, -0.5, float32(2), 10, "", "abc1",
+		[]byte("abc"), "abc", time.Time{}, time.Unix(0, 0),
+		[]int{1}, []interface{}{1, "a"}, map[string]int{"a": 1},
+		Counter{Id: 1}, Counter{Id: 2}, NoId{}, &Counter{Id: 1},
+	}
+
+	for _, a := range values {
+		assert.Equal(t, 0, comparer.Compare(a, a))
+		for _, b := range values {
+			ab := comparer.Compare(a, b)
+			assert.Equal(t, -sign(ab), sign(comparer.Compare(b, a)), "%v <=> %v", a, b)
+			for _, c := range values {
+				bc := comparer.Compare(b, c)
+				ac := comparer.Compare(a, c)
+				if ab <= 0 && bc <= 0 {
+					assert.LessOrEqual(t, ac, 0, "%v <= %v <= %v", a, b, c)
+				}
+				if ab == 0 && bc == 0 {
+					assert.Equal(t, 0, ac, "%v == %v == %v", a, b, c)
+				}
+			}
+		}
+	}
+}
+
+func sign(value int) int {
+	switch {
+	case value < 0:
+		return -1
+	case value > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+type Counter struct {
+	Id    int32
+	Value int
+}
+
+type NoId struct {
+	Name string
+}
+
+func TestIdGeneratorsUtils(t *testing.T) {
+	uuid := persist.NewUuidIdGenerator().NextId().(string)
+	assert.Regexp(t, "^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$", uuid)
+
+	// Time-ordered ids are increasing
+	uuid7 := persist.NewUuid7IdGenerator()
+	ulid := persist.NewUlidIdGenerator()
+	lastUuid, lastUlid := "", ""
+	for i := 0; i < 1000; i++ {
+		nextUuid := uuid7.NextId().(string)
+		nextUlid := ulid.NextId().(string)
+		assert.Regexp(t, "^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$", nextUuid)
+		assert.Regexp(t, "^[0-7][0-9A-HJKMNP-TV-Z]{25}$", nextUlid)
+		assert.True(t, nextUuid > lastUuid)
+		assert.True(t, nextUlid > lastUlid)
+		lastUuid, lastUlid = nextUuid, nextUlid
+	}
+
+	sequence := persist.NewSequenceIdGenerator()
+	sequence.Observe("41")
+	sequence.Observe("abc")
+	assert.Equal(t, int64(42), sequence.NextId())
+}
+
+func TestGenerateIntegerObjectIdUtils(t *testing.T) {
+	var item interface{} = Counter{Value: 1}
+	err := persist.GenerateObjectIdWithGenerator(&item, persist.IdGeneratorFunc(func() interface{} { return "7" }))
+	assert.Nil(t, err)
+	assert.Equal(t, int32(7), item.(Counter).Id)
+
+	// Ids that do not fit into the field are rejected
+	item = Counter{Value: 1}
+	err = persist.GenerateObjectIdWithGenerator(&item, persist.IdGeneratorFunc(func() interface{} { return int64(1) << 40 }))
+	assert.NotNil(t, err)
+
+	// Integer ids are not generated without a generator that knows stored ids
+	item = Counter{Value: 1}
+	assert.NotNil(t, persist.GenerateObjectId(&item))
+	assert.Zero(t, item.(Counter).Id)
+
+	sequence := persist.NewSequenceIdGenerator()
+	sequence.Observe(int32(5))
+	item = Counter{Value: 1}
+	assert.Nil(t, persist.GenerateObjectIdWithGenerator(&item, sequence))
+	assert.Equal(t, int32(6), item.(Counter).Id)
+
+	item = NoId{Name: "name"}
+	err = persist.GenerateObjectId(&item)
+	assert.NotNil(t, err)
 }
 
 func TestGenerateObjectIdUtils(t *testing.T) {
@@ -167,3 +396,160 @@ func TestGenerateObjectIdUtils(t *testing.T) {
 	assert.Equal(t, nestedOwnerGroup.NestedField, "nested 3")
 	assert.Equal(t, nestedOwnerGroup.Testing, 9876)
 }
+
+type Address struct {
+	City  string   `json:"city" bson:"town"`
+	Lines []string `json:"lines"`
+}
+
+type Customer struct {
+	Id        string             `json:"id"`
+	FullName  string             `json:"full_name"`
+	Address   Address            `json:"address"`
+	Previous  *Address           `json:"previous"`
+	Addresses []Address          `json:"addresses"`
+	Visits    int64              `json:"visits,string"`
+	Updated   time.Time          `json:"updated"`
+	Extra     map[string]Address `json:"extra"`
+}
+
+func TestGetSetPropertyUtils(t *testing.T) {
+	now := time.Now().UTC()
+	customer := &Customer{
+		Id:        "1",
+		FullName:  "John Smith",
+		Address:   Address{City: "Paris", Lines: []string{"Line 1", "Line 2"}},
+		Addresses: []Address{{City: "Rome"}},
+		Updated:   now,
+	}
+
+	// Json tags, field names and nested paths
+	assert.Equal(t, "John Smith", persist.GetProperty(customer, "full_name"))
+	assert.Equal(t, "John Smith", persist.GetProperty(customer, "FullName"))
+	assert.Equal(t, "John Smith", persist.GetProperty(*customer, "FULL_NAME"))
+	assert.Equal(t, now, persist.GetProperty(customer, "updated"))
+	assert.Equal(t, "Paris", persist.GetProperty(customer, "address.city"))
+	assert.Equal(t, "Paris", persist.GetProperty(customer, "City"))
+	assert.Equal(t, "Line 2", persist.GetProperty(customer, "address.lines[1]"))
+	assert.Equal(t, "Rome", persist.GetProperty(customer, "addresses.0.city"))
+	assert.Nil(t, persist.GetProperty(customer, "addresses[1].city"))
+	assert.Nil(t, persist.GetProperty(customer, "previous.city"))
+	assert.Nil(t, persist.GetProperty(customer, "unknown"))
+
+	// Custom tags are checked before json tags
+	assert.Equal(t, "Paris", persist.GetPropertyWithTag(customer, "address.town", "bson"))
+
+	// Values are converted to types of properties
+	persist.SetProperty(customer, "full_name", "Jane Smith")
+	persist.SetProperty(customer, "visits", "12")
+	persist.SetProperty(customer, "address.lines[0]", "New line")
+	persist.SetProperty(customer, "addresses[0].city", "Milan")
+	persist.SetProperty(customer, "previous.city", "London")
+	persist.SetProperty(customer, "extra.work.city", "Berlin")
+	persist.SetProperty(customer, "updated", "2020-01-02T03:04:05Z")
+	persist.SetPropertyWithTag(customer, "address.town", "Lyon", "bson")
+	assert.Equal(t, "Jane Smith", customer.FullName)
+	assert.Equal(t, int64(12), customer.Visits)
+	assert.Equal(t, "New line", customer.Address.Lines[0])
+	assert.Equal(t, "Milan", customer.Addresses[0].City)
+	assert.Equal(t, "London", customer.Previous.City)
+	assert.Equal(t, "Berlin", customer.Extra["work"].City)
+	assert.Equal(t, 2020, customer.Updated.Year())
+	assert.Equal(t, "Lyon", customer.Address.City)
+
+	// Values that cannot be converted are ignored
+	persist.SetProperty(customer, "visits", "many")
+	assert.Equal(t, int64(12), customer.Visits)
+
+	// Maps are accessed by keys and nested paths
+	data := map[string]interface{}{
+		"name":  "ABC",
+		"a.b":   1,
+		"items": []interface{}{map[string]interface{}{"value": 1}},
+	}
+	assert.Equal(t, "ABC", persist.GetProperty(data, "NAME"))
+	assert.Equal(t, 1, persist.GetProperty(data, "a.b"))
+	assert.Equal(t, 1, persist.GetProperty(data, "items[0].value"))
+	persist.SetProperty(data, "items[0].value", 2)
+	persist.SetProperty(data, "location.city", "Paris")
+	assert.Equal(t, 2, persist.GetProperty(data, "items.0.value"))
+	assert.Equal(t, "Paris", persist.GetProperty(data, "location.city"))
+
+	// Names of new map entries are lower case
+	persist.SetProperty(data, "Title", "Manager")
+	persist.SetProperty(data, "Address.City", "Rome")
+	assert.Equal(t, "Manager", data["title"])
+	assert.Equal(t, "Rome", persist.GetProperty(data, "address.city"))
+	_, ok := data["Title"]
+	assert.False(t, ok)
+}
+
+type LoadedStatus string
+
+type LoadedBase struct {
+	Id      string    `json:"id"`
+	Created time.Time `json:"created"`
+}
+
+type LoadedItem struct {
+	LoadedBase
+	Count   int32        `json:"count"`
+	Big     int64        `json:"big"`
+	Quoted  uint64       `json:"quoted,string"`
+	Ratio   float32      `json:"ratio"`
+	Active  bool         `json:"active"`
+	Status  LoadedStatus `json:"status"`
+	Ignored string       `json:"-"`
+	Name    string
+}
+
+type LoadedNestedItem struct {
+	Id   string `json:"id"`
+	Base LoadedBase
+}
+
+func loadItems(prototype reflect.Type, items []interface{}) []interface{} {
+	persistence := persist.NewMemoryPersistence(prototype)
+	persistence.Loader = &benchmarkLoader{items: items}
+	persistence.Open("")
+	return persistence.Items
+}
+
+func TestLoadItemsUtils(t *testing.T) {
+	items := []interface{}{
+		map[string]interface{}{
+			"id":      "1",
+			"created": "2020-01-02T03:04:05.123Z",
+			"count":   float64(12),
+			"big":     float64(1234757257822780121),
+			"quoted":  "18446744073709551615",
+			"ratio":   0.1,
+			"active":  true,
+			"status":  "ready",
+			"Ignored": "value",
+			"NAME":    "name",
+			"unknown": "value",
+		},
+		// Invalid values are decoded from JSON
+		map[string]interface{}{"id": "2", "count": 1.5, "status": "new"},
+		map[string]interface{}{"id": "3", "count": float64(1 << 40)},
+		map[string]interface{}{"id": "4", "quoted": float64(1), "active": nil},
+	}
+
+	loaded := loadItems(reflect.TypeOf(LoadedItem{}), items)
+	assert.Len(t, loaded, len(items))
+	for i, item := range items {
+		data, _ := json.Marshal(item)
+		var expected LoadedItem
+		json.Unmarshal(data, &expected)
+		assert.Equal(t, expected, loaded[i])
+	}
+	assert.Equal(t, uint64(18446744073709551615), loaded[0].(LoadedItem).Quoted)
+	assert.Equal(t, "name", loaded[0].(LoadedItem).Name)
+
+	// Nested structs are decoded from JSON
+	loaded = loadItems(reflect.TypeOf(LoadedNestedItem{}), []interface{}{
+		map[string]interface{}{"id": "1", "Base": map[string]interface{}{"id": "2"}},
+	})
+	assert.Equal(t, "2", loaded[0].(LoadedNestedItem).Base.Id)
+}